@@ -22,6 +22,12 @@ var (
 func init() {
 	// Initialize configuration
 	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if cfg.EncryptionKey == config.DefaultEncryptionKey {
+		log.Println("Warning: ENCRYPTION_KEY is not set, backups are encrypted with the public default key")
+	}
 
 	// Initialize database
 	db, err := repository.InitDB(cfg.DatabasePath)
@@ -38,7 +44,12 @@ func init() {
 	repos := repository.NewRepositories(db)
 
 	// Initialize services
-	services := service.NewServices(repos)
+	services := service.NewServices(repos, cfg)
+
+	// Make sure somebody can log in to a fresh install
+	if err := services.Auth.EnsureInitialPIN(cfg.InitialStaffPIN); err != nil {
+		log.Fatal("Failed to set the initial staff PIN:", err)
+	}
 
 	// Initialize Gin router
 	engine = gin.Default()
@@ -67,7 +78,7 @@ func init() {
 	}
 
 	// Initialize handlers
-	h := handlers.NewHandlers(services)
+	h := handlers.NewHandlers(services, cfg)
 
 	// Setup routes
 	handlers.SetupRoutes(engine, h)
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -13,6 +16,9 @@ import (
 )
 
 func main() {
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "print the SQL of the pending database migrations and exit")
+	flag.Parse()
+
 	// Load .env file if exists
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
@@ -20,6 +26,12 @@ func main() {
 
 	// Initialize configuration
 	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if cfg.EncryptionKey == config.DefaultEncryptionKey {
+		log.Println("Warning: ENCRYPTION_KEY is not set, backups are encrypted with the public default key")
+	}
 
 	// Initialize database
 	db, err := repository.InitDB(cfg.DatabasePath)
@@ -28,6 +40,20 @@ func main() {
 	}
 	defer db.Close()
 
+	if *migrateDryRun {
+		pending, err := repository.PendingMigrations(db)
+		if err != nil {
+			log.Fatal("Failed to check migrations:", err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("-- No pending migrations")
+		}
+		for _, migration := range pending {
+			fmt.Printf("-- Migration %s\n%s\n", migration.Name, migration.SQL)
+		}
+		return
+	}
+
 	// Run migrations
 	if err := repository.RunMigrations(db); err != nil {
 		log.Fatal("Failed to run migrations:", err)
@@ -37,7 +63,18 @@ func main() {
 	repos := repository.NewRepositories(db)
 
 	// Initialize services
-	services := service.NewServices(repos)
+	services := service.NewServices(repos, cfg)
+
+	// Make sure somebody can log in to a fresh install
+	if err := services.Auth.EnsureInitialPIN(cfg.InitialStaffPIN); err != nil {
+		log.Fatal("Failed to set the initial staff PIN:", err)
+	}
+
+	// Send queued receipts to the printer in the background
+	go services.PrintQueue.Run(context.Background())
+
+	// Back up the database on a schedule
+	go services.Backup.Run(context.Background())
 
 	// Initialize Gin router
 	router := gin.Default()
@@ -51,7 +88,7 @@ func main() {
 	router.Static("/js", "./web/static/js")
 
 	// Initialize handlers
-	h := handlers.NewHandlers(services)
+	h := handlers.NewHandlers(services, cfg)
 
 	// Setup routes
 	handlers.SetupRoutes(router, h)
@@ -0,0 +1,88 @@
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// code128Patterns holds the bar and space widths of every Code 128 symbol,
+// starting with a bar. 103-105 are the start codes, 106 is the stop code.
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+	// code128QuietZone is the blank margin on each side, in modules
+	code128QuietZone = 10
+)
+
+// Code128 is an encoded Code 128 barcode
+type Code128 struct {
+	// Bars holds one entry per module, true for a bar
+	Bars []bool
+}
+
+// EncodeCode128 encodes printable ASCII text with code set B, which covers
+// upper and lower case item codes
+func EncodeCode128(text string) (*Code128, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text is empty")
+	}
+
+	symbols := []int{code128StartB}
+	checksum := code128StartB
+	for i, r := range text {
+		if r < 32 || r > 127 {
+			return nil, fmt.Errorf("character %q cannot be encoded in Code 128", r)
+		}
+		value := int(r) - 32
+		symbols = append(symbols, value)
+		checksum += (i + 1) * value
+	}
+	symbols = append(symbols, checksum%103, code128Stop)
+
+	var bars []bool
+	for _, symbol := range symbols {
+		for i, w := range code128Patterns[symbol] {
+			for j := 0; j < int(w-'0'); j++ {
+				bars = append(bars, i%2 == 0)
+			}
+		}
+	}
+	return &Code128{Bars: bars}, nil
+}
+
+// Image draws the barcode with its quiet zones, scaled to fit width pixels.
+// Modules are never narrower than one pixel.
+func (b *Code128) Image(width, height int) *image.Paletted {
+	modules := len(b.Bars) + 2*code128QuietZone
+	scale := width / modules
+	if scale < 1 {
+		scale = 1
+	}
+	if width < modules*scale {
+		width = modules * scale
+	}
+	offset := (width-modules*scale)/2 + code128QuietZone*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.White, color.Black})
+	for i, bar := range b.Bars {
+		if bar {
+			fillRect(img, offset+i*scale, 0, scale, height)
+		}
+	}
+	return img
+}
@@ -0,0 +1,69 @@
+package barcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCode128Patterns(t *testing.T) {
+	for i, pattern := range code128Patterns {
+		sum := 0
+		for _, w := range pattern {
+			sum += int(w - '0')
+		}
+		if i == code128Stop {
+			assert.Equal(t, 13, sum, "symbol %d", i)
+		} else {
+			assert.Equal(t, 11, sum, "symbol %d", i)
+		}
+	}
+}
+
+func TestEncodeCode128(t *testing.T) {
+	b, err := EncodeCode128("PJJ123C")
+	require.NoError(t, err)
+
+	// Start, 7 characters, checksum and stop
+	assert.Len(t, b.Bars, 9*11+13)
+
+	// Decode the symbols back by their patterns
+	var symbols []int
+	for pos := 0; pos < len(b.Bars)-13; pos += 11 {
+		pattern := ""
+		run := 0
+		for i := pos; i < pos+11; i++ {
+			run++
+			if i == pos+10 || b.Bars[i] != b.Bars[i+1] {
+				pattern += string(rune('0' + run))
+				run = 0
+			}
+		}
+		for symbol, p := range code128Patterns {
+			if p == pattern {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	// P J J 1 2 3 C in code set B; (104 + 48 + 2*42 + 3*42 + 4*17 + 5*18 + 6*19 + 7*35) % 103 = 55
+	assert.Equal(t, []int{104, 48, 42, 42, 17, 18, 19, 35, 55}, symbols)
+
+	_, err = EncodeCode128("")
+	assert.Error(t, err)
+	_, err = EncodeCode128("あ")
+	assert.Error(t, err)
+}
+
+func TestCode128_Image(t *testing.T) {
+	b, err := EncodeCode128("ITEM-001")
+	require.NoError(t, err)
+
+	modules := len(b.Bars) + 20
+	img := b.Image(2*modules, 50)
+	assert.Equal(t, 2*modules, img.Bounds().Dx())
+	assert.Equal(t, 50, img.Bounds().Dy())
+	// The start symbol begins with a bar after the quiet zone
+	assert.Equal(t, uint8(0), img.ColorIndexAt(19, 0))
+	assert.Equal(t, uint8(1), img.ColorIndexAt(20, 0))
+}
@@ -0,0 +1,169 @@
+package barcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Version 1-M "HELLO WORLD" from the worked example of the standard
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ecc)
+}
+
+func TestQRCapacity(t *testing.T) {
+	// Byte mode capacities of level M from the standard
+	assert.Equal(t, 14, qrDataCodewords(1)-2)
+	assert.Equal(t, 26, qrDataCodewords(2)-2)
+	assert.Equal(t, 122, qrDataCodewords(7)-2)
+	assert.Equal(t, 2331, qrDataCodewords(40)-3)
+	assert.Equal(t, []int{6, 22, 38}, qrAlignmentPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, qrAlignmentPositions(32))
+}
+
+// decodeQR reads a code back the way a scanner would: format bits, mask,
+// codewords, error correction and finally the byte mode payload
+func decodeQR(t *testing.T, q *QRCode) string {
+	version := (q.Size - 17) / 4
+
+	format := 0
+	for i := 0; i < 8; i++ {
+		if q.Dark(q.Size-1-i, 8) {
+			format |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if q.Dark(8, q.Size-15+i) {
+			format |= 1 << i
+		}
+	}
+	format ^= 0x5412
+	require.Equal(t, 0, format>>13, "error correction level M")
+	mask := (format >> 10) & 7
+
+	// A fresh code of the same version tells which modules hold data
+	layout := &QRCode{Size: q.Size, modules: newGrid(q.Size), isFunction: newGrid(q.Size)}
+	layout.drawFunctionPatterns(version)
+	unmasked := &QRCode{Size: q.Size, modules: newGrid(q.Size), isFunction: layout.isFunction}
+	for y := range q.modules {
+		copy(unmasked.modules[y], q.modules[y])
+	}
+	unmasked.applyMask(mask)
+
+	var bits bitBuffer
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if !layout.isFunction[y][x] {
+					bits = append(bits, unmasked.modules[y][x])
+				}
+			}
+		}
+	}
+	raw := make([]byte, qrRawModules(version)/8)
+	for i := range raw {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				raw[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	// De-interleave and check every block's error correction
+	numBlocks, eccLen := qrNumBlocks[version], qrECCPerBlock[version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortData := len(raw)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortData+1; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+	for _, block := range blocks {
+		n := len(block) - eccLen
+		assert.Equal(t, reedSolomonRemainder(block[:n], reedSolomonDivisor(eccLen)), block[n:])
+		data = append(data, block[:n]...)
+	}
+
+	require.Equal(t, byte(0x4), data[0]>>4, "byte mode")
+	read := func(pos, n int) int {
+		v := 0
+		for i := pos; i < pos+n; i++ {
+			v = v<<1 | int(data[i/8]>>(7-i%8))&1
+		}
+		return v
+	}
+	countBits := 8
+	if version > 9 {
+		countBits = 16
+	}
+	length := read(4, countBits)
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return string(text)
+}
+
+func TestEncodeQR(t *testing.T) {
+	for _, text := range []string{"", "ITEM-001", "4901234567894", strings.Repeat("KidsPOS ", 40), strings.Repeat("あ", 300)} {
+		q, err := EncodeQR(text)
+		require.NoError(t, err)
+		assert.Equal(t, text, decodeQR(t, q))
+
+		// Finder pattern corners, a separator and the dark module
+		assert.True(t, q.Dark(0, 0))
+		assert.True(t, q.Dark(q.Size-1, 0))
+		assert.True(t, q.Dark(0, q.Size-1))
+		assert.False(t, q.Dark(7, 7))
+		assert.True(t, q.Dark(8, q.Size-8))
+	}
+
+	t.Run("short ids fit version 1", func(t *testing.T) {
+		q, err := EncodeQR("ITEM-001")
+		require.NoError(t, err)
+		assert.Equal(t, 21, q.Size)
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		_, err := EncodeQR(strings.Repeat("x", 2332))
+		assert.Error(t, err)
+	})
+}
+
+func TestQRCode_Image(t *testing.T) {
+	q, err := EncodeQR("ITEM-001")
+	require.NoError(t, err)
+
+	// 29 modules including the quiet zone fit 6 times into 200 pixels
+	img := q.Image(200)
+	assert.Equal(t, 200, img.Bounds().Dx())
+	offset := (200-29*6)/2 + 4*6
+	assert.Equal(t, uint8(1), img.ColorIndexAt(offset, offset))
+	assert.Equal(t, uint8(0), img.ColorIndexAt(offset-1, offset-1))
+
+	// Never smaller than one pixel per module
+	assert.Equal(t, 29, q.Image(10).Bounds().Dx())
+}
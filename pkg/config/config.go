@@ -1,9 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
+// DefaultEncryptionKey is used when ENCRYPTION_KEY is not set. It is public,
+// so it protects nothing and is refused in production.
+const DefaultEncryptionKey = "DefaultKidsPOSKey123!@#"
+
 type Config struct {
 	DatabasePath     string
 	Port             string
@@ -12,20 +19,77 @@ type Config struct {
 	QRCodeSize       int
 	AllowedIPPrefix  string
 	EncryptionKey    string
+	// IdempotencyKeyTTL is how long a sale's idempotency key keeps
+	// retries from creating a second sale
+	IdempotencyKeyTTL time.Duration
+	// AdminAllowedIPs and RegisterAllowedIPs list the networks that may use
+	// the admin pages and the register API, as CIDR ranges or prefixes
+	// like "192.168.". Both default to AllowedIPPrefix.
+	AdminAllowedIPs    string
+	RegisterAllowedIPs string
+	// TrustedProxies lists the reverse proxies whose X-Forwarded-For header
+	// is believed. Empty means the connection's address is used.
+	TrustedProxies string
+	// TrustedPlatform names a hosting platform that reports the client
+	// address in a header it controls, e.g. "vercel"
+	TrustedPlatform string
+	// SessionTTL is how long a staff login lasts, in the web UI and for
+	// register tokens alike
+	SessionTTL time.Duration
+	// InitialStaffPIN is given to the first staff member when nobody can
+	// log in yet, so a fresh install can be set up
+	InitialStaffPIN string
+	// RequireDevice makes the register API refuse requests that do not come
+	// from an approved device. The admin pages and admin API are not affected.
+	RequireDevice bool
+	// BackupDir is where database backups are kept. BackupInterval is how
+	// often one is taken while the server runs, and BackupKeep how many are
+	// kept before the oldest are deleted.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+	// Production is set when Gin runs in release mode (GIN_MODE=release)
+	Production bool
 }
 
 func New() *Config {
+	allowedIPPrefix := getEnv("ALLOWED_IP_PREFIX", "192.168.")
+	trustedPlatform := ""
+	if os.Getenv("VERCEL") != "" {
+		trustedPlatform = "vercel"
+	}
+
 	return &Config{
 		DatabasePath:     getEnv("DATABASE_PATH", "/tmp/kidspos.db"),
 		Port:            getEnv("PORT", "8080"),
 		ReceiptPrinterHost: getEnv("RECEIPT_PRINTER_HOST", "localhost"),
 		ReceiptPrinterPort: getEnv("RECEIPT_PRINTER_PORT", "9100"),
 		QRCodeSize:      getEnvAsInt("QR_CODE_SIZE", 200),
-		AllowedIPPrefix: getEnv("ALLOWED_IP_PREFIX", "192.168."),
-		EncryptionKey:   getEnv("ENCRYPTION_KEY", "DefaultKidsPOSKey123!@#"),
+		AllowedIPPrefix: allowedIPPrefix,
+		EncryptionKey:   getEnv("ENCRYPTION_KEY", DefaultEncryptionKey),
+		IdempotencyKeyTTL: getEnvAsDuration("IDEMPOTENCY_KEY_TTL", 7*24*time.Hour),
+		AdminAllowedIPs:    getEnv("ADMIN_ALLOWED_IPS", allowedIPPrefix),
+		RegisterAllowedIPs: getEnv("REGISTER_ALLOWED_IPS", allowedIPPrefix),
+		TrustedProxies:     getEnv("TRUSTED_PROXIES", ""),
+		TrustedPlatform:    getEnv("TRUSTED_PLATFORM", trustedPlatform),
+		SessionTTL:         getEnvAsDuration("SESSION_TTL", 12*time.Hour),
+		InitialStaffPIN:    getEnv("INITIAL_STAFF_PIN", ""),
+		RequireDevice:      getEnvAsBool("REQUIRE_DEVICE", false),
+		BackupDir:          getEnv("BACKUP_DIR", "./backups"),
+		BackupInterval:     getEnvAsDuration("BACKUP_INTERVAL", 24*time.Hour),
+		BackupKeep:         getEnvAsInt("BACKUP_KEEP", 7),
+		Production:         os.Getenv("GIN_MODE") == "release",
 	}
 }
 
+// Validate reports settings the server must not start with
+func (c *Config) Validate() error {
+	if c.Production && c.EncryptionKey == DefaultEncryptionKey {
+		return fmt.Errorf("ENCRYPTION_KEY must be set to a secret of your own in production")
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -34,6 +98,28 @@ func getEnv(key, defaultValue string) string {
 }
 
 func getEnvAsInt(key string, defaultValue int) int {
-	// Simple implementation for now
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
 	return defaultValue
 }
\ No newline at end of file
@@ -0,0 +1,217 @@
+// Package encrypt encrypts backup and export files with a passphrase.
+//
+// The key is derived from the passphrase with scrypt and a random salt kept
+// in the file header. The data is sealed with AES-256-GCM in chunks, so
+// large files never have to fit in memory. Each chunk's nonce holds its
+// number and the last chunk is marked, so chunks cannot be reordered,
+// dropped or cut off without the file failing to decrypt.
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Magic starts every encrypted file
+var Magic = []byte("KPOSENC1")
+
+// ErrDecrypt is returned for a wrong passphrase or a damaged file; the two
+// cannot be told apart
+var ErrDecrypt = errors.New("wrong encryption key or damaged file")
+
+const (
+	saltSize   = 16
+	prefixSize = 7 // random part of the nonces
+	headerSize = 8 + saltSize + prefixSize
+	chunkSize  = 64 * 1024
+)
+
+// scryptN is the scrypt cost. Tests lower it.
+var scryptN = 1 << 15
+
+// IsEncrypted reports whether data starts like an encrypted file
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, Magic)
+}
+
+// Writer encrypts what is written to it. Close must be called to write the
+// last chunk; it does not close the underlying writer.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	buf    []byte
+	count  uint32
+	closed bool
+}
+
+// NewWriter writes the header of an encrypted file to w and returns a
+// Writer for its contents
+func NewWriter(w io.Writer, passphrase string) (*Writer, error) {
+	header := make([]byte, headerSize)
+	copy(header, Magic)
+	if _, err := rand.Read(header[len(Magic):]); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, header[len(Magic):len(Magic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: header[len(Magic)+saltSize:],
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to closed encrypt.Writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		// A full chunk is only sealed once more data follows, so that the
+		// last chunk is always shorter than a full one
+		if len(w.buf) == chunkSize && len(p) > 0 {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the last chunk
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) == chunkSize {
+		if err := w.seal(false); err != nil {
+			return err
+		}
+	}
+	return w.seal(true)
+}
+
+func (w *Writer) seal(last bool) error {
+	nonce := chunkNonce(w.prefix, w.count, last)
+	sealed := w.aead.Seal(nil, nonce, w.buf, w.header)
+	w.count++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts an encrypted file
+type Reader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	chunk  []byte
+	plain  []byte
+	count  uint32
+	done   bool
+}
+
+// NewReader reads the header of an encrypted file from r and returns a
+// Reader for its contents. Data is only returned once its chunk has been
+// authenticated.
+func NewReader(r io.Reader, passphrase string) (*Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsEncrypted(header) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	aead, err := newAEAD(passphrase, header[len(Magic):len(Magic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		prefix: header[len(Magic)+saltSize:],
+		chunk:  make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *Reader) open() error {
+	n, err := io.ReadFull(r.r, r.chunk)
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	nonce := chunkNonce(r.prefix, r.count, last)
+	plain, err := r.aead.Open(r.chunk[:0], nonce, r.chunk[:n], r.header)
+	if err != nil {
+		return ErrDecrypt
+	}
+	r.plain = plain
+	r.count++
+	r.done = last
+	return nil
+}
+
+// newAEAD derives the key from the passphrase and salt
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce is the random prefix, the chunk number and whether it is the
+// last chunk
+func chunkNonce(prefix []byte, count uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], count)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
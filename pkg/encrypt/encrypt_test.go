@@ -0,0 +1,104 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	scryptN = 1 << 10
+}
+
+func encrypt(t *testing.T, data []byte, passphrase string) []byte {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, passphrase)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return out.Bytes()
+}
+
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 100} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		sealed := encrypt(t, data, "secret")
+		assert.True(t, IsEncrypted(sealed))
+		if size > 16 {
+			assert.False(t, bytes.Contains(sealed, data[:16]), "size %d", size)
+		}
+
+		opened, err := decrypt(sealed, "secret")
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, data, opened, "size %d", size)
+	}
+}
+
+func TestSmallWrites(t *testing.T) {
+	data := make([]byte, 2*chunkSize+10)
+	rand.Read(data)
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, "secret")
+	require.NoError(t, err)
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err := w.Write(data[i:end])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	opened, err := decrypt(out.Bytes(), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, data, opened)
+}
+
+func TestDecryptFails(t *testing.T) {
+	data := make([]byte, 2*chunkSize)
+	rand.Read(data)
+	sealed := encrypt(t, data, "secret")
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, err := decrypt(sealed, "wrong")
+		assert.ErrorIs(t, err, ErrDecrypt)
+	})
+
+	t.Run("changed byte", func(t *testing.T) {
+		changed := append([]byte(nil), sealed...)
+		changed[len(changed)/2] ^= 1
+		_, err := decrypt(changed, "secret")
+		assert.ErrorIs(t, err, ErrDecrypt)
+	})
+
+	t.Run("cut off at a chunk", func(t *testing.T) {
+		chunk := chunkSize + 16
+		_, err := decrypt(sealed[:headerSize+chunk], "secret")
+		assert.ErrorIs(t, err, ErrDecrypt)
+		_, err = decrypt(sealed[:len(sealed)-1], "secret")
+		assert.ErrorIs(t, err, ErrDecrypt)
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		_, err := decrypt([]byte("SQLite format 3\x00"), "secret")
+		assert.Error(t, err)
+		assert.False(t, IsEncrypted([]byte("SQLite format 3\x00")))
+	})
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
 	"github.com/gin-gonic/gin"
@@ -39,6 +43,66 @@ func (h *Handlers) APIItemsGet(c *gin.Context) {
 	c.JSON(http.StatusOK, item)
 }
 
+// APIItemsGetByCode returns the item with a scanned itemId or alias code
+func (h *Handlers) APIItemsGetByCode(c *gin.Context) {
+	item, err := h.itemService.GetItemByCode(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// APIItemsAddAlias adds an alias code to an item via API
+func (h *Handlers) APIItemsAddAlias(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := h.itemService.GetItem(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if !allowStore(c, existing.StoreID) {
+		return
+	}
+
+	item, err := h.itemService.AddAlias(currentActor(c), id, payload.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// APIItemsDeleteAlias removes an alias code from an item via API
+func (h *Handlers) APIItemsDeleteAlias(c *gin.Context) {
+	item, ok := h.findAPIItem(c)
+	if !ok || !allowStore(c, item.StoreID) {
+		return
+	}
+
+	if err := h.itemService.DeleteAlias(currentActor(c), item.ID, c.Param("code")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alias deleted successfully"})
+}
+
 // APIItemsCreate creates a new item via API
 func (h *Handlers) APIItemsCreate(c *gin.Context) {
 	var item models.Item
@@ -47,7 +111,12 @@ func (h *Handlers) APIItemsCreate(c *gin.Context) {
 		return
 	}
 
-	if err := h.itemService.CreateItem(&item); err != nil {
+	// Store managers add items to their own store
+	if scope := storeScope(c); scope != 0 {
+		item.StoreID = &scope
+	}
+
+	if err := h.itemService.CreateItem(currentActor(c), &item); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -57,9 +126,8 @@ func (h *Handlers) APIItemsCreate(c *gin.Context) {
 
 // APIItemsUpdate updates an item via API
 func (h *Handlers) APIItemsUpdate(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+	existing, ok := h.findAPIItem(c)
+	if !ok || !allowStore(c, existing.StoreID) {
 		return
 	}
 
@@ -69,8 +137,9 @@ func (h *Handlers) APIItemsUpdate(c *gin.Context) {
 		return
 	}
 
-	item.ID = id
-	if err := h.itemService.UpdateItem(&item); err != nil {
+	item.ID = existing.ID
+	item.StoreID = existing.StoreID
+	if err := h.itemService.UpdateItem(currentActor(c), &item); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -80,13 +149,12 @@ func (h *Handlers) APIItemsUpdate(c *gin.Context) {
 
 // APIItemsDelete deletes an item via API
 func (h *Handlers) APIItemsDelete(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+	item, ok := h.findAPIItem(c)
+	if !ok || !allowStore(c, item.StoreID) {
 		return
 	}
 
-	if err := h.itemService.DeleteItem(id); err != nil {
+	if err := h.itemService.DeleteItem(currentActor(c), item.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -94,9 +162,64 @@ func (h *Handlers) APIItemsDelete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Item deleted successfully"})
 }
 
+// APIItemsQRCode returns a QR code of the item code as PNG
+func (h *Handlers) APIItemsQRCode(c *gin.Context) {
+	item, ok := h.findAPIItem(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.itemService.QRCodePNG(item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// APIItemsBarcode returns a Code 128 barcode of the item code as PNG
+func (h *Handlers) APIItemsBarcode(c *gin.Context) {
+	item, ok := h.findAPIItem(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.itemService.BarcodePNG(item)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// findAPIItem loads the item named by the :id parameter, responding with
+// an error when there is none
+func (h *Handlers) findAPIItem(c *gin.Context) (*models.Item, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	item, err := h.itemService.GetItem(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return nil, false
+	}
+	return item, true
+}
+
 // APISalesList returns sales list as JSON
 func (h *Handlers) APISalesList(c *gin.Context) {
-	sales, err := h.saleService.GetAllSales()
+	filter, err := parseSaleFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sales, err := h.saleService.GetSalesReport(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -110,8 +233,22 @@ func (h *Handlers) APISalesList(c *gin.Context) {
 
 // APISalesGet returns a single sale as JSON
 func (h *Handlers) APISalesGet(c *gin.Context) {
-	// TODO: Implement get single sale
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sale, err := h.saleService.GetSale(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	}
+	if !allowStore(c, &sale.StoreID) {
+		return
+	}
+
+	c.JSON(http.StatusOK, sale)
 }
 
 // APISalesCreate creates a new sale via API
@@ -122,14 +259,244 @@ func (h *Handlers) APISalesCreate(c *gin.Context) {
 		return
 	}
 
-	if err := h.saleService.CreateSale(&sale); err != nil {
+	// Registers retry timed out requests with the same key, either as a
+	// header or as the client-generated sale ID
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		if sale.ClientID != "" && sale.ClientID != key {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key header does not match clientId"})
+			return
+		}
+		sale.ClientID = key
+	}
+
+	// The sale is rung up by whoever is logged in on whichever device sent
+	// it, not whoever the body names, and a store manager sells in their
+	// own store
+	sale.StaffID = currentStaffID(c)
+	sale.DeviceID = currentDeviceID(c)
+	if scope := storeScope(c); scope != 0 {
+		sale.StoreID = scope
+	}
+
+	if err := h.saleService.CreateSale(currentActor(c), &sale); err != nil {
+		var dupErr *models.DuplicateSaleError
+		if errors.As(err, &dupErr) {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, dupErr.Sale)
+			return
+		}
+		var stockErr *models.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     err.Error(),
+				"code":      "insufficient_stock",
+				"itemId":    stockErr.ItemID,
+				"itemCode":  stockErr.ItemCode,
+				"itemName":  stockErr.ItemName,
+				"requested": stockErr.Requested,
+				"available": stockErr.Available,
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	h.receiptService.PrintAfterSale(&sale)
 
 	c.JSON(http.StatusCreated, sale)
 }
 
+// APISalesBatch records sales queued by a register while it was offline
+func (h *Handlers) APISalesBatch(c *gin.Context) {
+	var payload struct {
+		Sales []models.Sale `json:"sales"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope := storeScope(c)
+	for i := range payload.Sales {
+		payload.Sales[i].StaffID = currentStaffID(c)
+		payload.Sales[i].DeviceID = currentDeviceID(c)
+		if scope != 0 {
+			payload.Sales[i].StoreID = scope
+		}
+	}
+
+	results, err := h.saleService.CreateSales(currentActor(c), payload.Sales)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	counts := map[string]int{
+		models.BatchSaleCreated:   0,
+		models.BatchSaleDuplicate: 0,
+		models.BatchSaleRejected:  0,
+	}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":    results,
+		"created":    counts[models.BatchSaleCreated],
+		"duplicates": counts[models.BatchSaleDuplicate],
+		"rejected":   counts[models.BatchSaleRejected],
+	})
+}
+
+// APISalesPrint queues the receipt of a sale for the receipt printer
+func (h *Handlers) APISalesPrint(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sale, err := h.saleService.GetSale(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	}
+	if !allowStore(c, &sale.StoreID) {
+		return
+	}
+
+	job, err := h.receiptService.PrintSale(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// APISalesReceipt renders the receipt of a sale as HTML, plain text or PDF
+func (h *Handlers) APISalesReceipt(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "txt" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html, txt or pdf"})
+		return
+	}
+
+	sale, err := h.saleService.GetSale(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	}
+	if !allowStore(c, &sale.StoreID) {
+		return
+	}
+
+	r, err := h.receiptService.GetReceipt(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	switch format {
+	case "txt":
+		contentType = "text/plain; charset=utf-8"
+		buf.WriteString(r.Text())
+	case "pdf":
+		contentType = "application/pdf"
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=receipt-%d.pdf", id))
+		err = r.WritePDF(&buf)
+	default:
+		err = r.WriteHTML(&buf)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// APISalesVoid voids a whole sale via API
+func (h *Handlers) APISalesVoid(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !h.allowAPISale(c, id) {
+		return
+	}
+
+	reversal, err := h.saleService.VoidSale(currentActor(c), id, payload.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reversal)
+}
+
+// APISalesRefund refunds selected lines of a sale via API
+func (h *Handlers) APISalesRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var payload struct {
+		Reason  string              `json:"reason"`
+		Details []models.RefundLine `json:"details"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.allowAPISale(c, id) {
+		return
+	}
+
+	reversal, err := h.saleService.RefundSale(currentActor(c), id, payload.Details, payload.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reversal)
+}
+
+// allowAPISale checks that the logged in staff member may reverse the sale,
+// responding with an error otherwise
+func (h *Handlers) allowAPISale(c *gin.Context, id int) bool {
+	sale, err := h.saleService.GetSale(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return false
+	}
+	return allowStore(c, &sale.StoreID)
+}
+
 // APIStoresList returns stores list as JSON
 func (h *Handlers) APIStoresList(c *gin.Context) {
 	stores, err := h.storeService.GetAllStores()
@@ -169,7 +536,7 @@ func (h *Handlers) APIStoresCreate(c *gin.Context) {
 		return
 	}
 
-	if err := h.storeService.CreateStore(&store); err != nil {
+	if err := h.storeService.CreateStore(currentActor(c), &store); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -192,7 +559,7 @@ func (h *Handlers) APIStoresUpdate(c *gin.Context) {
 	}
 
 	store.ID = id
-	if err := h.storeService.UpdateStore(&store); err != nil {
+	if err := h.storeService.UpdateStore(currentActor(c), &store); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -208,7 +575,7 @@ func (h *Handlers) APIStoresDelete(c *gin.Context) {
 		return
 	}
 
-	if err := h.storeService.DeleteStore(id); err != nil {
+	if err := h.storeService.DeleteStore(currentActor(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -255,7 +622,7 @@ func (h *Handlers) APIStaffsCreate(c *gin.Context) {
 		return
 	}
 
-	if err := h.staffService.CreateStaff(&staff); err != nil {
+	if err := h.staffService.CreateStaff(currentActor(c), &staff); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -278,7 +645,7 @@ func (h *Handlers) APIStaffsUpdate(c *gin.Context) {
 	}
 
 	staff.ID = id
-	if err := h.staffService.UpdateStaff(&staff); err != nil {
+	if err := h.staffService.UpdateStaff(currentActor(c), &staff); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -294,7 +661,7 @@ func (h *Handlers) APIStaffsDelete(c *gin.Context) {
 		return
 	}
 
-	if err := h.staffService.DeleteStaff(id); err != nil {
+	if err := h.staffService.DeleteStaff(currentActor(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -328,7 +695,7 @@ func (h *Handlers) APISettingsUpdate(c *gin.Context) {
 		return
 	}
 
-	if err := h.settingService.UpdateSetting(key, payload.Value); err != nil {
+	if err := h.settingService.UpdateSetting(currentActor(c), key, payload.Value); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -338,9 +705,15 @@ func (h *Handlers) APISettingsUpdate(c *gin.Context) {
 
 // APIReportsSales returns sales report as JSON
 func (h *Handlers) APIReportsSales(c *gin.Context) {
-	sales, err := h.saleService.GetAllSales()
+	filter, err := parseSaleFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sales, err := h.saleService.GetSalesReport(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -348,23 +721,108 @@ func (h *Handlers) APIReportsSales(c *gin.Context) {
 		sales = []*models.Sale{}
 	}
 
-	// Calculate summary
+	// Calculate summary. Voids and refunds carry negative amounts, so
+	// totalAmount is already net of them.
 	totalSales := 0
 	totalAmount := 0
+	totalSubtotal := 0
+	totalTax := 0
+	totalRefunds := 0
+	refundAmount := 0
 	for _, sale := range sales {
-		totalSales++
+		if sale.Type == models.SaleTypeSale {
+			totalSales++
+		} else {
+			totalRefunds++
+			refundAmount += sale.TotalPrice
+		}
 		totalAmount += sale.TotalPrice
+		totalSubtotal += sale.Subtotal
+		totalTax += sale.Tax
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"sales":       sales,
-		"totalSales":  totalSales,
-		"totalAmount": totalAmount,
+		"sales":         sales,
+		"totalSales":    totalSales,
+		"totalAmount":   totalAmount,
+		"totalSubtotal": totalSubtotal,
+		"totalTax":      totalTax,
+		"totalRefunds":  totalRefunds,
+		"refundAmount":  refundAmount,
 	})
 }
 
 // APIReportsSalesExcel generates Excel report
 func (h *Handlers) APIReportsSalesExcel(c *gin.Context) {
-	// TODO: Implement Excel generation
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
-}
\ No newline at end of file
+	filter, err := parseSaleFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Render into memory first so that errors can still be reported as JSON
+	var buf bytes.Buffer
+	if err := h.saleService.WriteSalesReportExcel(&buf, filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("sales-report-%s.xlsx", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+}
+
+// parseSaleFilter reads the from/to/storeId/staffId query parameters.
+// Dates are accepted as YYYY-MM-DD (local time, "to" covers the whole day)
+// or RFC3339 timestamps (used as-is, "to" is exclusive).
+func parseSaleFilter(c *gin.Context) (models.SaleFilter, error) {
+	var filter models.SaleFilter
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseDateParam(from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %s", from)
+		}
+		filter.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseDateParam(to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %s", to)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = t
+	}
+	if storeID := c.Query("storeId"); storeID != "" {
+		id, err := strconv.Atoi(storeID)
+		if err != nil {
+			return filter, fmt.Errorf("invalid storeId: %s", storeID)
+		}
+		filter.StoreID = id
+	}
+	if staffID := c.Query("staffId"); staffID != "" {
+		id, err := strconv.Atoi(staffID)
+		if err != nil {
+			return filter, fmt.Errorf("invalid staffId: %s", staffID)
+		}
+		filter.StaffID = id
+	}
+
+	// Store managers only see the sales of their own store
+	if scope := storeScope(c); scope != 0 {
+		filter.StoreID = scope
+	}
+
+	return filter, nil
+}
+
+// parseDateParam parses either a YYYY-MM-DD date or an RFC3339 timestamp
+func parseDateParam(value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
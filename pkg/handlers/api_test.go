@@ -1,15 +1,23 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/config"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/encrypt"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/service"
@@ -22,78 +30,673 @@ import (
 func setupTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite", ":memory:")
 	require.NoError(t, err)
+	// Every connection to :memory: would get a database of its own
+	db.SetMaxOpenConns(1)
 
-	// Create tables
-	_, err = db.Exec(`
-		CREATE TABLE staff (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			staffId TEXT NOT NULL UNIQUE,
-			name TEXT NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	require.NoError(t, err)
-
-	_, err = db.Exec(`
-		CREATE TABLE store (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storeId TEXT NOT NULL UNIQUE,
-			name TEXT NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	require.NoError(t, err)
-
-	_, err = db.Exec(`
-		CREATE TABLE sale (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			staffId INTEGER NOT NULL,
-			storeId INTEGER NOT NULL,
-			totalPrice INTEGER NOT NULL,
-			deposit INTEGER NOT NULL,
-			saleAt DATETIME NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (staffId) REFERENCES staff(id),
-			FOREIGN KEY (storeId) REFERENCES store(id)
-		)
-	`)
-	require.NoError(t, err)
-
-	_, err = db.Exec(`
-		CREATE TABLE apk_versions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			version TEXT NOT NULL UNIQUE,
-			versionCode INTEGER NOT NULL,
-			fileName TEXT NOT NULL,
-			fileSize INTEGER NOT NULL,
-			filePath TEXT NOT NULL,
-			releaseNotes TEXT,
-			isActive INTEGER NOT NULL DEFAULT 1,
-			uploadedAt DATETIME NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
+	require.NoError(t, repository.RunMigrations(db))
+
+	// Tests add the records they need, so the sample data goes
+	_, err = db.Exec(`DELETE FROM setting; DELETE FROM staff; DELETE FROM store`)
 	require.NoError(t, err)
 
 	return db
 }
 
 func setupTestRouter(db *sql.DB) *gin.Engine {
+	return setupTestRouterWithConfig(db, config.New())
+}
+
+func setupTestRouterWithConfig(db *sql.DB, cfg *config.Config) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
+	// httptest requests carry no client address
+	cfg.AdminAllowedIPs, cfg.RegisterAllowedIPs = "*", "*"
+
 	repos := repository.NewRepositories(db)
-	services := service.NewServices(repos)
-	handlers := NewHandlers(services)
+	services := service.NewServices(repos, cfg)
+	handlers := NewHandlers(services, cfg)
 
+	loginTestStaff(router, services)
 	SetupRoutes(router, handlers)
 
 	return router
 }
 
+// testStaffID is the staff ID of the staff member the test router logs in as
+const testStaffID = "TEST-LOGIN"
+
+// loginTestStaff logs a staff member of its own in and sends its token with
+// every request that does not bring an Authorization header of its own. It
+// has to be called before the routes are set up.
+func loginTestStaff(router *gin.Engine, services *service.Services) *models.StaffSession {
+	staff := &models.Staff{StaffID: testStaffID, Name: "Test Login", PIN: "1234", Role: models.RoleAdmin}
+	if err := services.Staff.CreateStaff(models.Actor{}, staff); err != nil {
+		panic(err)
+	}
+	session, err := services.Auth.Login(testStaffID, "1234", "", models.SessionAPI)
+	if err != nil {
+		panic(err)
+	}
+
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+session.Token)
+		}
+	})
+	return session
+}
+
+func TestAPIAuth(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	login := func(staffID, pin string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"staffId": staffID, "pin": pin})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("login returns a bearer token", func(t *testing.T) {
+		w := login(testStaffID, "1234")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var session models.StaffSession
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+		assert.NotEmpty(t, session.Token)
+		assert.Equal(t, testStaffID, session.Staff.StaffID)
+
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), testStaffID)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("wrong PIN", func(t *testing.T) {
+		w := login(testStaffID, "9999")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_login"`)
+	})
+
+	t.Run("API requires a token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/items", nil)
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"login_required"`)
+	})
+
+	t.Run("web UI redirects to the login page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/items?page=2", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login?next=%2Fitems%3Fpage%3D2", w.Header().Get("Location"))
+	})
+
+	t.Run("web login sets a session cookie", func(t *testing.T) {
+		form := strings.NewReader("staffId=" + testStaffID + "&pin=1234&next=//evil.example.com")
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/login", form)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, sessionCookie, cookies[0].Name)
+		assert.True(t, cookies[0].HttpOnly)
+
+		// The web UI calls the API with its cookie
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Basic none")
+		req.AddCookie(cookies[0])
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestAPIRoles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	var storeIDs []int64
+	for _, code := range []string{"STORE-001", "STORE-002"} {
+		result, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+			code, code, time.Now(), time.Now())
+		require.NoError(t, err)
+		id, _ := result.LastInsertId()
+		storeIDs = append(storeIDs, id)
+	}
+	otherItem, err := db.Exec("INSERT INTO item (itemId, name, price, stock, storeId, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		"ITEM-OTHER", "Other Candy", 100, 10, storeIDs[1], time.Now(), time.Now())
+	require.NoError(t, err)
+	otherItemID, _ := otherItem.LastInsertId()
+
+	request := func(method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// newStaff creates a staff member as the admin test staff and logs them in
+	newStaff := func(staffID, role string, storeID *int64) string {
+		w := request(http.MethodPost, "/api/staffs", "", map[string]interface{}{
+			"staffId": staffID, "name": staffID, "pin": "1234", "role": role, "storeId": storeID,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		w = request(http.MethodPost, "/api/auth/login", "", map[string]string{"staffId": staffID, "pin": "1234"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var session models.StaffSession
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+		return session.Token
+	}
+
+	cashier := newStaff("CASHIER", models.RoleCashier, nil)
+	manager := newStaff("MANAGER", models.RoleStoreManager, &storeIDs[0])
+
+	t.Run("store manager needs a store", func(t *testing.T) {
+		w := request(http.MethodPost, "/api/staffs", "", map[string]interface{}{
+			"name": "No Store", "role": models.RoleStoreManager,
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = request(http.MethodPost, "/api/staffs", "", map[string]interface{}{
+			"name": "Bad Role", "role": "owner",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("cashier sells but does not manage items", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/items", cashier, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = request(http.MethodPost, "/api/items", cashier, map[string]interface{}{"name": "Gum", "price": 50})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"permission_denied"`)
+		assert.Contains(t, w.Body.String(), `"permission":"items.manage"`)
+
+		w = request(http.MethodGet, "/api/sales", cashier, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = request(http.MethodPost, "/api/sales", cashier, map[string]interface{}{
+			"storeId": storeIDs[1],
+			"deposit": 100,
+			"details": []map[string]interface{}{{"itemId": otherItemID, "quantity": 1}},
+		})
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("store manager manages their own store", func(t *testing.T) {
+		w := request(http.MethodPost, "/api/items", manager, map[string]interface{}{"name": "Gum", "price": 50})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var item models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		require.NotNil(t, item.StoreID)
+		assert.Equal(t, int(storeIDs[0]), *item.StoreID)
+
+		w = request(http.MethodPut, fmt.Sprintf("/api/items/%d", item.ID), manager, map[string]interface{}{"name": "Mint Gum", "price": 60})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = request(http.MethodPut, fmt.Sprintf("/api/items/%d", otherItemID), manager, map[string]interface{}{"name": "Mine", "price": 1})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"store_not_allowed"`)
+
+		w = request(http.MethodDelete, fmt.Sprintf("/api/items/%d", otherItemID), manager, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = request(http.MethodPost, "/api/stores", manager, map[string]interface{}{"name": "New Store"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"permission":"stores.manage"`)
+	})
+
+	t.Run("store manager sees and reverses their own store's sales", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/sales", manager, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var sales []models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sales))
+		assert.Empty(t, sales)
+
+		w = request(http.MethodGet, "/api/sales", "", nil)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sales))
+		require.Len(t, sales, 1)
+
+		w = request(http.MethodPost, fmt.Sprintf("/api/sales/%d/void", sales[0].ID), manager, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"store_not_allowed"`)
+	})
+
+	t.Run("store manager cannot read another store's sale", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/sales", "", nil)
+		var sales []models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sales))
+		require.Len(t, sales, 1)
+		require.Equal(t, int(storeIDs[1]), sales[0].StoreID)
+
+		for _, path := range []string{"/api/sales/%d", "/api/sales/%d/receipt", "/api/sales/%d/print"} {
+			method := http.MethodGet
+			if strings.HasSuffix(path, "/print") {
+				method = http.MethodPost
+			}
+			w := request(method, fmt.Sprintf(path, sales[0].ID), manager, nil)
+			assert.Equal(t, http.StatusForbidden, w.Code, path)
+			assert.Contains(t, w.Body.String(), `"code":"store_not_allowed"`, path)
+		}
+
+		// The cashier works at every store
+		w = request(http.MethodGet, fmt.Sprintf("/api/sales/%d", sales[0].ID), cashier, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestAPIDevices(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	request := func(method, path string, headers map[string]string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPost, "/api/devices/register", nil, map[string]string{"name": "Register 1", "appVersion": "1.0.0"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var device models.Device
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &device))
+	assert.NotEmpty(t, device.Token)
+	assert.Equal(t, models.DevicePending, device.Status)
+	token := map[string]string{deviceTokenHeader: device.Token}
+
+	t.Run("pending device waits for approval", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/devices/me", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"pending"`)
+		assert.NotContains(t, w.Body.String(), device.Token)
+
+		w = request(http.MethodGet, "/api/items", token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"device_not_approved"`)
+	})
+
+	t.Run("approved device sells and is seen", func(t *testing.T) {
+		w := request(http.MethodPost, fmt.Sprintf("/api/devices/%d/approve", device.ID), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		headers := map[string]string{deviceTokenHeader: device.Token, appVersionHeader: "1.1.0"}
+		w = request(http.MethodPost, "/api/sales", headers, map[string]interface{}{
+			"storeId":  storeID,
+			"deposit":  100,
+			"deviceId": 999,
+			"details":  []map[string]interface{}{{"itemId": itemID, "quantity": 1}},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var sale models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sale))
+		require.NotNil(t, sale.DeviceID)
+		assert.Equal(t, device.ID, *sale.DeviceID)
+
+		w = request(http.MethodGet, "/api/devices", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var devices []models.Device
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
+		require.Len(t, devices, 1)
+		assert.Equal(t, models.DeviceApproved, devices[0].Status)
+		assert.Equal(t, "1.1.0", devices[0].AppVersion)
+		assert.NotNil(t, devices[0].LastSeenAt)
+		assert.Empty(t, devices[0].Token)
+	})
+
+	t.Run("revoked device is rejected", func(t *testing.T) {
+		w := request(http.MethodPost, fmt.Sprintf("/api/devices/%d/revoke", device.ID), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = request(http.MethodGet, "/api/items", token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"device_revoked"`)
+
+		w = request(http.MethodPost, fmt.Sprintf("/api/devices/%d/approve", device.ID), nil, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/items", map[string]string{deviceTokenHeader: "bogus"}, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_device_token"`)
+	})
+
+	t.Run("devices can be required", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+
+		cfg := config.New()
+		cfg.RequireDevice = true
+		router := setupTestRouterWithConfig(db, cfg)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/items", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"device_required"`)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/apk/version/all", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The web UI's cookie is no device either
+		form := strings.NewReader("staffId=" + testStaffID + "&pin=1234")
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/login", form)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+
+		withCookie := func(method, path string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Basic none")
+			req.AddCookie(cookies[0])
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w = withCookie(http.MethodPost, "/api/sales")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"device_required"`)
+
+		// The admin pages and admin API go on working
+		w = withCookie(http.MethodGet, "/api/sales")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = withCookie(http.MethodPost, "/sales/999/print")
+		assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+		w = withCookie(http.MethodGet, "/api/sales/999/receipt")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = withCookie(http.MethodGet, "/sales/999/receipt?format=pdf")
+		assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	})
+}
+
+func TestAPIAudit(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	result, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+	_, err = db.Exec("INSERT INTO setting (key, value, type, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?)",
+		"taxRate", "10", "number", time.Now(), time.Now())
+	require.NoError(t, err)
+
+	request := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPut, fmt.Sprintf("/api/items/%d", itemID), map[string]interface{}{
+		"itemId": "ITEM-001",
+		"name":   "Candy",
+		"price":  120,
+		"stock":  10,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = request(http.MethodPut, "/api/settings/taxRate", map[string]string{"value": "8"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("records the change with its actor", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/audit?entity=item", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var entries []struct {
+			models.AuditEntry
+			Before models.Item `json:"before"`
+			After  models.Item `json:"after"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		require.Len(t, entries, 1)
+		assert.Equal(t, fmt.Sprint(itemID), entries[0].EntityID)
+		assert.Equal(t, models.AuditUpdate, entries[0].Action)
+		assert.Equal(t, "Test Login", entries[0].StaffName)
+		assert.NotNil(t, entries[0].StaffID)
+		assert.Equal(t, 100, entries[0].Before.Price)
+		assert.Equal(t, 120, entries[0].After.Price)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/audit", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var entries []models.AuditEntry
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		require.Len(t, entries, 3)
+		assert.Equal(t, models.AuditSetting, entries[0].Entity, "newest first")
+
+		w = request(http.MethodGet, "/api/audit?entity=setting&entityId=taxRate&limit=1", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		require.Len(t, entries, 1)
+		assert.Contains(t, string(entries[0].Before), `"value":"10"`)
+		assert.Contains(t, string(entries[0].After), `"value":"8"`)
+
+		w = request(http.MethodGet, "/api/audit?action=delete", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+
+		w = request(http.MethodGet, "/api/audit?from=2024-01-02&to=2023-12-31", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = request(http.MethodGet, "/api/audit?staffId=abc", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("admins only", func(t *testing.T) {
+		services := service.NewServices(repository.NewRepositories(db), config.New())
+		require.NoError(t, services.Staff.CreateStaff(models.Actor{}, &models.Staff{StaffID: "CASHIER", Name: "Cashier", PIN: "1234"}))
+		session, err := services.Auth.Login("CASHIER", "1234", "", models.SessionAPI)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/audit", nil)
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAPIBackup(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := config.New()
+	cfg.BackupDir = t.TempDir()
+	router := setupTestRouterWithConfig(db, cfg)
+
+	t.Run("downloads a fresh backup", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/backup", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "kidspos-")
+		assert.True(t, encrypt.IsEncrypted(w.Body.Bytes()))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/admin/backups", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var backups []models.Backup
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &backups))
+		require.Len(t, backups, 1)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/admin/backups/"+backups[0].Name, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/admin/backups/kidspos-missing.db", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("rejects a restore that is not a backup", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", "notes.txt")
+		require.NoError(t, err)
+		part.Write([]byte("hello"))
+		require.NoError(t, writer.Close())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/restore", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "not a SQLite database")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/api/admin/restore", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAPIExportImport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := config.New()
+	router := setupTestRouterWithConfig(db, cfg)
+
+	_, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+
+	var plain []byte
+	t.Run("exports the catalog", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/export?encrypt=false", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".json")
+
+		var doc models.Export
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Equal(t, models.ExportVersion, doc.Version)
+		require.Len(t, doc.Items, 1)
+		assert.Equal(t, "ITEM-001", doc.Items[0].ItemID)
+		plain = w.Body.Bytes()
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/admin/export?sales=true", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".json.enc")
+		assert.True(t, encrypt.IsEncrypted(w.Body.Bytes()))
+	})
+
+	t.Run("imports a changed catalog", func(t *testing.T) {
+		changed := bytes.Replace(plain, []byte(`"price": 100`), []byte(`"price": 120`), 1)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/import?mode=merge", bytes.NewReader(changed))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result models.ImportResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, 1, result.Items.Skipped)
+		require.Len(t, result.Conflicts, 1)
+		assert.Equal(t, []string{"price"}, result.Conflicts[0].Fields)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", "export.json")
+		require.NoError(t, err)
+		part.Write(changed)
+		require.NoError(t, writer.Close())
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/api/admin/import?mode=replace", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, 1, result.Items.Updated)
+
+		var price int
+		require.NoError(t, db.QueryRow("SELECT price FROM item WHERE itemId = 'ITEM-001'").Scan(&price))
+		assert.Equal(t, 120, price)
+	})
+
+	t.Run("rejects what is not an export", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/import", strings.NewReader(`{"items": []}`))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "not a KidsPOS export")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/api/admin/import?mode=overwrite", bytes.NewReader(plain))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 func TestAPIStaffsList(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -119,8 +722,11 @@ func TestAPIStaffsList(t *testing.T) {
 		var staffs []*models.Staff
 		err := json.Unmarshal(w.Body.Bytes(), &staffs)
 		require.NoError(t, err)
-		assert.Len(t, staffs, 2)
+		assert.Len(t, staffs, 3) // with the logged in test staff
 		assert.Equal(t, "Test Staff 2", staffs[0].Name) // DESC order
+		assert.False(t, staffs[0].HasPIN)
+		assert.True(t, staffs[2].HasPIN)
+		assert.NotContains(t, w.Body.String(), "pinHash")
 	})
 }
 
@@ -368,6 +974,1201 @@ func TestAPIStoresDelete(t *testing.T) {
 	})
 }
 
+// Sale API Tests
+func TestAPIItemsGetByCode(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-ab12cd34", "Toy Car", 300, 5, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+	_, err = db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-other", "Ball", 100, 5, time.Now(), time.Now())
+	require.NoError(t, err)
+
+	do := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(data)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, reader)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("by itemId", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/items/code/ITEM-ab12cd34", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var item models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		assert.Equal(t, int(itemID), item.ID)
+		assert.Equal(t, "Toy Car", item.Name)
+	})
+
+	t.Run("by alias", func(t *testing.T) {
+		w := do(http.MethodPost, fmt.Sprintf("/api/items/%d/aliases", itemID), map[string]string{"code": " 4901234567894 "})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var item models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		assert.Equal(t, []string{"4901234567894"}, item.Aliases)
+
+		w = do(http.MethodGet, "/api/items/code/4901234567894", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		assert.Equal(t, int(itemID), item.ID)
+		assert.Equal(t, []string{"4901234567894"}, item.Aliases)
+
+		// The catalog carries the aliases too
+		w = do(http.MethodGet, "/api/items", nil)
+		var items []models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+		require.Len(t, items, 2)
+		assert.Equal(t, []string{"4901234567894"}, items[1].Aliases)
+	})
+
+	t.Run("codes must be unique", func(t *testing.T) {
+		w := do(http.MethodPost, fmt.Sprintf("/api/items/%d/aliases", itemID), map[string]string{"code": "ITEM-other"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do(http.MethodPost, fmt.Sprintf("/api/items/%d/aliases", itemID), map[string]string{"code": "4901234567894"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do(http.MethodPost, "/api/items", map[string]interface{}{"itemId": "4901234567894", "name": "Copy", "price": 100})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("delete alias", func(t *testing.T) {
+		w := do(http.MethodDelete, fmt.Sprintf("/api/items/%d/aliases/4901234567894", itemID), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do(http.MethodGet, "/api/items/code/4901234567894", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = do(http.MethodDelete, fmt.Sprintf("/api/items/%d/aliases/4901234567894", itemID), nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/items/code/ITEM-missing", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/items/999/aliases", map[string]string{"code": "x"}).Code)
+	})
+
+	t.Run("deleted items no longer resolve", func(t *testing.T) {
+		w := do(http.MethodDelete, fmt.Sprintf("/api/items/%d", itemID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/items/code/ITEM-ab12cd34", nil).Code)
+	})
+}
+
+func TestAPIItemsCodes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := config.New()
+	cfg.QRCodeSize = 200
+	router := setupTestRouterWithConfig(db, cfg)
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("qr code", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/items/%d/qr.png", itemID))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		img, err := png.Decode(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 200, img.Bounds().Dx())
+		assert.Equal(t, 200, img.Bounds().Dy())
+	})
+
+	t.Run("barcode", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/items/%d/barcode.png", itemID))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		img, err := png.Decode(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 200, img.Bounds().Dx())
+		assert.Equal(t, 100, img.Bounds().Dy())
+	})
+
+	t.Run("item not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/api/items/999/qr.png").Code)
+		assert.Equal(t, http.StatusNotFound, get("/api/items/999/barcode.png").Code)
+	})
+}
+
+func TestAPIItemsLabels(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("all items", func(t *testing.T) {
+		w := get("/api/items/labels.pdf")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+	})
+
+	t.Run("chosen items with a custom grid", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/items/labels.pdf?ids=%d&layout=a4-44&columns=2&rows=2&marginTop=10&copies=5", itemID))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		// 5 labels on sheets of 4
+		assert.Contains(t, w.Body.String(), "/Count 2")
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/items/labels.pdf?layout=a4-99").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/api/items/labels.pdf?ids=abc").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/api/items/labels.pdf?ids=999").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/api/items/labels.pdf?columns=40").Code)
+	})
+}
+
+func TestAPISalesGet(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	saleResult, err := db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)",
+		staffID, storeID, 300, 500, time.Now())
+	require.NoError(t, err)
+	saleID, _ := saleResult.LastInsertId()
+
+	_, err = db.Exec("INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (?, ?, ?, ?)",
+		saleID, itemID, 3, 100)
+	require.NoError(t, err)
+
+	t.Run("get existing sale with details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/sales/%d", saleID), nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var sale models.Sale
+		err := json.Unmarshal(w.Body.Bytes(), &sale)
+		require.NoError(t, err)
+		assert.Equal(t, 300, sale.TotalPrice)
+		require.NotNil(t, sale.Store)
+		assert.Equal(t, "Test Store", sale.Store.Name)
+		require.NotNil(t, sale.Staff)
+		assert.Equal(t, "Test Staff", sale.Staff.Name)
+		require.Len(t, sale.Details, 1)
+		assert.Equal(t, 3, sale.Details[0].Quantity)
+		require.NotNil(t, sale.Details[0].Item)
+		assert.Equal(t, "Candy", sale.Details[0].Item.Name)
+	})
+
+	t.Run("get non-existent sale", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/sales/999", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("invalid sale ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/sales/abc", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAPISalesCreate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 2, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	postSale := func(quantity int) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"storeId": storeID,
+			"staffId": staffID,
+			"deposit": 1000,
+			"details": []map[string]interface{}{{"itemId": itemID, "quantity": quantity}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("create sale successfully", func(t *testing.T) {
+		w := postSale(1)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var sale models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sale))
+		assert.Equal(t, 100, sale.TotalPrice)
+		assert.Equal(t, 900, sale.Change)
+
+		// The sale belongs to the logged in staff, not the one in the body
+		var staffCode string
+		require.NoError(t, db.QueryRow("SELECT st.staffId FROM sale s JOIN staff st ON st.id = s.staffId WHERE s.id = ?", sale.ID).Scan(&staffCode))
+		assert.Equal(t, testStaffID, staffCode)
+		assert.Equal(t, []models.ChangeCount{
+			{Denomination: models.Denomination{Value: 500, Kind: "coin"}, Count: 1},
+			{Denomination: models.Denomination{Value: 100, Kind: "coin"}, Count: 4},
+		}, sale.ChangeBreakdown)
+
+		var change int
+		require.NoError(t, db.QueryRow("SELECT change FROM sale WHERE id = ?", sale.ID).Scan(&change))
+		assert.Equal(t, 900, change)
+	})
+
+	t.Run("insufficient stock", func(t *testing.T) {
+		w := postSale(5)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "insufficient_stock", response["code"])
+		assert.Equal(t, "Candy", response["itemName"])
+		assert.Equal(t, float64(5), response["requested"])
+		assert.Equal(t, float64(1), response["available"])
+	})
+
+	t.Run("non-positive quantity", func(t *testing.T) {
+		w := postSale(-1)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	postDetail := func(detail map[string]interface{}) *httptest.ResponseRecorder {
+		detail["itemId"] = itemID
+		detail["quantity"] = 1
+		body, _ := json.Marshal(map[string]interface{}{
+			"storeId": storeID,
+			"staffId": staffID,
+			"deposit": 1000,
+			"details": []map[string]interface{}{detail},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("deposit below total - should fail", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"storeId": storeID,
+			"staffId": staffID,
+			"deposit": 50,
+			"details": []map[string]interface{}{{"itemId": itemID, "quantity": 1}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "does not cover total")
+	})
+
+	t.Run("client price is ignored", func(t *testing.T) {
+		_, err := db.Exec("UPDATE item SET stock = 10 WHERE id = ?", itemID)
+		require.NoError(t, err)
+
+		w := postDetail(map[string]interface{}{"price": 1})
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var sale models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sale))
+		assert.Equal(t, 100, sale.TotalPrice)
+		require.Len(t, sale.Details, 1)
+		assert.Equal(t, 100, sale.Details[0].Price)
+		assert.False(t, sale.Details[0].PriceOverridden)
+	})
+
+	t.Run("override without reason - should fail", func(t *testing.T) {
+		w := postDetail(map[string]interface{}{"overridePrice": 50})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("override with reason is recorded", func(t *testing.T) {
+		w := postDetail(map[string]interface{}{"overridePrice": 50, "overrideReason": "time sale"})
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var sale models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sale))
+		assert.Equal(t, 50, sale.TotalPrice)
+
+		var price, listPrice int
+		var overridden bool
+		var reason string
+		err := db.QueryRow("SELECT price, listPrice, priceOverridden, overrideReason FROM sale_detail WHERE saleId = ?", sale.ID).
+			Scan(&price, &listPrice, &overridden, &reason)
+		require.NoError(t, err)
+		assert.Equal(t, 50, price)
+		assert.Equal(t, 100, listPrice)
+		assert.True(t, overridden)
+		assert.Equal(t, "time sale", reason)
+	})
+}
+
+func TestAPISalesCreateTax(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	_, err = db.Exec("INSERT INTO setting (key, value, type) VALUES ('taxRate', '10', 'number'), ('taxRounding', 'floor', 'string')")
+	require.NoError(t, err)
+
+	inclusiveResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 110, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	inclusiveID, _ := inclusiveResult.LastInsertId()
+
+	exclusiveResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, taxExcluded, createdAt, updatedAt) VALUES (?, ?, ?, ?, 1, ?, ?)",
+		"ITEM-002", "Pencil", 105, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	exclusiveID, _ := exclusiveResult.LastInsertId()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"storeId": storeID,
+		"staffId": staffID,
+		"deposit": 300,
+		"details": []map[string]interface{}{
+			{"itemId": inclusiveID, "quantity": 1},
+			{"itemId": exclusiveID, "quantity": 1},
+		},
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/sales", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var sale models.Sale
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sale))
+	// 110 includes 10 tax, 105 gets 10.5 tax floored to 10
+	assert.Equal(t, 205, sale.Subtotal)
+	assert.Equal(t, 20, sale.Tax)
+	assert.Equal(t, 225, sale.TotalPrice)
+	assert.Equal(t, 75, sale.Change)
+
+	var subtotal, tax, total int
+	err = db.QueryRow("SELECT subtotal, tax, totalPrice FROM sale WHERE id = ?", sale.ID).Scan(&subtotal, &tax, &total)
+	require.NoError(t, err)
+	assert.Equal(t, 205, subtotal)
+	assert.Equal(t, 20, tax)
+	assert.Equal(t, 225, total)
+}
+
+func TestAPISalesPrint(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// A fake printer that records what it is sent
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Close()
+			received <- data
+		}
+	}()
+
+	cfg := config.New()
+	cfg.ReceiptPrinterHost, cfg.ReceiptPrinterPort, err = net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	cfg.AdminAllowedIPs, cfg.RegisterAllowedIPs = "*", "*"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	services := service.NewServices(repository.NewRepositories(db), cfg)
+	loginTestStaff(router, services)
+	SetupRoutes(router, NewHandlers(services, cfg))
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	_, err = db.Exec("INSERT INTO setting (key, value, type) VALUES ('shopName', 'Kids Shop', 'string'), ('receiptFooter', 'Thank you!', 'string')")
+	require.NoError(t, err)
+
+	saleResult, err := db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, change, saleAt) VALUES (?, ?, ?, ?, ?, ?)",
+		staffID, storeID, 100, 500, 400, time.Now())
+	require.NoError(t, err)
+	saleID, _ := saleResult.LastInsertId()
+
+	t.Run("print receipt", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/print", saleID), nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		var job models.PrintJob
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, models.PrintJobQueued, job.Status)
+
+		require.NoError(t, services.PrintQueue.ProcessDue(time.Now()))
+
+		select {
+		case data := <-received:
+			assert.Contains(t, string(data), "Kids Shop")
+			assert.Contains(t, string(data), "Thank you!")
+			assert.Contains(t, string(data), `\400`)
+		case <-time.After(time.Second):
+			t.Fatal("printer received nothing")
+		}
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/print-jobs?status=done", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var jobs []models.PrintJob
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobs))
+		require.Len(t, jobs, 1)
+		assert.Equal(t, job.ID, jobs[0].ID)
+		assert.NotNil(t, jobs[0].PrintedAt)
+	})
+
+	t.Run("print automatically after a sale", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO setting (key, value, type) VALUES ('autoPrintReceipt', 'true', 'boolean')")
+		require.NoError(t, err)
+		itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+			"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+		require.NoError(t, err)
+		itemID, _ := itemResult.LastInsertId()
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"storeId": storeID,
+			"staffId": staffID,
+			"deposit": 100,
+			"details": []map[string]interface{}{{"itemId": itemID, "quantity": 1}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, services.PrintQueue.ProcessDue(time.Now()))
+
+		select {
+		case data := <-received:
+			assert.Contains(t, string(data), "Candy")
+		case <-time.After(time.Second):
+			t.Fatal("printer received nothing")
+		}
+	})
+
+	t.Run("sale not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales/999/print", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("printer offline keeps the job queued", func(t *testing.T) {
+		listener.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/print", saleID), nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		require.NoError(t, services.PrintQueue.ProcessDue(time.Now()))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/printers", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var statuses []models.PrinterStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+		require.Len(t, statuses, 1)
+		assert.Equal(t, 1, statuses[0].Queued)
+		assert.Contains(t, statuses[0].LastError, "not reachable")
+		assert.NotNil(t, statuses[0].LastPrintedAt)
+	})
+
+	t.Run("reprint", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/print-jobs/1/reprint", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var job models.PrintJob
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, models.PrintJobQueued, job.Status)
+		assert.Equal(t, int(saleID), *job.SaleID)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/api/print-jobs/999/reprint", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestAPISalesReceipt(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	_, err = db.Exec(`INSERT INTO setting (key, value, type) VALUES ('shopName', 'Kids Shop', 'string'),
+		('receiptFooter', 'Thank you!', 'string'), ('currency', 'USD', 'string')`)
+	require.NoError(t, err)
+
+	saleResult, err := db.Exec("INSERT INTO sale (staffId, storeId, subtotal, totalPrice, deposit, change, saleAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		staffID, storeID, 250, 250, 500, 250, time.Now())
+	require.NoError(t, err)
+	saleID, _ := saleResult.LastInsertId()
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("html by default", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/sales/%d/receipt", saleID))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "Kids Shop")
+		assert.Contains(t, w.Body.String(), "Thank you!")
+		assert.Contains(t, w.Body.String(), "$2.50")
+	})
+
+	t.Run("plain text", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/sales/%d/receipt?format=txt", saleID))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "Kids Shop")
+		assert.Contains(t, w.Body.String(), "$2.50")
+	})
+
+	t.Run("pdf", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/sales/%d/receipt?format=pdf", saleID))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/sales/%d/receipt?format=docx", saleID))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("sale not found", func(t *testing.T) {
+		w := get("/api/sales/999/receipt")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestAPISalesCreateIdempotent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 1, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	postSale := func(clientID, key string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"clientId": clientID,
+			"storeId":  storeID,
+			"staffId":  staffID,
+			"deposit":  100,
+			"details":  []map[string]interface{}{{"itemId": itemID, "quantity": 1}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var original models.Sale
+	t.Run("first request creates the sale", func(t *testing.T) {
+		w := postSale("", "key-0001")
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &original))
+	})
+
+	t.Run("retry returns the original sale", func(t *testing.T) {
+		// The only item is sold, so a second sale would fail on stock
+		w := postSale("", "key-0001")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+
+		var sale models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sale))
+		assert.Equal(t, original.ID, sale.ID)
+		assert.Equal(t, "key-0001", sale.ClientID)
+
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sale").Scan(&count))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("client ID in body works like the header", func(t *testing.T) {
+		w := postSale("key-0001", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("mismatching header and client ID", func(t *testing.T) {
+		w := postSale("key-0002", "key-0003")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAPISalesBatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 2, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	soldAt := time.Date(2024, 7, 20, 10, 30, 0, 0, time.Local)
+	offlineSale := func(clientID string, quantity int) map[string]interface{} {
+		return map[string]interface{}{
+			"clientId": clientID,
+			"storeId":  storeID,
+			"staffId":  staffID,
+			"deposit":  1000,
+			"saleAt":   soldAt,
+			"details":  []map[string]interface{}{{"itemId": itemID, "quantity": quantity}},
+		}
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"sales": []map[string]interface{}{
+			offlineSale("offline-1", 1),
+			offlineSale("offline-1", 1),
+			offlineSale("", 1),
+			offlineSale("offline-2", 5),
+			offlineSale("offline-3", 1),
+		},
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/sales/batch", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Results    []models.BatchSaleResult `json:"results"`
+		Created    int                      `json:"created"`
+		Duplicates int                      `json:"duplicates"`
+		Rejected   int                      `json:"rejected"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Results, 5)
+
+	assert.Equal(t, models.BatchSaleCreated, response.Results[0].Status)
+	assert.Equal(t, models.BatchSaleDuplicate, response.Results[1].Status)
+	assert.Equal(t, response.Results[0].SaleID, response.Results[1].SaleID)
+	assert.Equal(t, models.BatchSaleRejected, response.Results[2].Status)
+	assert.Equal(t, "client ID is required", response.Results[2].Reason)
+	assert.Equal(t, models.BatchSaleRejected, response.Results[3].Status)
+	assert.Contains(t, response.Results[3].Reason, "insufficient stock")
+	assert.Equal(t, models.BatchSaleCreated, response.Results[4].Status)
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 1, response.Duplicates)
+	assert.Equal(t, 2, response.Rejected)
+
+	// The original sale time is kept
+	var saleAt time.Time
+	require.NoError(t, db.QueryRow("SELECT saleAt FROM sale WHERE id = ?", response.Results[0].SaleID).Scan(&saleAt))
+	assert.True(t, soldAt.Equal(saleAt))
+
+	t.Run("a time sent in UTC counts for the local day", func(t *testing.T) {
+		// Half past midnight local time, which is still the day before in UTC
+		// east of Greenwich
+		soldAt := time.Date(2024, 7, 21, 0, 30, 0, 0, time.Local)
+		_, err := db.Exec("UPDATE item SET stock = 1 WHERE id = ?", itemID)
+		require.NoError(t, err)
+		body := fmt.Sprintf(`{"sales": [{"clientId": "offline-utc", "storeId": %d, "staffId": %d, "deposit": 1000,
+			"saleAt": %q, "details": [{"itemId": %d, "quantity": 1}]}]}`,
+			storeID, staffID, soldAt.UTC().Format(time.RFC3339), itemID)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales/batch", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, models.BatchSaleCreated, response.Results[0].Status, response.Results[0].Reason)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/sales?from=2024-07-21&to=2024-07-21", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var sales []models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sales))
+		require.Len(t, sales, 1)
+		assert.Equal(t, response.Results[0].SaleID, sales[0].ID)
+		assert.True(t, soldAt.Equal(sales[0].SaleAt))
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/sales/batch", bytes.NewBufferString(`{"sales": []}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAPISalesVoidAndRefund(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 7, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	createSale := func() (int64, int64) {
+		saleResult, err := db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)",
+			staffID, storeID, 300, 300, time.Now())
+		require.NoError(t, err)
+		saleID, _ := saleResult.LastInsertId()
+
+		detailResult, err := db.Exec("INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (?, ?, ?, ?)",
+			saleID, itemID, 3, 100)
+		require.NoError(t, err)
+		detailID, _ := detailResult.LastInsertId()
+
+		return saleID, detailID
+	}
+
+	stock := func() int {
+		var stock int
+		err := db.QueryRow("SELECT stock FROM item WHERE id = ?", itemID).Scan(&stock)
+		require.NoError(t, err)
+		return stock
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/devices/register", bytes.NewBufferString(`{"name": "Register 1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var device models.Device
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &device))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/api/devices/%d/approve", device.ID), nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("partial refund then void the rest", func(t *testing.T) {
+		saleID, detailID := createSale()
+		before := stock()
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"reason":  "wrong item",
+			"details": []map[string]interface{}{{"detailId": detailID, "quantity": 1}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/refund", saleID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var refund models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refund))
+		assert.Equal(t, models.SaleTypeRefund, refund.Type)
+		assert.Equal(t, -100, refund.TotalPrice)
+		require.NotNil(t, refund.OriginalSaleID)
+		assert.Equal(t, int(saleID), *refund.OriginalSaleID)
+		assert.Nil(t, refund.DeviceID)
+		assert.Equal(t, before+1, stock())
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/void", saleID), nil)
+		req.Header.Set(deviceTokenHeader, device.Token)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var void models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &void))
+		assert.Equal(t, models.SaleTypeVoid, void.Type)
+		assert.Equal(t, -200, void.TotalPrice)
+		require.NotNil(t, void.DeviceID)
+		assert.Equal(t, device.ID, *void.DeviceID)
+		assert.Equal(t, before+3, stock())
+
+		// The original sale is kept untouched
+		var total int
+		err := db.QueryRow("SELECT totalPrice FROM sale WHERE id = ?", saleID).Scan(&total)
+		require.NoError(t, err)
+		assert.Equal(t, 300, total)
+	})
+
+	t.Run("void twice - should fail", func(t *testing.T) {
+		saleID, _ := createSale()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/void", saleID), nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		before := stock()
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/void", saleID), nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, before, stock())
+	})
+
+	t.Run("refund more than sold - should fail", func(t *testing.T) {
+		saleID, detailID := createSale()
+		before := stock()
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"details": []map[string]interface{}{{"detailId": detailID, "quantity": 4}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/sales/%d/refund", saleID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, before, stock())
+	})
+
+	t.Run("reversals show up as negative amounts in reports", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/reports/sales", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		// 3 sales of 300, one fully reversed in two steps, one voided
+		assert.Equal(t, float64(3), response["totalSales"])
+		assert.Equal(t, float64(3), response["totalRefunds"])
+		assert.Equal(t, float64(-600), response["refundAmount"])
+		assert.Equal(t, float64(300), response["totalAmount"])
+	})
+}
+
+func TestAPIReportsSales(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	otherStoreResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-002", "Other Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	otherStoreID, _ := otherStoreResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	day := time.Date(2025, 10, 1, 10, 0, 0, 0, time.Local)
+	_, err = db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)",
+		staffID, storeID, 100, 100, day)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)",
+		staffID, otherStoreID, 200, 200, day)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)",
+		staffID, storeID, 400, 400, day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+
+	t.Run("filter by date range and store", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/reports/sales?from=2025-10-01&to=2025-10-01&storeId=%d", storeID)
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), response["totalSales"])
+		assert.Equal(t, float64(100), response["totalAmount"])
+	})
+
+	t.Run("sales list accepts the same filter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/sales?from=2025-10-01&to=2025-10-02", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var sales []*models.Sale
+		err := json.Unmarshal(w.Body.Bytes(), &sales)
+		require.NoError(t, err)
+		assert.Len(t, sales, 2)
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/reports/sales?from=yesterday", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("end before start", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/reports/sales?from=2025-10-05&to=2025-10-01", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAPIReportsSalesExcel(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := setupTestRouter(db)
+
+	// Insert test data
+	storeResult, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STORE-001", "Test Store", time.Now(), time.Now())
+	require.NoError(t, err)
+	storeID, _ := storeResult.LastInsertId()
+
+	staffResult, err := db.Exec("INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+		"STAFF-001", "Test Staff", time.Now(), time.Now())
+	require.NoError(t, err)
+	staffID, _ := staffResult.LastInsertId()
+
+	itemResult, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	itemID, _ := itemResult.LastInsertId()
+
+	saleResult, err := db.Exec("INSERT INTO sale (staffId, storeId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)",
+		staffID, storeID, 300, 500, time.Now())
+	require.NoError(t, err)
+	saleID, _ := saleResult.LastInsertId()
+
+	_, err = db.Exec("INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (?, ?, ?, ?)",
+		saleID, itemID, 3, 100)
+	require.NoError(t, err)
+
+	t.Run("download workbook", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/reports/sales/excel", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".xlsx")
+
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		require.NoError(t, err)
+
+		sheets := 0
+		for _, f := range zr.File {
+			if strings.HasPrefix(f.Name, "xl/worksheets/") {
+				sheets++
+			}
+			if f.Name == "xl/worksheets/sheet3.xml" {
+				rc, err := f.Open()
+				require.NoError(t, err)
+				summary, err := io.ReadAll(rc)
+				rc.Close()
+				require.NoError(t, err)
+				assert.Contains(t, string(summary), "売上合計(税込)", "the summary states its tax basis")
+			}
+		}
+		assert.Equal(t, 3, sheets)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/reports/sales/excel?storeId=abc", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 // APK API Tests
 func TestAPIApkLatest(t *testing.T) {
 	db := setupTestDB(t)
@@ -160,7 +160,7 @@ func (h *Handlers) APIApkUpload(c *gin.Context) {
 	}
 
 	// Upload APK
-	apk, err := h.apkVersionService.UploadApk(file, version, versionCode, releaseNotes)
+	apk, err := h.apkVersionService.UploadApk(currentActor(c), file, version, versionCode, releaseNotes)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -177,7 +177,7 @@ func (h *Handlers) APIApkDelete(c *gin.Context) {
 		return
 	}
 
-	if err := h.apkVersionService.DeleteVersion(id); err != nil {
+	if err := h.apkVersionService.DeleteVersion(currentActor(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -193,7 +193,7 @@ func (h *Handlers) APIApkDeactivate(c *gin.Context) {
 		return
 	}
 
-	apk, err := h.apkVersionService.DeactivateVersion(id)
+	apk, err := h.apkVersionService.DeactivateVersion(currentActor(c), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
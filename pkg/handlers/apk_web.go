@@ -70,7 +70,7 @@ func (h *Handlers) ApkUpload(c *gin.Context) {
 	}
 
 	// Upload APK
-	_, err = h.apkVersionService.UploadApk(file, version, versionCode, releaseNotes)
+	_, err = h.apkVersionService.UploadApk(currentActor(c), file, version, versionCode, releaseNotes)
 	if err != nil {
 		c.HTML(http.StatusBadRequest, "apk/upload.html", gin.H{
 			"title": "Upload APK",
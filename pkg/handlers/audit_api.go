@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APIAuditList returns the newest audit log entries as JSON
+func (h *Handlers) APIAuditList(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.auditService.GetEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// parseAuditFilter reads the entity, entityId, action, staffId, deviceId,
+// from, to and limit query parameters. Dates are read like the sales
+// filter's.
+func parseAuditFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Entity:   c.Query("entity"),
+		EntityID: c.Query("entityId"),
+		Action:   c.Query("action"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseDateParam(from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %s", from)
+		}
+		filter.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseDateParam(to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %s", to)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = t
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"staffId", &filter.StaffID},
+		{"deviceId", &filter.DeviceID},
+		{"limit", &filter.Limit},
+	}
+	for _, param := range ints {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: %s", param.name, value)
+		}
+		*param.dst = n
+	}
+
+	return filter, nil
+}
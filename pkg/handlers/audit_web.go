@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditList displays the audit log page, filtered by the same query
+// parameters as the API
+func (h *Handlers) AuditList(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	entries, err := h.auditService.GetEntries(filter)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "audit/index.html", gin.H{
+		"title":   "Audit Log",
+		"entries": entries,
+		"query": gin.H{
+			"entity":   c.Query("entity"),
+			"entityId": c.Query("entityId"),
+			"action":   c.Query("action"),
+			"staffId":  c.Query("staffId"),
+			"deviceId": c.Query("deviceId"),
+			"from":     c.Query("from"),
+			"to":       c.Query("to"),
+		},
+		"entities": []string{
+			models.AuditItem, models.AuditStore, models.AuditStaff, models.AuditSetting,
+			models.AuditApk, models.AuditSale, models.AuditDevice, models.AuditDatabase,
+		},
+		"actions": []string{
+			models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditVoid,
+			models.AuditRefund, models.AuditDeactivate, models.AuditApprove, models.AuditRevoke,
+			models.AuditRestore, models.AuditImport,
+		},
+	})
+}
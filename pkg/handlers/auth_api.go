@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APIAuthLogin logs a staff member in and returns a bearer token
+func (h *Handlers) APIAuthLogin(c *gin.Context) {
+	var credentials struct {
+		StaffID string `json:"staffId" binding:"required"`
+		PIN     string `json:"pin" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.authService.Login(credentials.StaffID, credentials.PIN, c.ClientIP(), models.SessionAPI)
+	if err != nil {
+		status, code := loginFailure(c, credentials.StaffID, err)
+		response := gin.H{"error": err.Error()}
+		if code != "" {
+			response["code"] = code
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// APIAuthLogout ends the session of the request's token
+func (h *Handlers) APIAuthLogout(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		token, _ = c.Cookie(sessionCookie)
+	}
+	if err := h.authService.Logout(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// APIAuthMe returns the logged in staff member
+func (h *Handlers) APIAuthMe(c *gin.Context) {
+	c.JSON(http.StatusOK, currentSession(c))
+}
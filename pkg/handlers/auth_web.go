@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginPage displays the staff login form
+func (h *Handlers) LoginPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"title": "Login",
+		"next":  localPath(c.Query("next")),
+	})
+}
+
+// Login logs a staff member in to the web UI with a session cookie
+func (h *Handlers) Login(c *gin.Context) {
+	staffID := c.PostForm("staffId")
+	next := localPath(c.PostForm("next"))
+
+	session, err := h.authService.Login(staffID, c.PostForm("pin"), c.ClientIP(), models.SessionWeb)
+	if err != nil {
+		status, code := loginFailure(c, staffID, err)
+		message := err.Error()
+		switch code {
+		case "invalid_login":
+			message = "スタッフIDまたはPINが違います"
+		case "login_locked":
+			message = "ログインの試行回数が多すぎます。しばらくしてからやり直してください"
+		}
+		c.HTML(status, "login.html", gin.H{
+			"title":   "Login",
+			"error":   message,
+			"staffId": staffID,
+			"next":    next,
+		})
+		return
+	}
+
+	maxAge := int(time.Until(session.ExpiresAt).Seconds())
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, session.Token, maxAge, "/", "", secureRequest(c), true)
+	c.Redirect(http.StatusSeeOther, next)
+}
+
+// Logout ends the web UI session
+func (h *Handlers) Logout(c *gin.Context) {
+	if token, err := c.Cookie(sessionCookie); err == nil {
+		if err := h.authService.Logout(token); err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, "", -1, "/", "", secureRequest(c), true)
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// localPath keeps the page to return to after login on this server
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// secureRequest reports whether the browser reached us over HTTPS, directly
+// or through a proxy such as Vercel's
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
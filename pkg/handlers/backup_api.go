@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APIBackupDownload takes a backup of the database now and downloads it
+func (h *Handlers) APIBackupDownload(c *gin.Context) {
+	backup, err := h.backupService.CreateBackup()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.serveBackup(c, backup.Name)
+}
+
+// APIBackupsList returns the kept backups, newest first
+func (h *Handlers) APIBackupsList(c *gin.Context) {
+	backups, err := h.backupService.GetBackups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if backups == nil {
+		backups = []*models.Backup{}
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// APIBackupsDownload downloads a kept backup by its name
+func (h *Handlers) APIBackupsDownload(c *gin.Context) {
+	h.serveBackup(c, c.Param("name"))
+}
+
+// APIRestore replaces the database with an uploaded backup. The database
+// as it was is kept as a backup, which is returned. Devices and logins are
+// not restored, which the response says.
+func (h *Handlers) APIRestore(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	previous, err := h.backupService.Restore(currentActor(c), fileHeader.Filename, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Database restored successfully",
+		"devices":        "Devices and logins were kept as they are; devices only in the backup were revoked",
+		"previousBackup": previous,
+	})
+}
+
+func (h *Handlers) serveBackup(c *gin.Context, name string) {
+	path, err := h.backupService.GetBackupPath(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Header("Content-Type", "application/octet-stream")
+	c.File(path)
+}
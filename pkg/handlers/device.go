@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// deviceTokenHeader carries the token a register tablet got when it
+	// registered
+	deviceTokenHeader = "X-Device-Token"
+	// appVersionHeader carries the app version of the register tablet
+	appVersionHeader = "X-App-Version"
+	// deviceKey is where the device middleware leaves the device in the context
+	deviceKey = "device"
+)
+
+// IdentifyDevice finds the device of a request's device token and records
+// when, from where and with which app version it was seen. Requests without
+// a token pass through; unknown and revoked tokens are rejected.
+func (h *Handlers) IdentifyDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(deviceTokenHeader)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		device, err := h.deviceService.Authenticate(token, c.ClientIP(), c.GetHeader(appVersionHeader))
+		switch {
+		case errors.Is(err, models.ErrInvalidDeviceToken):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+				"code":  "invalid_device_token",
+			})
+			return
+		case errors.Is(err, models.ErrDeviceRevoked):
+			log.Printf("Rejected %s %s from revoked device at %q", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": err.Error(),
+				"code":  "device_revoked",
+			})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(deviceKey, device)
+		c.Next()
+	}
+}
+
+// RequireDevice rejects register API requests from devices that are not
+// approved yet. When devices are required, requests without a device are
+// rejected too, whoever is logged in; only the register routes use it, so
+// the admin pages and the admin API are not affected.
+func (h *Handlers) RequireDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		device := currentDevice(c)
+		if device != nil && device.Status != models.DeviceApproved {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "This device has not been approved yet",
+				"code":  "device_not_approved",
+			})
+			return
+		}
+
+		if device == nil && h.requireDevice {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Requests must come from a registered device",
+				"code":  "device_required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentDevice returns the device the device middleware found
+func currentDevice(c *gin.Context) *models.Device {
+	if value, ok := c.Get(deviceKey); ok {
+		return value.(*models.Device)
+	}
+	return nil
+}
+
+// currentDeviceID returns the ID of the request's device, if it has one
+func currentDeviceID(c *gin.Context) *int {
+	if device := currentDevice(c); device != nil {
+		id := device.ID
+		return &id
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APIDevicesRegister registers a register tablet. The tablet keeps the
+// returned token and sends it in the X-Device-Token header; it works once
+// an admin has approved the tablet.
+func (h *Handlers) APIDevicesRegister(c *gin.Context) {
+	var payload struct {
+		Name       string `json:"name" binding:"required"`
+		AppVersion string `json:"appVersion"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appVersion := payload.AppVersion
+	if appVersion == "" {
+		appVersion = c.GetHeader(appVersionHeader)
+	}
+	device, err := h.deviceService.Register(payload.Name, appVersion, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+// APIDevicesMe returns the device of the request's token, so that a pending
+// tablet can wait for its approval
+func (h *Handlers) APIDevicesMe(c *gin.Context) {
+	device := currentDevice(c)
+	if device == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Device token required",
+			"code":  "device_required",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// APIDevicesList returns all registered devices as JSON
+func (h *Handlers) APIDevicesList(c *gin.Context) {
+	devices, err := h.deviceService.GetAllDevices()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if devices == nil {
+		devices = []*models.Device{}
+	}
+	c.JSON(http.StatusOK, devices)
+}
+
+// APIDevicesApprove approves a pending device via API
+func (h *Handlers) APIDevicesApprove(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.deviceService.GetDevice(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	device, err := h.deviceService.Approve(currentActor(c), id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// APIDevicesRevoke revokes a device's token via API
+func (h *Handlers) APIDevicesRevoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.deviceService.GetDevice(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	device, err := h.deviceService.Revoke(currentActor(c), id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DevicesList displays the registered register tablets
+func (h *Handlers) DevicesList(c *gin.Context) {
+	devices, err := h.deviceService.GetAllDevices()
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "devices/index.html", gin.H{
+		"title":   "Devices",
+		"devices": devices,
+	})
+}
+
+// DevicesApprove approves a pending device from the web UI
+func (h *Handlers) DevicesApprove(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": "Invalid ID",
+		})
+		return
+	}
+
+	if _, err := h.deviceService.Approve(currentActor(c), id); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/devices")
+}
+
+// DevicesRevoke revokes a device from the web UI
+func (h *Handlers) DevicesRevoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": "Invalid ID",
+		})
+		return
+	}
+
+	if _, err := h.deviceService.Revoke(currentActor(c), id); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/devices")
+}
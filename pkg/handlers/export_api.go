@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIExport downloads the stores, items, staff and settings as a JSON
+// document, with the sales if sales=true. The document is encrypted like
+// backups unless encrypt=false.
+func (h *Handlers) APIExport(c *gin.Context) {
+	doc, err := h.exportService.Export(c.Query("sales") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	encrypted := c.Query("encrypt") != "false"
+	var body bytes.Buffer
+	if err := h.exportService.Encode(&body, doc, encrypted); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := "kidspos-export-" + doc.ExportedAt.Format("20060102-150405") + ".json"
+	contentType := "application/json"
+	if encrypted {
+		name += ".enc"
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Data(http.StatusOK, contentType, body.Bytes())
+}
+
+// APIImport loads an export document, sent as the request body or as the
+// multipart file "file". The mode query parameter is merge (the default)
+// or replace, and dryRun=true reports what would happen without changing
+// anything. Sales in the document are not imported; the response counts
+// them in salesSkipped.
+func (h *Handlers) APIImport(c *gin.Context) {
+	var input io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		input = file
+	}
+
+	result, err := h.exportService.Import(currentActor(c), input, c.Query("mode"), c.Query("dryRun") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
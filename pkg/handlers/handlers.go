@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/config"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/service"
 	"github.com/gin-gonic/gin"
@@ -15,20 +17,40 @@ type Handlers struct {
 	itemService       *service.ItemService
 	storeService      *service.StoreService
 	staffService      *service.StaffService
+	authService       *service.AuthService
+	deviceService     *service.DeviceService
 	saleService       *service.SaleService
 	settingService    *service.SettingService
+	receiptService    *service.ReceiptService
+	printQueueService *service.PrintQueueService
+	labelService      *service.LabelService
 	apkVersionService *service.ApkVersionService
+	auditService      *service.AuditService
+	backupService     *service.BackupService
+	exportService     *service.ExportService
+	network           *NetworkAccess
+	requireDevice     bool
 }
 
 // NewHandlers creates handler instances
-func NewHandlers(services *service.Services) *Handlers {
+func NewHandlers(services *service.Services, cfg *config.Config) *Handlers {
 	return &Handlers{
 		itemService:       services.Item,
 		storeService:      services.Store,
 		staffService:      services.Staff,
+		authService:       services.Auth,
+		deviceService:     services.Device,
 		saleService:       services.Sale,
 		settingService:    services.Setting,
+		receiptService:    services.Receipt,
+		printQueueService: services.PrintQueue,
+		labelService:      services.Label,
 		apkVersionService: services.ApkVersion,
+		auditService:      services.Audit,
+		backupService:     services.Backup,
+		exportService:     services.Export,
+		network:           NewNetworkAccess(cfg),
+		requireDevice:     cfg.RequireDevice,
 	}
 }
 
@@ -65,12 +87,17 @@ func (h *Handlers) ItemsNew(c *gin.Context) {
 // ItemsCreate creates a new item
 func (h *Handlers) ItemsCreate(c *gin.Context) {
 	item := &models.Item{
-		Name:  c.PostForm("name"),
-		Price: atoi(c.PostForm("price")),
-		Stock: atoi(c.PostForm("stock")),
+		Name:        c.PostForm("name"),
+		Price:       atoi(c.PostForm("price")),
+		Stock:       atoi(c.PostForm("stock")),
+		TaxExcluded: c.PostForm("taxExcluded") == "on",
+	}
+	// Store managers add items to their own store
+	if scope := storeScope(c); scope != 0 {
+		item.StoreID = &scope
 	}
 
-	if err := h.itemService.CreateItem(item); err != nil {
+	if err := h.itemService.CreateItem(currentActor(c), item); err != nil {
 		c.HTML(http.StatusBadRequest, "items/new.html", gin.H{
 			"title": "New Item",
 			"error": err.Error(),
@@ -92,6 +119,9 @@ func (h *Handlers) ItemsEdit(c *gin.Context) {
 		})
 		return
 	}
+	if !allowStore(c, item.StoreID) {
+		return
+	}
 
 	c.HTML(http.StatusOK, "items/edit.html", gin.H{
 		"title": "Edit Item",
@@ -101,15 +131,20 @@ func (h *Handlers) ItemsEdit(c *gin.Context) {
 
 // ItemsUpdate updates an item
 func (h *Handlers) ItemsUpdate(c *gin.Context) {
-	id := atoi(c.Param("id"))
+	existing, ok := h.findWebItem(c)
+	if !ok {
+		return
+	}
 	item := &models.Item{
-		ID:    id,
-		Name:  c.PostForm("name"),
-		Price: atoi(c.PostForm("price")),
-		Stock: atoi(c.PostForm("stock")),
+		ID:          existing.ID,
+		StoreID:     existing.StoreID,
+		Name:        c.PostForm("name"),
+		Price:       atoi(c.PostForm("price")),
+		Stock:       atoi(c.PostForm("stock")),
+		TaxExcluded: c.PostForm("taxExcluded") == "on",
 	}
 
-	if err := h.itemService.UpdateItem(item); err != nil {
+	if err := h.itemService.UpdateItem(currentActor(c), item); err != nil {
 		c.HTML(http.StatusBadRequest, "items/edit.html", gin.H{
 			"title": "Edit Item",
 			"error": err.Error(),
@@ -123,8 +158,11 @@ func (h *Handlers) ItemsUpdate(c *gin.Context) {
 
 // ItemsDelete deletes an item
 func (h *Handlers) ItemsDelete(c *gin.Context) {
-	id := atoi(c.Param("id"))
-	if err := h.itemService.DeleteItem(id); err != nil {
+	item, ok := h.findWebItem(c)
+	if !ok {
+		return
+	}
+	if err := h.itemService.DeleteItem(currentActor(c), item.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -132,9 +170,28 @@ func (h *Handlers) ItemsDelete(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/items")
 }
 
-// SalesList displays sales list page
+// findWebItem loads the item named by the :id parameter when the logged in
+// staff member may change it, rendering an error page otherwise
+func (h *Handlers) findWebItem(c *gin.Context) (*models.Item, bool) {
+	item, err := h.itemService.GetItem(atoi(c.Param("id")))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "Item not found",
+		})
+		return nil, false
+	}
+	return item, allowStore(c, item.StoreID)
+}
+
+// SalesList displays sales list page. Store managers see their own store.
 func (h *Handlers) SalesList(c *gin.Context) {
-	sales, err := h.saleService.GetAllSales()
+	var sales []*models.Sale
+	var err error
+	if scope := storeScope(c); scope != 0 {
+		sales, err = h.saleService.GetSalesReport(models.SaleFilter{StoreID: scope})
+	} else {
+		sales, err = h.saleService.GetAllSales()
+	}
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": err.Error(),
@@ -148,6 +205,50 @@ func (h *Handlers) SalesList(c *gin.Context) {
 	})
 }
 
+// SalesShow displays a single sale with its line items
+func (h *Handlers) SalesShow(c *gin.Context) {
+	id := atoi(c.Param("id"))
+	sale, err := h.saleService.GetSale(id)
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "Sale not found",
+		})
+		return
+	}
+	if !allowStore(c, &sale.StoreID) {
+		return
+	}
+
+	c.HTML(http.StatusOK, "sales/show.html", gin.H{
+		"title": "Sale Detail",
+		"sale":  sale,
+	})
+}
+
+// SalesVoid voids a sale from its detail page
+func (h *Handlers) SalesVoid(c *gin.Context) {
+	id := atoi(c.Param("id"))
+	sale, err := h.saleService.GetSale(id)
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "Sale not found",
+		})
+		return
+	}
+	if !allowStore(c, &sale.StoreID) {
+		return
+	}
+
+	if _, err := h.saleService.VoidSale(currentActor(c), id, c.PostForm("reason")); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/sales/%d", id))
+}
+
 // SalesNew displays new sale form
 func (h *Handlers) SalesNew(c *gin.Context) {
 	items, _ := h.itemService.GetAllItems()
@@ -167,10 +268,13 @@ func (h *Handlers) SalesCreate(c *gin.Context) {
 	// Parse form data
 	sale := &models.Sale{
 		StoreID: atoi(c.PostForm("storeId")),
-		StaffID: atoi(c.PostForm("staffId")),
+		StaffID: currentStaffID(c),
 		Deposit: atoi(c.PostForm("deposit")),
 		SaleAt:  time.Now(),
 	}
+	if scope := storeScope(c); scope != 0 {
+		sale.StoreID = scope
+	}
 
 	// Parse sale details
 	itemIds := c.PostFormArray("itemId[]")
@@ -184,7 +288,7 @@ func (h *Handlers) SalesCreate(c *gin.Context) {
 		sale.Details = append(sale.Details, detail)
 	}
 
-	if err := h.saleService.CreateSale(sale); err != nil {
+	if err := h.saleService.CreateSale(currentActor(c), sale); err != nil {
 		items, _ := h.itemService.GetAllItems()
 		stores, _ := h.storeService.GetAllStores()
 		staffs, _ := h.staffService.GetAllStaffs()
@@ -199,8 +303,10 @@ func (h *Handlers) SalesCreate(c *gin.Context) {
 		})
 		return
 	}
+	h.receiptService.PrintAfterSale(sale)
 
-	c.Redirect(http.StatusSeeOther, "/sales")
+	// Cashiers may not open the sales list, so show the sale itself
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/sales/%d", sale.ID))
 }
 
 // StoresList displays stores list page
@@ -232,7 +338,7 @@ func (h *Handlers) StoresCreate(c *gin.Context) {
 		Name: c.PostForm("name"),
 	}
 
-	if err := h.storeService.CreateStore(store); err != nil {
+	if err := h.storeService.CreateStore(currentActor(c), store); err != nil {
 		c.HTML(http.StatusBadRequest, "stores/new.html", gin.H{
 			"title": "New Store",
 			"error": err.Error(),
@@ -269,7 +375,7 @@ func (h *Handlers) StoresUpdate(c *gin.Context) {
 		Name: c.PostForm("name"),
 	}
 
-	if err := h.storeService.UpdateStore(store); err != nil {
+	if err := h.storeService.UpdateStore(currentActor(c), store); err != nil {
 		c.HTML(http.StatusBadRequest, "stores/edit.html", gin.H{
 			"title": "Edit Store",
 			"error": err.Error(),
@@ -284,7 +390,7 @@ func (h *Handlers) StoresUpdate(c *gin.Context) {
 // StoresDelete deletes a store
 func (h *Handlers) StoresDelete(c *gin.Context) {
 	id := atoi(c.Param("id"))
-	if err := h.storeService.DeleteStore(id); err != nil {
+	if err := h.storeService.DeleteStore(currentActor(c), id); err != nil {
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": err.Error(),
 		})
@@ -320,10 +426,13 @@ func (h *Handlers) StaffsNew(c *gin.Context) {
 // StaffsCreate creates a new staff
 func (h *Handlers) StaffsCreate(c *gin.Context) {
 	staff := &models.Staff{
-		Name: c.PostForm("name"),
+		Name:    c.PostForm("name"),
+		PIN:     c.PostForm("pin"),
+		Role:    c.PostForm("role"),
+		StoreID: formStoreID(c),
 	}
 
-	if err := h.staffService.CreateStaff(staff); err != nil {
+	if err := h.staffService.CreateStaff(currentActor(c), staff); err != nil {
 		c.HTML(http.StatusBadRequest, "staffs/new.html", gin.H{
 			"title": "New Staff",
 			"error": err.Error(),
@@ -356,11 +465,14 @@ func (h *Handlers) StaffsEdit(c *gin.Context) {
 func (h *Handlers) StaffsUpdate(c *gin.Context) {
 	id := atoi(c.Param("id"))
 	staff := &models.Staff{
-		ID:   id,
-		Name: c.PostForm("name"),
+		ID:      id,
+		Name:    c.PostForm("name"),
+		PIN:     c.PostForm("pin"),
+		Role:    c.PostForm("role"),
+		StoreID: formStoreID(c),
 	}
 
-	if err := h.staffService.UpdateStaff(staff); err != nil {
+	if err := h.staffService.UpdateStaff(currentActor(c), staff); err != nil {
 		c.HTML(http.StatusBadRequest, "staffs/edit.html", gin.H{
 			"title": "Edit Staff",
 			"error": err.Error(),
@@ -372,10 +484,18 @@ func (h *Handlers) StaffsUpdate(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/staffs")
 }
 
+// formStoreID reads the optional storeId form field
+func formStoreID(c *gin.Context) *int {
+	if id := atoi(c.PostForm("storeId")); id > 0 {
+		return &id
+	}
+	return nil
+}
+
 // StaffsDelete deletes a staff
 func (h *Handlers) StaffsDelete(c *gin.Context) {
 	id := atoi(c.Param("id"))
-	if err := h.staffService.DeleteStaff(id); err != nil {
+	if err := h.staffService.DeleteStaff(currentActor(c), id); err != nil {
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": err.Error(),
 		})
@@ -403,7 +523,15 @@ func (h *Handlers) SettingsList(c *gin.Context) {
 
 // ReportsSales displays sales report page
 func (h *Handlers) ReportsSales(c *gin.Context) {
-	sales, err := h.saleService.GetAllSales()
+	filter, err := parseSaleFilter(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	sales, err := h.saleService.GetSalesReport(filter)
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": err.Error(),
@@ -412,8 +540,12 @@ func (h *Handlers) ReportsSales(c *gin.Context) {
 	}
 
 	c.HTML(http.StatusOK, "reports/sales.html", gin.H{
-		"title": "Sales Report",
-		"sales": sales,
+		"title":   "Sales Report",
+		"sales":   sales,
+		"from":    c.Query("from"),
+		"to":      c.Query("to"),
+		"storeId": c.Query("storeId"),
+		"staffId": c.Query("staffId"),
 	})
 }
 
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// APIItemsLabels returns A4 label sheets for all items, or for the items
+// given as ids, as PDF
+func (h *Handlers) APIItemsLabels(c *gin.Context) {
+	ids, layout, copies, err := parseLabelQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.labelService.WriteLabelSheetPDF(&buf, ids, layout, copies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "inline; filename=labels.pdf")
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// parseLabelQuery reads the items, the layout and the number of copies.
+// A predefined layout can be adjusted with columns, rows, marginTop,
+// marginLeft, gapX and gapY.
+func parseLabelQuery(c *gin.Context) ([]int, service.LabelLayout, int, error) {
+	var ids []int
+	for _, value := range c.QueryArray("ids") {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, service.LabelLayout{}, 0, fmt.Errorf("invalid item ID: %s", s)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	layout, err := service.FindLabelLayout(c.DefaultQuery("layout", "a4-24"))
+	if err != nil {
+		return nil, layout, 0, err
+	}
+	for name, field := range map[string]*int{"columns": &layout.Columns, "rows": &layout.Rows} {
+		if value := c.Query(name); value != "" {
+			if *field, err = strconv.Atoi(value); err != nil {
+				return nil, layout, 0, fmt.Errorf("invalid %s: %s", name, value)
+			}
+		}
+	}
+	lengths := map[string]*float64{
+		"marginTop":  &layout.MarginTop,
+		"marginLeft": &layout.MarginLeft,
+		"gapX":       &layout.GapX,
+		"gapY":       &layout.GapY,
+	}
+	for name, field := range lengths {
+		if value := c.Query(name); value != "" {
+			if *field, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, layout, 0, fmt.Errorf("invalid %s: %s", name, value)
+			}
+		}
+	}
+
+	copies, err := strconv.Atoi(c.DefaultQuery("copies", "1"))
+	if err != nil {
+		return nil, layout, 0, fmt.Errorf("invalid copies: %s", c.Query("copies"))
+	}
+	return ids, layout, copies, nil
+}
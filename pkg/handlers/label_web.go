@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ItemsLabels displays the label sheet form
+func (h *Handlers) ItemsLabels(c *gin.Context) {
+	items, err := h.itemService.GetAllItems()
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "items/labels.html", gin.H{
+		"title":   "Labels",
+		"items":   items,
+		"layouts": service.LabelLayouts,
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Hosting platforms that put the client address in a header the client
+// cannot forge
+var trustedPlatformHeaders = map[string]string{
+	"vercel":     "X-Vercel-Forwarded-For",
+	"cloudflare": gin.PlatformCloudflare,
+}
+
+// loopbackNetworks are always allowed so the server can be managed from
+// the machine it runs on
+var loopbackNetworks = []string{"127.0.0.0/8", "::1/128"}
+
+// IPAllowlist is a set of networks allowed to reach a group of routes
+type IPAllowlist struct {
+	name     string
+	allowAll bool
+	networks []*net.IPNet
+}
+
+// ParseIPAllowlist parses a comma separated list of CIDR ranges, single
+// addresses and prefixes like "192.168." or "10.". "*" allows everyone.
+// Invalid entries are logged and ignored, which only ever narrows access.
+func ParseIPAllowlist(name, spec string) *IPAllowlist {
+	list := &IPAllowlist{name: name}
+	for _, entry := range append(strings.Split(spec, ","), loopbackNetworks...) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			list.allowAll = true
+			continue
+		}
+		network, err := parseNetwork(entry)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid %s network %q: %v", name, entry, err)
+			continue
+		}
+		list.networks = append(list.networks, network)
+	}
+	return list
+}
+
+// parseNetwork turns a CIDR range, an address or a dotted IPv4 prefix into
+// a network
+func parseNetwork(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		return network, err
+	}
+
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	// "192.168." covers 192.168.0.0/16
+	if strings.HasSuffix(entry, ".") {
+		octets := strings.Split(strings.TrimSuffix(entry, "."), ".")
+		if len(octets) <= 3 {
+			padded := append(append([]string{}, octets...), "0", "0", "0")[:4]
+			if ip := net.ParseIP(strings.Join(padded, ".")).To4(); ip != nil {
+				return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(octets), 32)}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("not a CIDR range, address or prefix")
+}
+
+// Allows reports whether the address belongs to one of the networks
+func (l *IPAllowlist) Allows(address string) bool {
+	if l.allowAll {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkAccess restricts the admin pages and the register API to the
+// configured networks
+type NetworkAccess struct {
+	admin    *IPAllowlist
+	register *IPAllowlist
+	proxies  []string
+	platform string
+}
+
+// NewNetworkAccess builds the allowlists from the configuration
+func NewNetworkAccess(cfg *config.Config) *NetworkAccess {
+	access := &NetworkAccess{
+		admin:    ParseIPAllowlist("admin", cfg.AdminAllowedIPs),
+		register: ParseIPAllowlist("register", cfg.RegisterAllowedIPs),
+	}
+	for _, proxy := range strings.Split(cfg.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			access.proxies = append(access.proxies, proxy)
+		}
+	}
+	if cfg.TrustedPlatform != "" {
+		header, ok := trustedPlatformHeaders[strings.ToLower(cfg.TrustedPlatform)]
+		if !ok {
+			log.Printf("Warning: unknown trusted platform %q", cfg.TrustedPlatform)
+		}
+		access.platform = header
+	}
+	return access
+}
+
+// configure sets how the router finds the client address. Forwarded
+// headers are ignored unless proxies or a platform are trusted.
+func (a *NetworkAccess) configure(router *gin.Engine) {
+	if err := router.SetTrustedProxies(a.proxies); err != nil {
+		log.Printf("Warning: invalid trusted proxies, forwarded headers are ignored: %v", err)
+		router.SetTrustedProxies(nil)
+	}
+	router.TrustedPlatform = a.platform
+}
+
+// RequireAdminNetwork rejects clients outside the admin networks
+func (a *NetworkAccess) RequireAdminNetwork() gin.HandlerFunc {
+	return a.require(a.admin)
+}
+
+// RequireRegisterNetwork rejects clients outside the register networks.
+// Admin networks may use the register API too; the admin pages call it.
+func (a *NetworkAccess) RequireRegisterNetwork() gin.HandlerFunc {
+	return a.require(a.register, a.admin)
+}
+
+func (a *NetworkAccess) require(lists ...*IPAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		for _, list := range lists {
+			if list.Allows(ip) {
+				c.Next()
+				return
+			}
+		}
+
+		log.Printf("Rejected %s %s from %q: not in the %s networks", c.Request.Method, c.Request.URL.Path, ip, lists[0].name)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Access from this network is not allowed",
+			"code":  "network_not_allowed",
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/config"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPAllowlist(t *testing.T) {
+	list := ParseIPAllowlist("test", "192.168., 10.0.0.0/8, 172.16.5.4, 2001:db8::/32, bogus, 300.1.")
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"192.168.1.20", true},
+		{"192.169.1.20", false},
+		{"10.20.30.40", true},
+		{"172.16.5.4", true},
+		{"172.16.5.5", false},
+		{"2001:db8::1", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"8.8.8.8", false},
+		{"", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, list.Allows(tt.address), tt.address)
+	}
+
+	assert.True(t, ParseIPAllowlist("test", "*").Allows("8.8.8.8"))
+	assert.False(t, ParseIPAllowlist("test", "").Allows("192.168.1.1"))
+}
+
+func TestNetworkAccess(t *testing.T) {
+	newRouter := func(cfg *config.Config) *gin.Engine {
+		db := setupTestDB(t)
+		t.Cleanup(func() { db.Close() })
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		services := service.NewServices(repository.NewRepositories(db), cfg)
+		loginTestStaff(router, services)
+		SetupRoutes(router, NewHandlers(services, cfg))
+		return router
+	}
+	request := func(router *gin.Engine, method, path, remoteAddr string, headers map[string]string) int {
+		req, _ := http.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cfg := config.New()
+	cfg.AdminAllowedIPs = "192.168.10.0/24"
+	cfg.RegisterAllowedIPs = "192.168.20.0/24"
+	cfg.TrustedProxies, cfg.TrustedPlatform = "", ""
+	router := newRouter(cfg)
+
+	t.Run("register network uses the register API only", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(router, "GET", "/api/items", "192.168.20.5:1234", nil))
+		assert.Equal(t, http.StatusForbidden, request(router, "GET", "/api/sales", "192.168.20.5:1234", nil))
+		assert.Equal(t, http.StatusForbidden, request(router, "GET", "/items", "192.168.20.5:1234", nil))
+	})
+
+	t.Run("admin network uses both", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(router, "GET", "/api/items", "192.168.10.5:1234", nil))
+		assert.Equal(t, http.StatusOK, request(router, "GET", "/api/sales", "192.168.10.5:1234", nil))
+	})
+
+	t.Run("other networks are rejected", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/items", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"network_not_allowed"`)
+	})
+
+	t.Run("loopback is always allowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(router, "GET", "/api/sales", "127.0.0.1:1234", nil))
+	})
+
+	t.Run("forwarded headers are ignored by default", func(t *testing.T) {
+		headers := map[string]string{"X-Forwarded-For": "192.168.10.5", "X-Vercel-Forwarded-For": "192.168.10.5"}
+		assert.Equal(t, http.StatusForbidden, request(router, "GET", "/api/items", "203.0.113.7:1234", headers))
+	})
+
+	t.Run("trusted proxy forwards the client address", func(t *testing.T) {
+		proxied := *cfg
+		proxied.TrustedProxies = "203.0.113.0/24"
+		router := newRouter(&proxied)
+
+		headers := map[string]string{"X-Forwarded-For": "192.168.20.5"}
+		assert.Equal(t, http.StatusOK, request(router, "GET", "/api/items", "203.0.113.7:1234", headers))
+		assert.Equal(t, http.StatusForbidden, request(router, "GET", "/api/items", "198.51.100.1:1234", headers))
+	})
+
+	t.Run("vercel header is used on vercel", func(t *testing.T) {
+		vercel := *cfg
+		vercel.TrustedPlatform = "vercel"
+		router := newRouter(&vercel)
+
+		headers := map[string]string{"X-Vercel-Forwarded-For": "192.168.20.5"}
+		assert.Equal(t, http.StatusOK, request(router, "GET", "/api/items", "203.0.113.7:1234", headers))
+		assert.Equal(t, http.StatusForbidden, request(router, "GET", "/api/sales", "203.0.113.7:1234", headers))
+	})
+}
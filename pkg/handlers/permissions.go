@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects staff members whose role does not grant the
+// permission. It runs after the login middleware.
+func (h *Handlers) RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := currentSession(c)
+		if session != nil && session.Staff.Can(permission) {
+			c.Next()
+			return
+		}
+
+		staffID := ""
+		if session != nil {
+			staffID = session.Staff.StaffID
+		}
+		log.Printf("Denied %s %s to staff %q: needs %s", c.Request.Method, c.Request.URL.Path, staffID, permission)
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "Permission denied",
+				"code":       "permission_denied",
+				"permission": permission,
+			})
+			return
+		}
+		c.HTML(http.StatusForbidden, "error.html", gin.H{
+			"error": "この操作の権限がありません",
+		})
+		c.Abort()
+	}
+}
+
+// storeScope returns the store the logged in staff member is limited to, or
+// 0 when they may work with every store
+func storeScope(c *gin.Context) int {
+	if session := currentSession(c); session != nil {
+		return session.Staff.ScopedStoreID()
+	}
+	return 0
+}
+
+// allowStore reports whether the logged in staff member may see and manage
+// records of the store. Otherwise it responds with 403. Records without a store are
+// shared by every store, so only unscoped staff may change them.
+func allowStore(c *gin.Context, storeID *int) bool {
+	scope := storeScope(c)
+	if scope == 0 || (storeID != nil && *storeID == scope) {
+		return true
+	}
+
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This belongs to another store",
+			"code":  "store_not_allowed",
+		})
+	} else {
+		c.HTML(http.StatusForbidden, "error.html", gin.H{
+			"error": "他のお店のデータは扱えません",
+		})
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIPrintJobsList returns recent print jobs, optionally filtered by status
+func (h *Handlers) APIPrintJobsList(c *gin.Context) {
+	jobs, err := h.printQueueService.GetJobs(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// APIPrintJobsReprint queues a copy of a finished or failed print job
+func (h *Handlers) APIPrintJobsReprint(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.printQueueService.GetJob(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Print job not found"})
+		return
+	}
+
+	job, err := h.printQueueService.Reprint(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, job)
+}
+
+// APIPrintersStatus returns the queue status of every printer
+func (h *Handlers) APIPrintersStatus(c *gin.Context) {
+	statuses, err := h.printQueueService.GetPrinterStatuses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
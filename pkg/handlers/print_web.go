@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrintJobsList displays the printer status and recent print jobs
+func (h *Handlers) PrintJobsList(c *gin.Context) {
+	status := c.Query("status")
+	jobs, err := h.printQueueService.GetJobs(status)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	printers, err := h.printQueueService.GetPrinterStatuses()
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "print_jobs/index.html", gin.H{
+		"title":    "Print Jobs",
+		"status":   status,
+		"jobs":     jobs,
+		"printers": printers,
+	})
+}
+
+// PrintJobsReprint queues a copy of a print job from the web UI
+func (h *Handlers) PrintJobsReprint(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": "Invalid ID",
+		})
+		return
+	}
+
+	if _, err := h.printQueueService.Reprint(id); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/print-jobs")
+}
@@ -1,82 +1,231 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/gin-gonic/gin"
+)
 
 // SetupRoutes はGinのルーターを設定します
 // cmd/server/main.goとapi/index.goの両方から呼び出されます
 func SetupRoutes(router *gin.Engine, h *Handlers) {
-	// Web routes
-	router.GET("/", h.Home)
-	router.GET("/items", h.ItemsList)
-	router.GET("/items/new", h.ItemsNew)
-	router.POST("/items", h.ItemsCreate)
-	router.GET("/items/:id/edit", h.ItemsEdit)
-	router.POST("/items/:id", h.ItemsUpdate)
-	router.POST("/items/:id/delete", h.ItemsDelete)
-
-	router.GET("/sales", h.SalesList)
-	router.GET("/sales/new", h.SalesNew)
-	router.POST("/sales", h.SalesCreate)
-
-	router.GET("/stores", h.StoresList)
-	router.GET("/stores/new", h.StoresNew)
-	router.POST("/stores", h.StoresCreate)
-	router.GET("/stores/:id/edit", h.StoresEdit)
-	router.POST("/stores/:id", h.StoresUpdate)
-	router.POST("/stores/:id/delete", h.StoresDelete)
-
-	router.GET("/staffs", h.StaffsList)
-	router.GET("/staffs/new", h.StaffsNew)
-	router.POST("/staffs", h.StaffsCreate)
-	router.GET("/staffs/:id/edit", h.StaffsEdit)
-	router.POST("/staffs/:id", h.StaffsUpdate)
-	router.POST("/staffs/:id/delete", h.StaffsDelete)
-
-	router.GET("/settings", h.SettingsList)
-	router.GET("/reports/sales", h.ReportsSales)
-
-	router.GET("/apk", h.ApkList)
-	router.GET("/apk/upload", h.ApkUploadPage)
-	router.POST("/apk/upload", h.ApkUpload)
+	h.network.configure(router)
+
+	// Web routes are the admin pages, for logged in staff. Each group needs
+	// the permission of its pages.
+	web := router.Group("/", h.network.RequireAdminNetwork())
+	web.GET("/login", h.LoginPage)
+	web.POST("/login", h.Login)
+	web.POST("/logout", h.Logout)
+
+	pages := web.Group("", h.RequireLogin())
+	pages.GET("/", h.Home)
+
+	// Every role may look at the items; store managers change their own
+	viewItems := pages.Group("", h.RequirePermission(models.PermissionItemsView))
+	{
+		viewItems.GET("/items", h.ItemsList)
+	}
+	manageItems := pages.Group("", h.RequirePermission(models.PermissionItemsManage))
+	{
+		manageItems.GET("/items/new", h.ItemsNew)
+		manageItems.GET("/items/labels", h.ItemsLabels)
+		manageItems.POST("/items", h.ItemsCreate)
+		manageItems.GET("/items/:id/edit", h.ItemsEdit)
+		manageItems.POST("/items/:id", h.ItemsUpdate)
+		manageItems.POST("/items/:id/delete", h.ItemsDelete)
+	}
+
+	createSales := pages.Group("", h.RequirePermission(models.PermissionSalesCreate))
+	{
+		createSales.GET("/sales/new", h.SalesNew)
+		createSales.POST("/sales", h.SalesCreate)
+		createSales.GET("/sales/:id", h.SalesShow)
+		// The detail page shows and reprints receipts without going through
+		// the register API, which may only accept devices
+		createSales.GET("/sales/:id/receipt", h.APISalesReceipt)
+		createSales.POST("/sales/:id/print", h.APISalesPrint)
+	}
+	manageSales := pages.Group("", h.RequirePermission(models.PermissionSalesManage))
+	{
+		manageSales.GET("/sales", h.SalesList)
+		manageSales.POST("/sales/:id/void", h.SalesVoid)
+		manageSales.GET("/reports/sales", h.ReportsSales)
+	}
+
+	managePrint := pages.Group("", h.RequirePermission(models.PermissionPrintManage))
+	{
+		managePrint.GET("/print-jobs", h.PrintJobsList)
+		managePrint.POST("/print-jobs/:id/reprint", h.PrintJobsReprint)
+	}
+
+	manageStores := pages.Group("", h.RequirePermission(models.PermissionStoresManage))
+	{
+		manageStores.GET("/stores", h.StoresList)
+		manageStores.GET("/stores/new", h.StoresNew)
+		manageStores.POST("/stores", h.StoresCreate)
+		manageStores.GET("/stores/:id/edit", h.StoresEdit)
+		manageStores.POST("/stores/:id", h.StoresUpdate)
+		manageStores.POST("/stores/:id/delete", h.StoresDelete)
+	}
+
+	manageStaff := pages.Group("", h.RequirePermission(models.PermissionStaffManage))
+	{
+		manageStaff.GET("/staffs", h.StaffsList)
+		manageStaff.GET("/staffs/new", h.StaffsNew)
+		manageStaff.POST("/staffs", h.StaffsCreate)
+		manageStaff.GET("/staffs/:id/edit", h.StaffsEdit)
+		manageStaff.POST("/staffs/:id", h.StaffsUpdate)
+		manageStaff.POST("/staffs/:id/delete", h.StaffsDelete)
+	}
+
+	manageSettings := pages.Group("", h.RequirePermission(models.PermissionSettingsManage))
+	{
+		manageSettings.GET("/settings", h.SettingsList)
+	}
+
+	manageApk := pages.Group("", h.RequirePermission(models.PermissionApkManage))
+	{
+		manageApk.GET("/apk", h.ApkList)
+		manageApk.GET("/apk/upload", h.ApkUploadPage)
+		manageApk.POST("/apk/upload", h.ApkUpload)
+	}
+
+	manageDevices := pages.Group("", h.RequirePermission(models.PermissionDevicesManage))
+	{
+		manageDevices.GET("/devices", h.DevicesList)
+		manageDevices.POST("/devices/:id/approve", h.DevicesApprove)
+		manageDevices.POST("/devices/:id/revoke", h.DevicesRevoke)
+	}
+
+	viewAudit := pages.Group("", h.RequirePermission(models.PermissionAuditView))
+	{
+		viewAudit.GET("/audit", h.AuditList)
+	}
 
 	// API routes
 	api := router.Group("/api")
+
+	// Register routes are what the registers need to sell and update. Device
+	// registration, logging in and app updates come before a staff member is
+	// logged in; the rest needs an approved device, if any.
+	register := api.Group("", h.network.RequireRegisterNetwork(), h.IdentifyDevice())
+	register.POST("/devices/register", h.APIDevicesRegister)
+	register.GET("/devices/me", h.APIDevicesMe)
+	register.POST("/auth/login", h.APIAuthLogin)
+	register.GET("/apk/version/latest", h.APIApkLatest)
+	register.GET("/apk/version/check", h.APIApkCheckUpdate)
+	register.GET("/apk/version/all", h.APIApkVersions)
+	register.GET("/apk/download/:id", h.APIApkDownload)
+	register.GET("/apk/download/latest", h.APIApkDownloadLatest)
+
+	staff := register.Group("", h.RequireAPILogin(), h.RequireDevice())
+	{
+		staff.POST("/auth/logout", h.APIAuthLogout)
+		staff.GET("/auth/me", h.APIAuthMe)
+
+		staff.GET("/stores", h.APIStoresList)
+		staff.GET("/stores/:id", h.APIStoresGet)
+
+		staff.GET("/staffs", h.APIStaffsList)
+		staff.GET("/staffs/:id", h.APIStaffsGet)
+
+		staff.GET("/settings", h.APISettingsList)
+	}
+	registerItems := staff.Group("", h.RequirePermission(models.PermissionItemsView))
+	{
+		registerItems.GET("/items", h.APIItemsList)
+		registerItems.GET("/items/code/:itemId", h.APIItemsGetByCode)
+		registerItems.GET("/items/:id", h.APIItemsGet)
+	}
+	registerSales := staff.Group("", h.RequirePermission(models.PermissionSalesCreate))
+	{
+		registerSales.GET("/sales/:id", h.APISalesGet)
+		registerSales.POST("/sales", h.APISalesCreate)
+		registerSales.POST("/sales/batch", h.APISalesBatch)
+		registerSales.POST("/sales/:id/print", h.APISalesPrint)
+		registerSales.GET("/sales/:id/receipt", h.APISalesReceipt)
+	}
+	// Voids and refunds are done at the register by a store manager
+	registerManage := staff.Group("", h.RequirePermission(models.PermissionSalesManage))
+	{
+		registerManage.POST("/sales/:id/void", h.APISalesVoid)
+		registerManage.POST("/sales/:id/refund", h.APISalesRefund)
+	}
+
+	// Admin routes manage the catalog, settings, printers and reports
+	admin := api.Group("", h.network.RequireAdminNetwork(), h.RequireAPILogin())
+
+	adminItems := admin.Group("", h.RequirePermission(models.PermissionItemsManage))
+	{
+		adminItems.GET("/items/labels.pdf", h.APIItemsLabels)
+		adminItems.GET("/items/:id/qr.png", h.APIItemsQRCode)
+		adminItems.GET("/items/:id/barcode.png", h.APIItemsBarcode)
+		adminItems.POST("/items", h.APIItemsCreate)
+		adminItems.PUT("/items/:id", h.APIItemsUpdate)
+		adminItems.DELETE("/items/:id", h.APIItemsDelete)
+		adminItems.POST("/items/:id/aliases", h.APIItemsAddAlias)
+		adminItems.DELETE("/items/:id/aliases/:code", h.APIItemsDeleteAlias)
+	}
+
+	adminSales := admin.Group("", h.RequirePermission(models.PermissionSalesManage))
+	{
+		adminSales.GET("/sales", h.APISalesList)
+		adminSales.GET("/reports/sales", h.APIReportsSales)
+		adminSales.GET("/reports/sales/excel", h.APIReportsSalesExcel)
+	}
+
+	adminPrint := admin.Group("", h.RequirePermission(models.PermissionPrintManage))
+	{
+		adminPrint.GET("/print-jobs", h.APIPrintJobsList)
+		adminPrint.POST("/print-jobs/:id/reprint", h.APIPrintJobsReprint)
+		adminPrint.GET("/printers", h.APIPrintersStatus)
+	}
+
+	adminStores := admin.Group("", h.RequirePermission(models.PermissionStoresManage))
+	{
+		adminStores.POST("/stores", h.APIStoresCreate)
+		adminStores.PUT("/stores/:id", h.APIStoresUpdate)
+		adminStores.DELETE("/stores/:id", h.APIStoresDelete)
+	}
+
+	adminStaff := admin.Group("", h.RequirePermission(models.PermissionStaffManage))
+	{
+		adminStaff.POST("/staffs", h.APIStaffsCreate)
+		adminStaff.PUT("/staffs/:id", h.APIStaffsUpdate)
+		adminStaff.DELETE("/staffs/:id", h.APIStaffsDelete)
+	}
+
+	adminSettings := admin.Group("", h.RequirePermission(models.PermissionSettingsManage))
+	{
+		adminSettings.PUT("/settings/:key", h.APISettingsUpdate)
+	}
+
+	adminApk := admin.Group("", h.RequirePermission(models.PermissionApkManage))
+	{
+		adminApk.POST("/apk/upload", h.APIApkUpload)
+		adminApk.DELETE("/apk/version/:id", h.APIApkDelete)
+		adminApk.PUT("/apk/version/:id/deactivate", h.APIApkDeactivate)
+	}
+
+	adminDevices := admin.Group("", h.RequirePermission(models.PermissionDevicesManage))
+	{
+		adminDevices.GET("/devices", h.APIDevicesList)
+		adminDevices.POST("/devices/:id/approve", h.APIDevicesApprove)
+		adminDevices.POST("/devices/:id/revoke", h.APIDevicesRevoke)
+	}
+
+	adminAudit := admin.Group("", h.RequirePermission(models.PermissionAuditView))
+	{
+		adminAudit.GET("/audit", h.APIAuditList)
+	}
+
+	adminBackup := admin.Group("/admin", h.RequirePermission(models.PermissionBackupManage))
 	{
-		api.GET("/items", h.APIItemsList)
-		api.GET("/items/:id", h.APIItemsGet)
-		api.POST("/items", h.APIItemsCreate)
-		api.PUT("/items/:id", h.APIItemsUpdate)
-		api.DELETE("/items/:id", h.APIItemsDelete)
-
-		api.GET("/sales", h.APISalesList)
-		api.GET("/sales/:id", h.APISalesGet)
-		api.POST("/sales", h.APISalesCreate)
-
-		api.GET("/stores", h.APIStoresList)
-		api.GET("/stores/:id", h.APIStoresGet)
-		api.POST("/stores", h.APIStoresCreate)
-		api.PUT("/stores/:id", h.APIStoresUpdate)
-		api.DELETE("/stores/:id", h.APIStoresDelete)
-
-		api.GET("/staffs", h.APIStaffsList)
-		api.GET("/staffs/:id", h.APIStaffsGet)
-		api.POST("/staffs", h.APIStaffsCreate)
-		api.PUT("/staffs/:id", h.APIStaffsUpdate)
-		api.DELETE("/staffs/:id", h.APIStaffsDelete)
-
-		api.GET("/settings", h.APISettingsList)
-		api.PUT("/settings/:key", h.APISettingsUpdate)
-
-		api.GET("/reports/sales", h.APIReportsSales)
-		api.GET("/reports/sales/excel", h.APIReportsSalesExcel)
-
-		api.GET("/apk/version/latest", h.APIApkLatest)
-		api.GET("/apk/version/check", h.APIApkCheckUpdate)
-		api.GET("/apk/version/all", h.APIApkVersions)
-		api.GET("/apk/download/:id", h.APIApkDownload)
-		api.GET("/apk/download/latest", h.APIApkDownloadLatest)
-		api.POST("/apk/upload", h.APIApkUpload)
-		api.DELETE("/apk/version/:id", h.APIApkDelete)
-		api.PUT("/apk/version/:id/deactivate", h.APIApkDeactivate)
+		adminBackup.GET("/backup", h.APIBackupDownload)
+		adminBackup.GET("/backups", h.APIBackupsList)
+		adminBackup.GET("/backups/:name", h.APIBackupsDownload)
+		adminBackup.POST("/restore", h.APIRestore)
+		adminBackup.GET("/export", h.APIExport)
+		adminBackup.POST("/import", h.APIImport)
 	}
 }
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited entities
+const (
+	AuditItem    = "item"
+	AuditStore   = "store"
+	AuditStaff   = "staff"
+	AuditSetting = "setting"
+	AuditApk     = "apk"
+	AuditSale    = "sale"
+	AuditDevice  = "device"
+	// AuditDatabase records restores and imports of the whole database
+	AuditDatabase = "database"
+)
+
+// Audited actions
+const (
+	AuditCreate     = "create"
+	AuditUpdate     = "update"
+	AuditDelete     = "delete"
+	AuditVoid       = "void"
+	AuditRefund     = "refund"
+	AuditDeactivate = "deactivate"
+	AuditApprove    = "approve"
+	AuditRevoke     = "revoke"
+	AuditRestore    = "restore"
+	AuditImport     = "import"
+)
+
+// Actor is who makes a change: the logged in staff member and the register
+// device the request came from, if any
+type Actor struct {
+	StaffID   int
+	StaffName string
+	DeviceID  *int
+}
+
+// AuditEntry records one change with the entity as it was before and after.
+// Before is empty for creations and After for deletions.
+type AuditEntry struct {
+	ID         int             `json:"id" db:"id"`
+	Entity     string          `json:"entity" db:"entity"`
+	EntityID   string          `json:"entityId" db:"entityId"`
+	Action     string          `json:"action" db:"action"`
+	StaffID    *int            `json:"staffId,omitempty" db:"staffId"`
+	StaffName  string          `json:"staffName,omitempty" db:"staffName"`
+	DeviceID   *int            `json:"deviceId,omitempty" db:"deviceId"`
+	DeviceName string          `json:"deviceName,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty" db:"beforeJson"`
+	After      json.RawMessage `json:"after,omitempty" db:"afterJson"`
+	CreatedAt  time.Time       `json:"createdAt" db:"createdAt"`
+}
+
+// AuditFilter narrows down the audit log. Zero values match everything.
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+	Action   string
+	StaffID  int
+	DeviceID int
+	From     time.Time
+	To       time.Time // exclusive
+	Limit    int
+}
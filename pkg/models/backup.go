@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Backup is a copy of the database kept in the backup directory
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
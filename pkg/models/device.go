@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Device states
+const (
+	DevicePending  = "pending"  // registered, waiting for an admin
+	DeviceApproved = "approved" // may call the register API
+	DeviceRevoked  = "revoked"  // its token no longer works
+)
+
+// Device is a register tablet. The token is only known right after
+// registration; the database keeps a hash of it.
+type Device struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Status     string     `json:"status" db:"status"`
+	Token      string     `json:"token,omitempty"`
+	AppVersion string     `json:"appVersion,omitempty" db:"appVersion"`
+	LastIP     string     `json:"lastIp,omitempty" db:"lastIp"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty" db:"lastSeenAt"`
+	ApprovedAt *time.Time `json:"approvedAt,omitempty" db:"approvedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" db:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt" db:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt" db:"updatedAt"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// InsufficientStockError is returned when a sale asks for more of an item
+// than is left in stock at the time the sale is committed
+type InsufficientStockError struct {
+	ItemID    int    `json:"itemId"`
+	ItemCode  string `json:"itemCode"`
+	ItemName  string `json:"itemName"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for item %s: requested %d, %d left", e.ItemName, e.Requested, e.Available)
+}
+
+// DuplicateSaleError is returned when a sale is submitted again with a client ID
+// or idempotency key that already belongs to a recorded sale
+type DuplicateSaleError struct {
+	ClientID string
+	SaleID   int
+	// Sale is the sale recorded the first time, when it has been loaded
+	Sale *Sale
+}
+
+func (e *DuplicateSaleError) Error() string {
+	return fmt.Sprintf("sale %s has already been recorded as sale %d", e.ClientID, e.SaleID)
+}
+
+// ErrInvalidLogin is returned when a staff ID and PIN do not match. It does
+// not say which of the two was wrong.
+var ErrInvalidLogin = errors.New("invalid staff ID or PIN")
+
+// LoginLockedError is returned when logins are refused for a while after
+// too many attempts
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many login attempts, try again after %s", e.Until.Format("15:04:05"))
+}
+
+// ErrInvalidDeviceToken is returned for a device token that was never issued
+var ErrInvalidDeviceToken = errors.New("invalid device token")
+
+// ErrDeviceRevoked is returned for the token of a device an admin revoked
+var ErrDeviceRevoked = errors.New("device has been revoked")
@@ -0,0 +1,128 @@
+package models
+
+import "time"
+
+// ExportVersion is the version of the export format this server writes.
+// Documents of a newer version are refused on import.
+const ExportVersion = 1
+
+// Import modes
+const (
+	// ImportMerge adds what is new and keeps the records that differ,
+	// reporting them as conflicts
+	ImportMerge = "merge"
+	// ImportReplace overwrites the records that differ and deletes the items
+	// the document does not list
+	ImportReplace = "replace"
+)
+
+// Export is the catalog of a shop as a portable document. Records refer to
+// each other by their business keys (itemId, storeId, staffId) rather than
+// database IDs, so the document can be loaded into another server.
+type Export struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Stores     []ExportStore   `json:"stores"`
+	Items      []ExportItem    `json:"items"`
+	Staff      []ExportStaff   `json:"staff"`
+	Settings   []ExportSetting `json:"settings"`
+	Sales      []ExportSale    `json:"sales,omitempty"`
+}
+
+// ExportStore is a store in an export
+type ExportStore struct {
+	StoreID string `json:"storeId"`
+	Name    string `json:"name"`
+}
+
+// ExportItem is an item in an export
+type ExportItem struct {
+	ItemID      string   `json:"itemId"`
+	Name        string   `json:"name"`
+	Price       int      `json:"price"`
+	Stock       int      `json:"stock"`
+	TaxExcluded bool     `json:"taxExcluded"`
+	StoreID     string   `json:"storeId,omitempty"` // owning store; shared items have none
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+// ExportStaff is a staff member in an export. PINs are not exported.
+type ExportStaff struct {
+	StaffID string `json:"staffId"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	StoreID string `json:"storeId,omitempty"` // the store a store manager manages
+}
+
+// ExportSetting is a setting in an export
+type ExportSetting struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// ExportSale is a sale in an export. Sales are exported for the record and
+// are not imported; an import counts them as skipped.
+type ExportSale struct {
+	Number         int                `json:"number"` // the sale's ID on the exporting server
+	Type           string             `json:"type"`
+	OriginalNumber *int               `json:"originalNumber,omitempty"`
+	Reason         string             `json:"reason,omitempty"`
+	StoreID        string             `json:"storeId"`
+	StaffID        string             `json:"staffId"`
+	Subtotal       int                `json:"subtotal"`
+	Tax            int                `json:"tax"`
+	TaxRate        float64            `json:"taxRate"`
+	TaxRounding    string             `json:"taxRounding"`
+	TotalPrice     int                `json:"totalPrice"`
+	Deposit        int                `json:"deposit"`
+	Change         int                `json:"change"`
+	SaleAt         time.Time          `json:"saleAt"`
+	Details        []ExportSaleDetail `json:"details"`
+}
+
+// ExportSaleDetail is a line of an exported sale
+type ExportSaleDetail struct {
+	ItemID          string `json:"itemId"`
+	Quantity        int    `json:"quantity"`
+	Price           int    `json:"price"`
+	ListPrice       int    `json:"listPrice"`
+	PriceOverridden bool   `json:"priceOverridden"`
+	OverrideReason  string `json:"overrideReason,omitempty"`
+	TaxExcluded     bool   `json:"taxExcluded"`
+}
+
+// ImportCounts tells what an import did with one kind of record
+type ImportCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+	Skipped   int `json:"skipped"`
+}
+
+// ImportConflict is a record of the document that could not be imported
+// as it is. Kind is one of the audited entities (item, store, staff,
+// setting) and Key its business key.
+type ImportConflict struct {
+	Kind   string   `json:"kind"`
+	Key    string   `json:"key"`
+	Fields []string `json:"fields,omitempty"` // fields that differ from this server
+	Reason string   `json:"reason"`
+}
+
+// ImportResult reports what an import did, or would do on a dry run
+type ImportResult struct {
+	Mode     string       `json:"mode"`
+	DryRun   bool         `json:"dryRun"`
+	Stores   ImportCounts `json:"stores"`
+	Items    ImportCounts `json:"items"`
+	Staff    ImportCounts `json:"staff"`
+	Settings ImportCounts `json:"settings"`
+	// SalesSkipped is the number of sales in the document, which are
+	// never imported. It is always present, so a client cannot take a
+	// missing field for sales that were loaded.
+	SalesSkipped int              `json:"salesSkipped"`
+	Conflicts    []ImportConflict `json:"conflicts"`
+}
@@ -12,7 +12,10 @@ type Item struct {
 	Name        string    `json:"name" db:"name"`
 	Price       int       `json:"price" db:"price"`
 	Stock       int       `json:"stock" db:"stock"`
+	TaxExcluded bool      `json:"taxExcluded" db:"taxExcluded"`   // price does not include tax yet
+	StoreID     *int      `json:"storeId,omitempty" db:"storeId"` // owning store; shared items have none
 	IsDeleted   bool      `json:"isDeleted" db:"isDeleted"`
+	Aliases     []string  `json:"aliases,omitempty" db:"-"` // further codes for the item, e.g. a JAN barcode
 	CreatedAt   time.Time `json:"createdAt" db:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt" db:"updatedAt"`
 }
@@ -26,40 +29,129 @@ type Store struct {
 	UpdatedAt   time.Time `json:"updatedAt" db:"updatedAt"`
 }
 
-// Staff represents a staff member
+// Staff represents a staff member. The PIN is only ever accepted on create
+// and update; the database keeps a hash of it.
 type Staff struct {
-	ID          int       `json:"id" db:"id"`
-	StaffID     string    `json:"staffId" db:"staffId"`
-	Name        string    `json:"name" db:"name"`
-	CreatedAt   time.Time `json:"createdAt" db:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updatedAt"`
+	ID           int        `json:"id" db:"id"`
+	StaffID      string     `json:"staffId" db:"staffId"`
+	Name         string     `json:"name" db:"name"`
+	Role         string     `json:"role" db:"role"`
+	StoreID      *int       `json:"storeId,omitempty" db:"storeId"` // the store a store manager manages
+	PIN          string     `json:"pin,omitempty" db:"-"`
+	PINHash      string     `json:"-" db:"pinHash"`
+	HasPIN       bool       `json:"hasPin" db:"-"`
+	FailedLogins int        `json:"-" db:"failedLogins"`
+	LockedUntil  *time.Time `json:"-" db:"lockedUntil"`
+	CreatedAt    time.Time  `json:"createdAt" db:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt" db:"updatedAt"`
 }
 
+// Sale types. Voids and refunds are stored as separate sale rows with
+// negative amounts that point back to the original sale.
+const (
+	SaleTypeSale   = "sale"
+	SaleTypeVoid   = "void"
+	SaleTypeRefund = "refund"
+)
+
 // Sale represents a sale transaction
 type Sale struct {
-	ID          int          `json:"id" db:"id"`
-	StoreID     int          `json:"storeId" db:"storeId"`
-	StaffID     int          `json:"staffId" db:"staffId"`
-	TotalPrice  int          `json:"totalPrice" db:"totalPrice"`
-	Deposit     int          `json:"deposit" db:"deposit"`
-	SaleAt      time.Time    `json:"saleAt" db:"saleAt"`
-	Details     []SaleDetail `json:"details,omitempty"`
-	Store       *Store       `json:"store,omitempty"`
-	Staff       *Staff       `json:"staff,omitempty"`
-	CreatedAt   time.Time    `json:"createdAt" db:"createdAt"`
-	UpdatedAt   time.Time    `json:"updatedAt" db:"updatedAt"`
+	ID              int           `json:"id" db:"id"`
+	ClientID        string        `json:"clientId,omitempty"`
+	StoreID         int           `json:"storeId" db:"storeId"`
+	StaffID         int           `json:"staffId" db:"staffId"`
+	Subtotal        int           `json:"subtotal" db:"subtotal"`
+	Tax             int           `json:"tax" db:"tax"`
+	TaxRate         float64       `json:"taxRate" db:"taxRate"`
+	TaxRounding     string        `json:"taxRounding" db:"taxRounding"`
+	TotalPrice      int           `json:"totalPrice" db:"totalPrice"`
+	Deposit         int           `json:"deposit" db:"deposit"`
+	Change          int           `json:"change" db:"change"`
+	ChangeBreakdown []ChangeCount `json:"changeBreakdown,omitempty"`
+	SaleAt          time.Time     `json:"saleAt" db:"saleAt"`
+	Type            string        `json:"type" db:"type"`
+	OriginalSaleID  *int          `json:"originalSaleId,omitempty" db:"originalSaleId"`
+	Reason          string        `json:"reason,omitempty" db:"reason"`
+	DeviceID        *int          `json:"deviceId,omitempty" db:"deviceId"` // the register tablet that took the sale
+	Details         []SaleDetail  `json:"details,omitempty"`
+	Store           *Store        `json:"store,omitempty"`
+	Staff           *Staff        `json:"staff,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt" db:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt" db:"updatedAt"`
+}
+
+// Outcomes of a sale in a batch upload
+const (
+	BatchSaleCreated   = "created"
+	BatchSaleDuplicate = "duplicate"
+	BatchSaleRejected  = "rejected"
+)
+
+// BatchSaleResult reports what happened to one sale of a batch upload
+type BatchSaleResult struct {
+	ClientID string `json:"clientId"`
+	Status   string `json:"status"`
+	SaleID   int    `json:"saleId,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Denomination kinds
+const (
+	DenominationCoin = "coin"
+	DenominationBill = "bill"
+)
+
+// Denomination is a coin or bill of a currency, in its smallest unit
+type Denomination struct {
+	Value int    `json:"value"`
+	Kind  string `json:"kind"`
+}
+
+// ChangeCount is how many of one denomination to hand back as change
+type ChangeCount struct {
+	Denomination
+	Count int `json:"count"`
 }
 
-// SaleDetail represents a sale detail item
+// SaleFilter narrows down sales by period, store and staff.
+// Zero values leave the corresponding condition out.
+type SaleFilter struct {
+	From    time.Time // inclusive
+	To      time.Time // exclusive
+	StoreID int
+	StaffID int
+}
+
+// SaleDetail represents a sale detail item. Price is always taken from the
+// catalog (ListPrice) unless the client explicitly sends OverridePrice with
+// an OverrideReason.
 type SaleDetail struct {
-	ID          int       `json:"id" db:"id"`
-	SaleID      int       `json:"saleId" db:"saleId"`
-	ItemID      int       `json:"itemId" db:"itemId"`
-	Quantity    int       `json:"quantity" db:"quantity"`
-	Price       int       `json:"price" db:"price"`
-	Item        *Item     `json:"item,omitempty"`
-	CreatedAt   time.Time `json:"createdAt" db:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updatedAt"`
+	ID               int       `json:"id" db:"id"`
+	SaleID           int       `json:"saleId" db:"saleId"`
+	ItemID           int       `json:"itemId" db:"itemId"`
+	Quantity         int       `json:"quantity" db:"quantity"`
+	Price            int       `json:"price" db:"price"`
+	ListPrice        int       `json:"listPrice" db:"listPrice"`
+	PriceOverridden  bool      `json:"priceOverridden" db:"priceOverridden"`
+	OverrideReason   string    `json:"overrideReason,omitempty" db:"overrideReason"`
+	TaxExcluded      bool      `json:"taxExcluded" db:"taxExcluded"`
+	OverridePrice    *int      `json:"overridePrice,omitempty" db:"-"`
+	OriginalDetailID *int      `json:"originalDetailId,omitempty" db:"originalDetailId"`
+	Item             *Item     `json:"item,omitempty"`
+	CreatedAt        time.Time `json:"createdAt" db:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt" db:"updatedAt"`
+}
+
+// Subtotal returns the line total of a sale detail
+func (d SaleDetail) Subtotal() int {
+	return d.Price * d.Quantity
+}
+
+// RefundLine selects a quantity of an original sale detail to refund.
+// A zero quantity refunds whatever is left of the line.
+type RefundLine struct {
+	DetailID int `json:"detailId"`
+	Quantity int `json:"quantity"`
 }
 
 // Setting represents a configuration setting
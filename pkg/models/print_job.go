@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Print job states
+const (
+	PrintJobQueued = "queued"
+	PrintJobDone   = "done"
+	PrintJobFailed = "failed"
+)
+
+// PrintJob is a receipt waiting to be, or already, sent to a printer
+type PrintJob struct {
+	ID            int        `json:"id" db:"id"`
+	SaleID        *int       `json:"saleId,omitempty" db:"saleId"`
+	Printer       string     `json:"printer" db:"printer"`
+	Data          []byte     `json:"-" db:"data"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     string     `json:"lastError,omitempty" db:"lastError"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" db:"nextAttemptAt"`
+	PrintedAt     *time.Time `json:"printedAt,omitempty" db:"printedAt"`
+	CreatedAt     time.Time  `json:"createdAt" db:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updatedAt"`
+}
+
+// PrinterStatus summarizes the print queue of one printer
+type PrinterStatus struct {
+	Printer       string     `json:"printer"`
+	Queued        int        `json:"queued"`
+	Failed        int        `json:"failed"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastPrintedAt *time.Time `json:"lastPrintedAt,omitempty"`
+}
@@ -0,0 +1,82 @@
+package models
+
+// Staff roles
+const (
+	RoleCashier      = "cashier"       // a child at the register
+	RoleStoreManager = "store_manager" // manages the items and sales of one store
+	RoleAdmin        = "admin"         // a teacher, manages everything
+)
+
+// Permission is something a role may do
+type Permission string
+
+// Permissions checked by the routes. Store managers hold the items and
+// sales permissions for their own store only.
+const (
+	PermissionItemsView      Permission = "items.view"
+	PermissionItemsManage    Permission = "items.manage"
+	PermissionSalesCreate    Permission = "sales.create"
+	PermissionSalesManage    Permission = "sales.manage"
+	PermissionPrintManage    Permission = "print.manage"
+	PermissionStoresManage   Permission = "stores.manage"
+	PermissionStaffManage    Permission = "staff.manage"
+	PermissionSettingsManage Permission = "settings.manage"
+	PermissionApkManage      Permission = "apk.manage"
+	PermissionDevicesManage  Permission = "devices.manage"
+	PermissionAuditView      Permission = "audit.view"
+	PermissionBackupManage   Permission = "backup.manage"
+)
+
+// rolePermissions lists what each role may do
+var rolePermissions = map[string][]Permission{
+	RoleCashier: {
+		PermissionItemsView,
+		PermissionSalesCreate,
+	},
+	RoleStoreManager: {
+		PermissionItemsView,
+		PermissionItemsManage,
+		PermissionSalesCreate,
+		PermissionSalesManage,
+		PermissionPrintManage,
+	},
+	RoleAdmin: {
+		PermissionItemsView,
+		PermissionItemsManage,
+		PermissionSalesCreate,
+		PermissionSalesManage,
+		PermissionPrintManage,
+		PermissionStoresManage,
+		PermissionStaffManage,
+		PermissionSettingsManage,
+		PermissionApkManage,
+		PermissionDevicesManage,
+		PermissionAuditView,
+		PermissionBackupManage,
+	},
+}
+
+// ValidRole reports whether role is one of the staff roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can reports whether the staff member's role grants the permission
+func (s *Staff) Can(permission Permission) bool {
+	for _, p := range rolePermissions[s.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopedStoreID returns the store a store manager is limited to, or 0 for
+// staff members whose permissions cover every store
+func (s *Staff) ScopedStoreID() int {
+	if s.Role == RoleStoreManager && s.StoreID != nil {
+		return *s.StoreID
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaffCan(t *testing.T) {
+	storeID := 2
+	cashier := &Staff{Role: RoleCashier}
+	manager := &Staff{Role: RoleStoreManager, StoreID: &storeID}
+	admin := &Staff{Role: RoleAdmin}
+
+	assert.True(t, cashier.Can(PermissionSalesCreate))
+	assert.False(t, cashier.Can(PermissionItemsManage))
+	assert.True(t, manager.Can(PermissionItemsManage))
+	assert.False(t, manager.Can(PermissionStaffManage))
+	assert.True(t, admin.Can(PermissionApkManage))
+	assert.False(t, (&Staff{Role: "owner"}).Can(PermissionItemsView))
+
+	assert.Equal(t, 0, cashier.ScopedStoreID())
+	assert.Equal(t, 2, manager.ScopedStoreID())
+	assert.Equal(t, 0, admin.ScopedStoreID())
+
+	assert.True(t, ValidRole(RoleStoreManager))
+	assert.False(t, ValidRole(""))
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session kinds
+const (
+	SessionWeb = "web" // cookie session of the web UI
+	SessionAPI = "api" // bearer token of a register
+)
+
+// StaffSession is a logged in staff member. The token is only known right
+// after login; the database keeps a hash of it.
+type StaffSession struct {
+	Token     string    `json:"token,omitempty"`
+	Kind      string    `json:"kind"`
+	StaffID   int       `json:"staffId"`
+	Staff     *Staff    `json:"staff,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
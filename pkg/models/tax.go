@@ -0,0 +1,60 @@
+package models
+
+import "math"
+
+// Tax rounding rules
+const (
+	TaxRoundingFloor = "floor"
+	TaxRoundingRound = "round"
+	TaxRoundingCeil  = "ceil"
+)
+
+// ValidTaxRounding reports whether rounding is a known tax rounding rule
+func ValidTaxRounding(rounding string) bool {
+	switch rounding {
+	case TaxRoundingFloor, TaxRoundingRound, TaxRoundingCeil:
+		return true
+	}
+	return false
+}
+
+// ApplyTax works out the subtotal, tax and total of a sale from its details.
+// Tax is rounded once per sale for tax-exclusive lines and once for
+// tax-inclusive lines, so the lines of a receipt add up to its total.
+// Negative amounts, as on refunds, are rounded like the positive amount.
+func (s *Sale) ApplyTax(rate float64, rounding string) {
+	var inclusive, exclusive int
+	for _, detail := range s.Details {
+		if detail.TaxExcluded {
+			exclusive += detail.Subtotal()
+		} else {
+			inclusive += detail.Subtotal()
+		}
+	}
+
+	// Work in hundredths of a percent so that rates like 8.5 stay exact
+	basisPoints := int(math.Round(rate * 100))
+	exclusiveTax := divideRounded(exclusive*basisPoints, 10000, rounding)
+	inclusiveTax := divideRounded(inclusive*basisPoints, 10000+basisPoints, rounding)
+
+	s.TaxRate = rate
+	s.TaxRounding = rounding
+	s.Tax = exclusiveTax + inclusiveTax
+	s.Subtotal = exclusive + inclusive - inclusiveTax
+	s.TotalPrice = s.Subtotal + s.Tax
+}
+
+func divideRounded(n, d int, rounding string) int {
+	if n < 0 {
+		return -divideRounded(-n, d, rounding)
+	}
+
+	switch rounding {
+	case TaxRoundingCeil:
+		return (n + d - 1) / d
+	case TaxRoundingRound:
+		return (2*n + d) / (2 * d)
+	default:
+		return n / d
+	}
+}
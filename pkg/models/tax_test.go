@@ -0,0 +1,99 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaleApplyTax(t *testing.T) {
+	tests := []struct {
+		name     string
+		details  []SaleDetail
+		rate     float64
+		rounding string
+		subtotal int
+		tax      int
+		total    int
+	}{
+		{
+			name:     "tax-inclusive prices keep their total",
+			details:  []SaleDetail{{Price: 110, Quantity: 2}},
+			rate:     10,
+			rounding: TaxRoundingFloor,
+			subtotal: 200, tax: 20, total: 220,
+		},
+		{
+			name:     "tax-exclusive prices get tax added",
+			details:  []SaleDetail{{Price: 100, Quantity: 1, TaxExcluded: true}},
+			rate:     10,
+			rounding: TaxRoundingFloor,
+			subtotal: 100, tax: 10, total: 110,
+		},
+		{
+			name: "mixed",
+			details: []SaleDetail{
+				{Price: 110, Quantity: 1},
+				{Price: 100, Quantity: 1, TaxExcluded: true},
+			},
+			rate:     10,
+			rounding: TaxRoundingFloor,
+			subtotal: 200, tax: 20, total: 220,
+		},
+		{
+			name:     "floor",
+			details:  []SaleDetail{{Price: 15, Quantity: 1, TaxExcluded: true}},
+			rate:     10,
+			rounding: TaxRoundingFloor,
+			subtotal: 15, tax: 1, total: 16,
+		},
+		{
+			name:     "round",
+			details:  []SaleDetail{{Price: 15, Quantity: 1, TaxExcluded: true}},
+			rate:     10,
+			rounding: TaxRoundingRound,
+			subtotal: 15, tax: 2, total: 17,
+		},
+		{
+			name:     "ceil",
+			details:  []SaleDetail{{Price: 11, Quantity: 1, TaxExcluded: true}},
+			rate:     10,
+			rounding: TaxRoundingCeil,
+			subtotal: 11, tax: 2, total: 13,
+		},
+		{
+			name:     "fractional rate",
+			details:  []SaleDetail{{Price: 1000, Quantity: 1, TaxExcluded: true}},
+			rate:     8.5,
+			rounding: TaxRoundingFloor,
+			subtotal: 1000, tax: 85, total: 1085,
+		},
+		{
+			name:     "refunds round like sales",
+			details:  []SaleDetail{{Price: 15, Quantity: -1, TaxExcluded: true}},
+			rate:     10,
+			rounding: TaxRoundingFloor,
+			subtotal: -15, tax: -1, total: -16,
+		},
+		{
+			name:     "no tax",
+			details:  []SaleDetail{{Price: 100, Quantity: 3}},
+			rate:     0,
+			rounding: TaxRoundingFloor,
+			subtotal: 300, tax: 0, total: 300,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sale := &Sale{Details: tt.details}
+			sale.ApplyTax(tt.rate, tt.rounding)
+
+			assert.Equal(t, tt.subtotal, sale.Subtotal)
+			assert.Equal(t, tt.tax, sale.Tax)
+			assert.Equal(t, tt.total, sale.TotalPrice)
+			assert.Equal(t, tt.rate, sale.TaxRate)
+			assert.Equal(t, tt.rounding, sale.TaxRounding)
+		})
+	}
+}
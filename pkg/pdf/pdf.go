@@ -0,0 +1,159 @@
+// Package pdf writes minimal PDF documents.
+//
+// Only what receipts and labels need is supported: pages of any size, text
+// in one Japanese font and filled rectangles. The font is not embedded; PDF viewers
+// substitute their own Japanese gothic font. Everything is produced with
+// the standard library so PDFs also work on an offline Raspberry Pi.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/width"
+)
+
+// MM is the number of PDF points in a millimeter
+const MM = 72 / 25.4
+
+// Document is an in-memory PDF document
+type Document struct {
+	pages []*Page
+}
+
+// Page is a single page of a document. Coordinates are in points, measured
+// from the top left corner.
+type Page struct {
+	width   float64
+	height  float64
+	content bytes.Buffer
+}
+
+// New creates an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a page of the given size in points
+func (d *Document) AddPage(width, height float64) *Page {
+	page := &Page{width: width, height: height}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Text draws s with its baseline starting at x, y
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	p.content.WriteString("BT\n")
+	if bold {
+		// Stroking the outlines as well as filling them thickens the glyphs
+		fmt.Fprintf(&p.content, "2 Tr %s w\n", number(size*0.04))
+	} else {
+		p.content.WriteString("0 Tr\n")
+	}
+	fmt.Fprintf(&p.content, "/F1 %s Tf\n%s %s Td\n<%s> Tj\nET\n",
+		number(size), number(x), number(p.height-y), encodeText(s))
+}
+
+// Rect fills a black rectangle whose top left corner is at x, y
+func (p *Page) Rect(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n",
+		number(x), number(p.height-y-height), number(width), number(height))
+}
+
+// TextWidth returns the width of s in points at the given font size.
+// Japanese full-width characters are square, everything else half as wide.
+func TextWidth(s string, size float64) float64 {
+	n := 0
+	for _, r := range s {
+		switch width.LookupRune(r).Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			n += 2
+		default:
+			n++
+		}
+	}
+	return float64(n) * size / 2
+}
+
+// Write encodes the document as a PDF file
+func (d *Document) Write(w io.Writer) error {
+	if len(d.pages) == 0 {
+		return fmt.Errorf("document has no pages")
+	}
+
+	// Objects 1-5 are the catalog, the page tree and the font; every page
+	// then takes two objects, the page and its content stream.
+	var objects []string
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /HeiseiKakuGo-W5 /Encoding /UniJIS-UCS2-HW-H /DescendantFonts [4 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /HeiseiKakuGo-W5"+
+			" /CIDSystemInfo << /Registry (Adobe) /Ordering (Japan1) /Supplement 2 >>"+
+			" /FontDescriptor 5 0 R /DW 1000 /W [1 95 500 231 389 500] >>",
+		"<< /Type /FontDescriptor /FontName /HeiseiKakuGo-W5 /Flags 4 /FontBBox [-92 -250 1010 922]"+
+			" /ItalicAngle 0 /Ascent 752 /Descent -221 /CapHeight 737 /StemV 114 >>",
+	)
+
+	for i, page := range d.pages {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.content.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				number(page.width), number(page.height), 7+2*i),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.String()),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// encodeText encodes s as UTF-16BE hex for the UCS-2 CMap. Characters
+// outside the Basic Multilingual Plane are replaced.
+func encodeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xffff || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// number formats a coordinate without needless digits
+func number(f float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.2f", f), "0")
+	return strings.TrimSuffix(s, ".")
+}
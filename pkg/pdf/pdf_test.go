@@ -0,0 +1,77 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextWidth(t *testing.T) {
+	assert.Equal(t, 25.0, TextWidth("Candy", 10))
+	assert.Equal(t, 20.0, TextWidth("合計", 10))
+	assert.Equal(t, 0.0, TextWidth("", 10))
+}
+
+func TestDocument_Write(t *testing.T) {
+	t.Run("writes a valid cross-reference table", func(t *testing.T) {
+		doc := New()
+		doc.AddPage(80*MM, 100).Text(10, 20, 9, false, "合計 ¥100")
+		doc.AddPage(80*MM, 100).Text(10, 20, 18, true, "Shop")
+
+		var buf bytes.Buffer
+		require.NoError(t, doc.Write(&buf))
+		data := buf.Bytes()
+
+		assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+		assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+		assert.Contains(t, string(data), "/Count 2")
+
+		// startxref points at the table and every entry at its object
+		match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+		require.NotNil(t, match)
+		xref, _ := strconv.Atoi(string(match[1]))
+		require.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n0 10\n")))
+
+		entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+		require.Len(t, entries, 9)
+		for i, entry := range entries {
+			offset, _ := strconv.Atoi(string(entry[1]))
+			assert.True(t, bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+		}
+	})
+
+	t.Run("encodes text as UTF-16", func(t *testing.T) {
+		doc := New()
+		page := doc.AddPage(100, 100)
+		page.Text(10, 20, 9, false, "合計 ¥100")
+
+		// 合 計 space ¥ 1 0 0
+		assert.Contains(t, page.content.String(), "<54088A08002000A5003100300030> Tj")
+		// y is measured from the top
+		assert.Contains(t, page.content.String(), "10 80 Td")
+
+		page.Rect(10, 20, 5, 2.5)
+		assert.Contains(t, page.content.String(), "10 77.5 5 2.5 re f")
+
+		var buf bytes.Buffer
+		require.NoError(t, doc.Write(&buf))
+		start := bytes.Index(buf.Bytes(), []byte("stream\n")) + len("stream\n")
+		zr, err := zlib.NewReader(bytes.NewReader(buf.Bytes()[start:]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, page.content.String(), string(content))
+	})
+
+	t.Run("fails without pages", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Error(t, New().Write(&buf))
+	})
+}
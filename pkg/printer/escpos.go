@@ -0,0 +1,73 @@
+// Package printer sends receipts to ESC/POS thermal printers over raw TCP
+package printer
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/receipt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// ESC/POS commands
+var (
+	cmdInit          = []byte{0x1b, '@'}        // ESC @: reset the printer
+	cmdKanjiShiftJIS = []byte{0x1c, 'C', 0x01}  // FS C 1: Kanji characters are Shift_JIS
+	cmdKanjiOn       = []byte{0x1c, '&'}        // FS &: enable Kanji mode
+	cmdAlignLeft     = []byte{0x1b, 'a', 0x00}  // ESC a 0
+	cmdAlignCenter   = []byte{0x1b, 'a', 0x01}  // ESC a 1
+	cmdAlignRight    = []byte{0x1b, 'a', 0x02}  // ESC a 2
+	cmdBoldOn        = []byte{0x1b, 'E', 0x01}  // ESC E 1
+	cmdBoldOff       = []byte{0x1b, 'E', 0x00}  // ESC E 0
+	cmdSizeDouble    = []byte{0x1d, '!', 0x11}  // GS ! 0x11: double width and height
+	cmdSizeNormal    = []byte{0x1d, '!', 0x00}  // GS ! 0
+	cmdFeedAndCut    = []byte{0x1d, 'V', 66, 3} // GS V 66 n: feed n lines and cut partially
+)
+
+// EncodeReceipt turns receipt lines into ESC/POS commands. Text is sent as
+// Shift_JIS so that Japanese printers print kana and kanji. Japanese printers
+// print 0x5c as the yen sign; other characters Shift_JIS cannot represent
+// are replaced.
+func EncodeReceipt(lines []receipt.Line) ([]byte, error) {
+	encoder := encoding.ReplaceUnsupported(japanese.ShiftJIS.NewEncoder())
+
+	var b bytes.Buffer
+	b.Write(cmdInit)
+	b.Write(cmdKanjiShiftJIS)
+	b.Write(cmdKanjiOn)
+
+	for _, line := range lines {
+		switch line.Align {
+		case receipt.AlignCenter:
+			b.Write(cmdAlignCenter)
+		case receipt.AlignRight:
+			b.Write(cmdAlignRight)
+		default:
+			b.Write(cmdAlignLeft)
+		}
+		if line.Bold {
+			b.Write(cmdBoldOn)
+		}
+		if line.Large {
+			b.Write(cmdSizeDouble)
+		}
+
+		text, err := encoder.String(strings.ReplaceAll(line.Text, "¥", `\`))
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(text)
+		b.WriteByte('\n')
+
+		if line.Large {
+			b.Write(cmdSizeNormal)
+		}
+		if line.Bold {
+			b.Write(cmdBoldOff)
+		}
+	}
+
+	b.Write(cmdFeedAndCut)
+	return b.Bytes(), nil
+}
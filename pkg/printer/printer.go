@@ -0,0 +1,42 @@
+package printer
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultTimeout bounds connecting to and writing to a printer
+const DefaultTimeout = 5 * time.Second
+
+// Printer is a network receipt printer accepting raw ESC/POS on a TCP port,
+// usually 9100
+type Printer struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+// New returns a printer at host:port
+func New(host, port string) *Printer {
+	return &Printer{
+		Addr:    net.JoinHostPort(host, port),
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Print sends raw ESC/POS data to the printer
+func (p *Printer) Print(data []byte) error {
+	conn, err := net.DialTimeout("tcp", p.Addr, p.Timeout)
+	if err != nil {
+		return fmt.Errorf("printer %s is not reachable: %w", p.Addr, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(p.Timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("failed to send receipt to printer %s: %w", p.Addr, err)
+	}
+	return nil
+}
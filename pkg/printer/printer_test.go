@@ -0,0 +1,77 @@
+package printer
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/receipt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePrinter accepts one connection and records the bytes it receives
+func fakePrinter(t *testing.T) (addr string, received <-chan []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	ch := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		ch <- data
+	}()
+	return listener.Addr().String(), ch
+}
+
+func TestEncodeReceipt(t *testing.T) {
+	data, err := EncodeReceipt([]receipt.Line{
+		{Text: "ショップ", Align: receipt.AlignCenter, Bold: true, Large: true},
+		{Text: "合計 ¥100"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, []byte{0x1b, '@', 0x1c, 'C', 0x01, 0x1c, '&'}))
+	assert.True(t, bytes.HasSuffix(data, []byte{0x1d, 'V', 66, 3}))
+
+	// Centered, bold, double size shop name in Shift_JIS
+	assert.Contains(t, string(data), "\x1ba\x01\x1bE\x01\x1d!\x11\x83V\x83\x87\x83b\x83v\n\x1d!\x00\x1bE\x00")
+	// 合計 in Shift_JIS, yen sign as 0x5c
+	assert.Contains(t, string(data), "\x1ba\x00\x8d\x87\x8cv \x5c100\n")
+}
+
+func TestPrinterPrint(t *testing.T) {
+	t.Run("sends the data", func(t *testing.T) {
+		addr, received := fakePrinter(t)
+		host, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+
+		require.NoError(t, New(host, port).Print([]byte("hello")))
+
+		select {
+		case data := <-received:
+			assert.Equal(t, []byte("hello"), data)
+		case <-time.After(time.Second):
+			t.Fatal("printer received nothing")
+		}
+	})
+
+	t.Run("printer offline", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		p := &Printer{Addr: addr, Timeout: time.Second}
+		err = p.Print([]byte("hello"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not reachable")
+	})
+}
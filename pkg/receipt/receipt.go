@@ -0,0 +1,174 @@
+// Package receipt lays out sale receipts independently of where they are
+// printed or displayed. Every output format renders the same lines.
+package receipt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"golang.org/x/text/width"
+)
+
+// Width is the number of half-width columns on a receipt. 42 columns fit
+// both 58mm and 80mm thermal paper with the printer's standard font.
+const Width = 42
+
+// Align is the horizontal alignment of a receipt line
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Line is one line of a receipt
+type Line struct {
+	Text  string
+	Align Align
+	Bold  bool
+	// Large lines are printed at double width and height
+	Large bool
+}
+
+// Receipt holds everything printed on the receipt of a sale
+type Receipt struct {
+	ShopName string
+	Footer   string
+	Currency string
+	Sale     *models.Sale
+}
+
+// Lines lays the receipt out for the given number of columns
+func (r *Receipt) Lines(columns int) []Line {
+	sale := r.Sale
+	separator := Line{Text: strings.Repeat("-", columns)}
+
+	lines := []Line{
+		{Text: r.ShopName, Align: AlignCenter, Bold: true, Large: true},
+		{},
+		{Text: twoColumns(sale.SaleAt.Format("2006/01/02 15:04"), fmt.Sprintf("No.%d", sale.ID), columns)},
+	}
+	if sale.Store != nil && sale.Store.Name != "" {
+		lines = append(lines, Line{Text: "店舗: " + sale.Store.Name})
+	}
+	if sale.Staff != nil && sale.Staff.Name != "" {
+		lines = append(lines, Line{Text: "担当: " + sale.Staff.Name})
+	}
+	switch sale.Type {
+	case models.SaleTypeVoid:
+		lines = append(lines, Line{Text: "*** 取消 ***", Align: AlignCenter, Bold: true})
+	case models.SaleTypeRefund:
+		lines = append(lines, Line{Text: "*** 返品 ***", Align: AlignCenter, Bold: true})
+	}
+	lines = append(lines, separator)
+
+	for _, detail := range sale.Details {
+		name := fmt.Sprintf("#%d", detail.ItemID)
+		if detail.Item != nil {
+			name = detail.Item.Name
+		}
+		if detail.TaxExcluded {
+			name += " (税抜)"
+		}
+		lines = append(lines, Line{Text: name})
+
+		unit := fmt.Sprintf("  %s x %d", FormatMoney(detail.Price, r.Currency), detail.Quantity)
+		if detail.PriceOverridden {
+			unit += " (価格変更)"
+		}
+		lines = append(lines, Line{Text: twoColumns(unit, FormatMoney(detail.Subtotal(), r.Currency), columns)})
+	}
+	lines = append(lines, separator)
+
+	lines = append(lines,
+		Line{Text: twoColumns("小計(税抜)", FormatMoney(sale.Subtotal, r.Currency), columns)},
+		Line{Text: twoColumns(fmt.Sprintf("消費税(%g%%)", sale.TaxRate), FormatMoney(sale.Tax, r.Currency), columns)},
+		Line{Text: twoColumns("合計", FormatMoney(sale.TotalPrice, r.Currency), columns), Bold: true},
+	)
+	if sale.Type == models.SaleTypeSale {
+		lines = append(lines,
+			Line{Text: twoColumns("お預かり", FormatMoney(sale.Deposit, r.Currency), columns)},
+			Line{Text: twoColumns("お釣り", FormatMoney(sale.Change, r.Currency), columns)},
+		)
+	}
+
+	if r.Footer != "" {
+		lines = append(lines, separator)
+		for _, text := range strings.Split(r.Footer, "\n") {
+			lines = append(lines, Line{Text: strings.TrimRight(text, "\r"), Align: AlignCenter})
+		}
+	}
+	return lines
+}
+
+// currencyFormats maps currency codes to their symbol and minor unit digits
+var currencyFormats = map[string]struct {
+	symbol   string
+	decimals int
+}{
+	"JPY": {"¥", 0},
+	"USD": {"$", 2},
+	"EUR": {"€", 2},
+}
+
+// FormatMoney formats an amount given in the currency's smallest unit,
+// e.g. 1234 JPY as ¥1,234 and 1234 USD as $12.34
+func FormatMoney(amount int, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	format, ok := currencyFormats[strings.ToUpper(currency)]
+	if !ok {
+		return fmt.Sprintf("%s%s %s", sign, groupThousands(amount), currency)
+	}
+	if format.decimals == 0 {
+		return sign + format.symbol + groupThousands(amount)
+	}
+
+	unit := 1
+	for i := 0; i < format.decimals; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%s%s.%0*d", sign, format.symbol, groupThousands(amount/unit), format.decimals, amount%unit)
+}
+
+func groupThousands(n int) string {
+	digits := fmt.Sprint(n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// TextWidth returns how many half-width columns s takes up. Japanese
+// full-width characters take two.
+func TextWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		switch width.LookupRune(r).Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// twoColumns puts left and right on one line, right-aligned to columns
+func twoColumns(left, right string, columns int) string {
+	gap := columns - TextWidth(left) - TextWidth(right)
+	if gap < 1 {
+		gap = 1
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
@@ -0,0 +1,82 @@
+package receipt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatMoney(t *testing.T) {
+	assert.Equal(t, "¥0", FormatMoney(0, "JPY"))
+	assert.Equal(t, "¥1,234,567", FormatMoney(1234567, "JPY"))
+	assert.Equal(t, "-¥100", FormatMoney(-100, "jpy"))
+	assert.Equal(t, "$12.05", FormatMoney(1205, "USD"))
+	assert.Equal(t, "€1,000.00", FormatMoney(100000, "EUR"))
+	assert.Equal(t, "500 KRW", FormatMoney(500, "KRW"))
+}
+
+func TestTextWidth(t *testing.T) {
+	assert.Equal(t, 5, TextWidth("Candy"))
+	assert.Equal(t, 4, TextWidth("合計"))
+	assert.Equal(t, 4, TextWidth("¥100"))
+	assert.Equal(t, 6, TextWidth("ｱｲｳｴｵ!"))
+}
+
+func TestReceiptLines(t *testing.T) {
+	r := &Receipt{
+		ShopName: "こどもショップ",
+		Footer:   "ありがとう!\nまたきてね",
+		Currency: "JPY",
+		Sale: &models.Sale{
+			ID:         12,
+			Type:       models.SaleTypeSale,
+			SaleAt:     time.Date(2024, 7, 20, 10, 30, 0, 0, time.Local),
+			Subtotal:   200,
+			Tax:        20,
+			TaxRate:    10,
+			TotalPrice: 220,
+			Deposit:    500,
+			Change:     280,
+			Staff:      &models.Staff{Name: "たろう"},
+			Details: []models.SaleDetail{
+				{ItemID: 1, Quantity: 2, Price: 110, Item: &models.Item{Name: "あめ"}},
+			},
+		},
+	}
+
+	lines := r.Lines(Width)
+
+	assert.Equal(t, Line{Text: "こどもショップ", Align: AlignCenter, Bold: true, Large: true}, lines[0])
+
+	var texts []string
+	for _, line := range lines {
+		texts = append(texts, line.Text)
+		if line.Align == AlignLeft && strings.Contains(line.Text, "¥") {
+			// Amounts are right-aligned to the receipt width
+			assert.Equal(t, Width, TextWidth(line.Text), line.Text)
+		}
+	}
+	text := strings.Join(texts, "\n")
+
+	assert.Contains(t, text, "2024/07/20 10:30")
+	assert.Contains(t, text, "No.12")
+	assert.Contains(t, text, "担当: たろう")
+	assert.Contains(t, text, "あめ\n  ¥110 x 2")
+	assert.Contains(t, text, "消費税(10%)")
+	assert.Contains(t, text, "お預かり")
+	assert.Contains(t, text, "¥280")
+	assert.Contains(t, text, "ありがとう!\nまたきてね")
+
+	t.Run("refunds show no deposit", func(t *testing.T) {
+		r.Sale.Type = models.SaleTypeRefund
+		text := ""
+		for _, line := range r.Lines(Width) {
+			text += line.Text + "\n"
+		}
+		assert.Contains(t, text, "返品")
+		assert.NotContains(t, text, "お預かり")
+	})
+}
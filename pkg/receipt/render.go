@@ -0,0 +1,141 @@
+package receipt
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/pdf"
+)
+
+// Text renders the receipt as plain text, Width columns wide
+func (r *Receipt) Text() string {
+	var b strings.Builder
+	for _, line := range r.Lines(Width) {
+		pad := 0
+		switch line.Align {
+		case AlignCenter:
+			pad = (Width - TextWidth(line.Text)) / 2
+		case AlignRight:
+			pad = Width - TextWidth(line.Text)
+		}
+		if pad > 0 {
+			b.WriteString(strings.Repeat(" ", pad))
+		}
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// htmlTemplate shows the receipt lines in a Japanese monospace font, where
+// a full-width character is 1em and a half-width one 0.5em wide
+var htmlTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"lineClass": func(line Line) string {
+		classes := []string{"line"}
+		switch line.Align {
+		case AlignCenter:
+			classes = append(classes, "center")
+		case AlignRight:
+			classes = append(classes, "right")
+		}
+		if line.Bold {
+			classes = append(classes, "bold")
+		}
+		if line.Large {
+			classes = append(classes, "large")
+		}
+		return strings.Join(classes, " ")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}}</title>
+<style>
+body { margin: 1em; font-family: "BIZ UDGothic", "MS Gothic", "Osaka-Mono", "Noto Sans Mono CJK JP", monospace; font-size: 14px; }
+.receipt { width: {{.Width}}em; margin: 0 auto; }
+.line { white-space: pre; min-height: 1.3em; line-height: 1.3; }
+.center { text-align: center; }
+.right { text-align: right; }
+.bold { font-weight: bold; }
+.large { font-size: 2em; white-space: pre-wrap; }
+.actions { text-align: center; margin-top: 2em; }
+@page { margin: 5mm; }
+@media print {
+	body { margin: 0; }
+	.actions { display: none; }
+}
+</style>
+</head>
+<body>
+<div class="receipt">
+{{range .Lines}}<div class="{{lineClass .}}">{{.Text}}</div>
+{{end}}</div>
+<div class="actions"><button type="button" onclick="window.print()">印刷</button></div>
+</body>
+</html>
+`))
+
+// WriteHTML renders the receipt as an HTML page that prints from a browser
+func (r *Receipt) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		Width float64
+		Lines []Line
+	}{
+		Title: fmt.Sprintf("%s No.%d", r.ShopName, r.Sale.ID),
+		Width: float64(Width) / 2,
+		Lines: r.Lines(Width),
+	})
+}
+
+// Receipt PDFs are as wide as 80mm thermal paper and as long as needed
+const (
+	pdfPageWidth = 80 * pdf.MM
+	pdfMargin    = 5 * pdf.MM
+)
+
+// WritePDF renders the receipt as a single-page PDF
+func (r *Receipt) WritePDF(w io.Writer) error {
+	lines := r.Lines(Width)
+
+	// Fit Width half-width columns between the margins
+	textWidth := pdfPageWidth - 2*pdfMargin
+	size := textWidth / (float64(Width) / 2)
+
+	sizes := make([]float64, len(lines))
+	height := 2 * pdfMargin
+	for i, line := range lines {
+		sizes[i] = size
+		if line.Large {
+			sizes[i] = 2 * size
+			if lineWidth := pdf.TextWidth(line.Text, sizes[i]); lineWidth > textWidth {
+				sizes[i] *= textWidth / lineWidth
+			}
+		}
+		height += sizes[i] * 1.4
+	}
+
+	doc := pdf.New()
+	page := doc.AddPage(pdfPageWidth, height)
+	y := pdfMargin
+	for i, line := range lines {
+		y += sizes[i] * 1.4
+		x := pdfMargin
+		switch line.Align {
+		case AlignCenter:
+			x += (textWidth - pdf.TextWidth(line.Text, sizes[i])) / 2
+		case AlignRight:
+			x += textWidth - pdf.TextWidth(line.Text, sizes[i])
+		}
+		if line.Text != "" {
+			// The baseline sits above the bottom of the line for descenders
+			page.Text(x, y-sizes[i]*0.35, sizes[i], line.Bold, line.Text)
+		}
+	}
+
+	return doc.Write(w)
+}
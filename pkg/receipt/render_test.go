@@ -0,0 +1,64 @@
+package receipt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderTestReceipt() *Receipt {
+	return &Receipt{
+		ShopName: "Kids <Shop>",
+		Footer:   "Thank you!",
+		Currency: "USD",
+		Sale: &models.Sale{
+			ID:         7,
+			Type:       models.SaleTypeSale,
+			SaleAt:     time.Date(2024, 7, 20, 10, 30, 0, 0, time.Local),
+			Subtotal:   250,
+			TotalPrice: 250,
+			Deposit:    500,
+			Change:     250,
+			Details: []models.SaleDetail{
+				{ItemID: 1, Quantity: 1, Price: 250, Item: &models.Item{Name: "Candy"}},
+			},
+		},
+	}
+}
+
+func TestReceipt_Text(t *testing.T) {
+	text := renderTestReceipt().Text()
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	// The shop name and the footer are centered
+	assert.Equal(t, strings.Repeat(" ", (Width-11)/2)+"Kids <Shop>", lines[0])
+	assert.Equal(t, strings.Repeat(" ", (Width-10)/2)+"Thank you!", lines[len(lines)-1])
+	assert.Contains(t, text, "$2.50")
+	for _, line := range lines {
+		assert.LessOrEqual(t, TextWidth(line), Width, line)
+	}
+}
+
+func TestReceipt_WriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, renderTestReceipt().WriteHTML(&buf))
+	html := buf.String()
+
+	assert.Contains(t, html, `<div class="line center bold large">Kids &lt;Shop&gt;</div>`)
+	assert.Contains(t, html, "$2.50")
+	assert.Contains(t, html, "@media print")
+	assert.Contains(t, html, "width: 21em")
+}
+
+func TestReceipt_WritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, renderTestReceipt().WritePDF(&buf))
+
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	assert.Contains(t, buf.String(), "/Count 1")
+}
@@ -14,24 +14,10 @@ import (
 func setupAPKTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite", ":memory:")
 	require.NoError(t, err)
+	// Every connection to :memory: would get a database of its own
+	db.SetMaxOpenConns(1)
 
-	// Create apk_versions table
-	_, err = db.Exec(`
-		CREATE TABLE apk_versions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			version TEXT NOT NULL UNIQUE,
-			versionCode INTEGER NOT NULL,
-			fileName TEXT NOT NULL,
-			fileSize INTEGER NOT NULL,
-			filePath TEXT NOT NULL,
-			releaseNotes TEXT,
-			isActive INTEGER NOT NULL DEFAULT 1,
-			uploadedAt DATETIME NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	require.NoError(t, err)
+	require.NoError(t, RunMigrations(db))
 
 	return db
 }
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+)
+
+// AuditRepository handles audit log data access. Entries are only ever
+// added; staff and devices are not foreign keys, so that deleting them
+// keeps their history.
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// Create adds an entry to the audit log
+func (r *AuditRepository) Create(entry *models.AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	result, err := r.db.Exec(`INSERT INTO audit_log (entity, entityId, action, staffId, staffName, deviceId,
+			  beforeJson, afterJson, createdAt)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Entity, entry.EntityID, entry.Action, entry.StaffID, entry.StaffName, entry.DeviceID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+	return nil
+}
+
+// FindByFilter returns the entries matching the filter, newest first
+func (r *AuditRepository) FindByFilter(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := `SELECT a.id, a.entity, a.entityId, a.action, a.staffId, a.staffName, a.deviceId,
+			  COALESCE(d.name, ''), a.beforeJson, a.afterJson, a.createdAt
+			  FROM audit_log a
+			  LEFT JOIN device d ON a.deviceId = d.id`
+
+	var conditions []string
+	var args []interface{}
+	if filter.Entity != "" {
+		conditions = append(conditions, "a.entity = ?")
+		args = append(args, filter.Entity)
+	}
+	if filter.EntityID != "" {
+		conditions = append(conditions, "a.entityId = ?")
+		args = append(args, filter.EntityID)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "a.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.StaffID > 0 {
+		conditions = append(conditions, "a.staffId = ?")
+		args = append(args, filter.StaffID)
+	}
+	if filter.DeviceID > 0 {
+		conditions = append(conditions, "a.deviceId = ?")
+		args = append(args, filter.DeviceID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "a.createdAt >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "a.createdAt < ?")
+		args = append(args, filter.To)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY a.id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		var before, after sql.NullString
+		err := rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &entry.StaffID,
+			&entry.StaffName, &entry.DeviceID, &entry.DeviceName, &before, &after, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// nullJSON stores an empty document as NULL
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
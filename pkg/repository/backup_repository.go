@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteHeader starts every SQLite database file
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// backupTables must be in a file for it to be restored
+var backupTables = []string{"item", "store", "staff", "sale", "sale_detail", "setting"}
+
+// Restoring waits this long in total for other connections to let go
+const (
+	restoreRetries    = 50
+	restoreRetryDelay = 100 * time.Millisecond
+)
+
+// BackupRepository copies the live database to a file and back
+type BackupRepository struct {
+	db *sql.DB
+}
+
+// BackupTo writes a consistent copy of the database to path, which must not
+// exist yet. Sales can go on while it runs.
+func (r *BackupRepository) BackupTo(path string) error {
+	_, err := r.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// keptDeviceColumns are the columns of a device that survive a restore
+const keptDeviceColumns = `id, name, tokenHash, status, appVersion, lastIp, lastSeenAt,
+	approvedAt, revokedAt, createdAt, updatedAt`
+
+// RestoreFrom replaces the contents of the live database with the file at
+// path, which should have passed ValidateBackup. SQLite's backup API copies
+// it in one transaction, so every pooled connection sees either the old
+// data or the new. The schema is migrated afterwards, in case the file
+// comes from an older version.
+//
+// Devices and logins are not taken from the backup. The devices and staff
+// sessions of the live database are put back, so a device revoked since
+// the backup stays revoked, a logout stays a logout and whoever restores
+// stays logged in. Devices that only the backup knows are revoked, and
+// sessions of staff members the backup does not have are dropped.
+func (r *BackupRepository) RestoreFrom(path string) error {
+	devices, err := copyRows(r.db, `SELECT `+keptDeviceColumns+` FROM device`)
+	if err != nil {
+		return fmt.Errorf("failed to read devices: %w", err)
+	}
+	sessions, err := copyRows(r.db, `SELECT s.tokenHash, s.staffId, s.kind, s.expiresAt, s.createdAt, st.staffId
+		FROM staff_session s JOIN staff st ON st.id = s.staffId`)
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	if err := r.replaceWith(path); err != nil {
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+	if err := RunMigrations(r.db); err != nil {
+		return err
+	}
+
+	if err := r.putBack(devices, sessions); err != nil {
+		return fmt.Errorf("failed to keep devices and sessions: %w", err)
+	}
+	return nil
+}
+
+// replaceWith copies the database file at path over the live database
+func (r *BackupRepository) replaceWith(path string) error {
+	conn, err := r.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		restorer, ok := driverConn.(interface {
+			NewRestore(srcURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("database driver cannot restore backups")
+		}
+
+		for attempt := 1; ; attempt++ {
+			backup, err := restorer.NewRestore(path)
+			if err != nil {
+				return err
+			}
+			_, err = backup.Step(-1)
+			if finishErr := backup.Finish(); err == nil {
+				err = finishErr
+			}
+			if err == nil || !isBusy(err) || attempt == restoreRetries {
+				return err
+			}
+			time.Sleep(restoreRetryDelay)
+		}
+	})
+}
+
+// putBack writes the devices and sessions of the database as it was before
+// a restore over the restored ones
+func (r *BackupRepository) putBack(devices, sessions [][]interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE device SET status = ?, revokedAt = ?, updatedAt = ? WHERE status != ?`,
+		models.DeviceRevoked, now, now, models.DeviceRevoked)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		_, err := tx.Exec(`INSERT INTO device (`+keptDeviceColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET name = excluded.name, tokenHash = excluded.tokenHash,
+			status = excluded.status, appVersion = excluded.appVersion, lastIp = excluded.lastIp,
+			lastSeenAt = excluded.lastSeenAt, approvedAt = excluded.approvedAt,
+			revokedAt = excluded.revokedAt, createdAt = excluded.createdAt, updatedAt = excluded.updatedAt`,
+			device...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM staff_session`); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		// Only while the staff ID still belongs to the same staff member
+		_, err := tx.Exec(`INSERT INTO staff_session (tokenHash, staffId, kind, expiresAt, createdAt)
+			SELECT ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM staff WHERE id = ? AND staffId = ?)`,
+			session[0], session[1], session[2], session[3], session[4], session[1], session[5])
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyRows reads the rows of a query as they are
+func copyRows(db *sql.DB, query string) ([][]interface{}, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var copied [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		copied = append(copied, values)
+	}
+	return copied, rows.Err()
+}
+
+// ValidateBackup checks that the file at path is an intact KidsPOS database
+// whose schema this version can migrate. A backup from a newer version is
+// rejected here, before it replaces anything.
+func ValidateBackup(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil || !bytes.Equal(header, sqliteHeader) {
+		return fmt.Errorf("not a SQLite database")
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("failed to check database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("database is damaged: %s", result)
+	}
+
+	for _, table := range backupTables {
+		exists, err := tableExists(db, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("not a KidsPOS database: table %s is missing", table)
+		}
+	}
+
+	if _, err := PendingMigrations(db); err != nil {
+		return err
+	}
+	return nil
+}
+
+// isBusy reports whether err means another connection holds a lock
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+)
+
+// DeviceRepository handles register device data access. Devices are found
+// by a hash of their token, like staff sessions.
+type DeviceRepository struct {
+	db *sql.DB
+}
+
+const deviceColumns = `id, name, status, appVersion, lastIp, lastSeenAt, approvedAt, revokedAt,
+			  createdAt, updatedAt`
+
+func scanDevice(row interface{ Scan(...interface{}) error }) (*models.Device, error) {
+	device := &models.Device{}
+	err := row.Scan(&device.ID, &device.Name, &device.Status, &device.AppVersion, &device.LastIP,
+		&device.LastSeenAt, &device.ApprovedAt, &device.RevokedAt, &device.CreatedAt, &device.UpdatedAt)
+	return device, err
+}
+
+// FindAll returns every device, newest first
+func (r *DeviceRepository) FindAll() ([]*models.Device, error) {
+	rows, err := r.db.Query(`SELECT ` + deviceColumns + ` FROM device ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*models.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// FindByID finds a device by ID
+func (r *DeviceRepository) FindByID(id int) (*models.Device, error) {
+	device, err := scanDevice(r.db.QueryRow(`SELECT `+deviceColumns+` FROM device WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("device not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// FindByTokenHash finds the device a token was issued to
+func (r *DeviceRepository) FindByTokenHash(tokenHash string) (*models.Device, error) {
+	device, err := scanDevice(r.db.QueryRow(`SELECT `+deviceColumns+` FROM device WHERE tokenHash = ?`, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("device not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// Create stores a newly registered device under the hash of its token
+func (r *DeviceRepository) Create(device *models.Device, tokenHash string) error {
+	now := time.Now()
+	if device.Status == "" {
+		device.Status = models.DevicePending
+	}
+	result, err := r.db.Exec(`INSERT INTO device (name, tokenHash, status, appVersion, lastIp, lastSeenAt, createdAt, updatedAt)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		device.Name, tokenHash, device.Status, device.AppVersion, device.LastIP, now, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	device.ID = int(id)
+	device.LastSeenAt = &now
+	device.CreatedAt = now
+	device.UpdatedAt = now
+	return nil
+}
+
+// UpdateStatus moves a device from one state to another. It fails when the
+// device is not in the expected state any more.
+func (r *DeviceRepository) UpdateStatus(id int, from, to string) error {
+	now := time.Now()
+	query := `UPDATE device SET status = ?, updatedAt = ?`
+	switch to {
+	case models.DeviceApproved:
+		query += `, approvedAt = ?`
+	case models.DeviceRevoked:
+		query += `, revokedAt = ?`
+	default:
+		return fmt.Errorf("invalid device status: %s", to)
+	}
+	query += ` WHERE id = ? AND status = ?`
+
+	result, err := r.db.Exec(query, to, now, now, id, from)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("device is not %s", from)
+	}
+	return nil
+}
+
+// Touch records when and from where a device was last seen, and the app
+// version it reported. An empty version keeps the last one.
+func (r *DeviceRepository) Touch(id int, ip, appVersion string, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE device SET lastSeenAt = ?, lastIp = ?,
+			  appVersion = CASE WHEN ? = '' THEN appVersion ELSE ? END
+			  WHERE id = ?`, now, ip, appVersion, appVersion, id)
+	return err
+}
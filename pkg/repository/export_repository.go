@@ -0,0 +1,683 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+)
+
+// ExportRepository reads the catalog as a portable document and loads such
+// documents back, matching records by their business keys
+type ExportRepository struct {
+	db *sql.DB
+}
+
+// Export reads the stores, items, staff and settings, and the sales if
+// includeSales is set. Everything is read in one transaction, so the
+// document is consistent while sales go on.
+func (r *ExportRepository) Export(includeSales bool) (*models.Export, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	doc := &models.Export{
+		Version:    models.ExportVersion,
+		ExportedAt: time.Now(),
+	}
+	if doc.Stores, err = exportStores(tx); err != nil {
+		return nil, err
+	}
+	if doc.Items, err = exportItems(tx); err != nil {
+		return nil, err
+	}
+	if doc.Staff, err = exportStaff(tx); err != nil {
+		return nil, err
+	}
+	if doc.Settings, err = exportSettings(tx); err != nil {
+		return nil, err
+	}
+	if includeSales {
+		if doc.Sales, err = exportSales(tx); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func exportStores(tx *sql.Tx) ([]models.ExportStore, error) {
+	rows, err := tx.Query(`SELECT storeId, name FROM store ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stores := []models.ExportStore{}
+	for rows.Next() {
+		var store models.ExportStore
+		if err := rows.Scan(&store.StoreID, &store.Name); err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+	return stores, rows.Err()
+}
+
+func exportItems(tx *sql.Tx) ([]models.ExportItem, error) {
+	aliases, err := aliasesByItem(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT i.id, i.itemId, i.name, i.price, i.stock, i.taxExcluded, COALESCE(s.storeId, '')
+			  FROM item i LEFT JOIN store s ON s.id = i.storeId
+			  WHERE i.isDeleted = 0 ORDER BY i.id`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ExportItem{}
+	for rows.Next() {
+		var id int
+		var item models.ExportItem
+		err := rows.Scan(&id, &item.ItemID, &item.Name, &item.Price, &item.Stock, &item.TaxExcluded, &item.StoreID)
+		if err != nil {
+			return nil, err
+		}
+		item.Aliases = aliases[id]
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func exportStaff(tx *sql.Tx) ([]models.ExportStaff, error) {
+	query := `SELECT st.staffId, st.name, st.role, COALESCE(s.storeId, '')
+			  FROM staff st LEFT JOIN store s ON s.id = st.storeId ORDER BY st.id`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	staffs := []models.ExportStaff{}
+	for rows.Next() {
+		var staff models.ExportStaff
+		if err := rows.Scan(&staff.StaffID, &staff.Name, &staff.Role, &staff.StoreID); err != nil {
+			return nil, err
+		}
+		staffs = append(staffs, staff)
+	}
+	return staffs, rows.Err()
+}
+
+func exportSettings(tx *sql.Tx) ([]models.ExportSetting, error) {
+	rows, err := tx.Query(`SELECT key, value, type, COALESCE(description, '') FROM setting ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := []models.ExportSetting{}
+	for rows.Next() {
+		var setting models.ExportSetting
+		if err := rows.Scan(&setting.Key, &setting.Value, &setting.Type, &setting.Description); err != nil {
+			return nil, err
+		}
+		settings = append(settings, setting)
+	}
+	return settings, rows.Err()
+}
+
+func exportSales(tx *sql.Tx) ([]models.ExportSale, error) {
+	query := `SELECT sa.id, sa.type, sa.originalSaleId, sa.reason, st.storeId, sf.staffId,
+			  sa.subtotal, sa.tax, sa.taxRate, sa.taxRounding, sa.totalPrice, sa.deposit, sa.change, sa.saleAt
+			  FROM sale sa
+			  JOIN store st ON st.id = sa.storeId
+			  JOIN staff sf ON sf.id = sa.staffId
+			  ORDER BY sa.id`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := []models.ExportSale{}
+	index := map[int]int{}
+	for rows.Next() {
+		sale := models.ExportSale{Details: []models.ExportSaleDetail{}}
+		err := rows.Scan(&sale.Number, &sale.Type, &sale.OriginalNumber, &sale.Reason, &sale.StoreID, &sale.StaffID,
+			&sale.Subtotal, &sale.Tax, &sale.TaxRate, &sale.TaxRounding, &sale.TotalPrice, &sale.Deposit, &sale.Change, &sale.SaleAt)
+		if err != nil {
+			return nil, err
+		}
+		sale.SaleAt = sale.SaleAt.Local()
+		index[sale.Number] = len(sales)
+		sales = append(sales, sale)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	detailQuery := `SELECT d.saleId, i.itemId, d.quantity, d.price, d.listPrice, d.priceOverridden, d.overrideReason, d.taxExcluded
+				   FROM sale_detail d JOIN item i ON i.id = d.itemId ORDER BY d.id`
+
+	rows, err = tx.Query(detailQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var saleID int
+		var detail models.ExportSaleDetail
+		err := rows.Scan(&saleID, &detail.ItemID, &detail.Quantity, &detail.Price, &detail.ListPrice,
+			&detail.PriceOverridden, &detail.OverrideReason, &detail.TaxExcluded)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[saleID]; ok {
+			sales[i].Details = append(sales[i].Details, detail)
+		}
+	}
+	return sales, rows.Err()
+}
+
+// aliasesByItem returns the alias codes of every item by its database ID
+func aliasesByItem(tx *sql.Tx) (map[int][]string, error) {
+	rows, err := tx.Query(`SELECT itemId, code FROM item_alias ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := map[int][]string{}
+	for rows.Next() {
+		var itemID int
+		var code string
+		if err := rows.Scan(&itemID, &code); err != nil {
+			return nil, err
+		}
+		aliases[itemID] = append(aliases[itemID], code)
+	}
+	return aliases, rows.Err()
+}
+
+// Import loads a document, which the caller has validated, in one
+// transaction. Records are matched by their business keys. In merge mode
+// records that differ from this server are kept as they are and reported
+// as conflicts; in replace mode the document wins, and items it does not
+// list are deleted. Stores and staff are never deleted, as sales refer to
+// them. Sales in the document are not imported, only counted. On a dry
+// run the transaction is rolled back, so the result only tells what would
+// happen.
+func (r *ExportRepository) Import(doc *models.Export, mode string, dryRun bool) (*models.ImportResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	im := &importer{
+		tx:      tx,
+		replace: mode == models.ImportReplace,
+		now:     time.Now(),
+		result: &models.ImportResult{
+			Mode:         mode,
+			DryRun:       dryRun,
+			SalesSkipped: len(doc.Sales),
+			Conflicts:    []models.ImportConflict{},
+		},
+	}
+	if err := im.importStores(doc.Stores); err != nil {
+		return nil, fmt.Errorf("failed to import stores: %w", err)
+	}
+	if err := im.importItems(doc.Items); err != nil {
+		return nil, fmt.Errorf("failed to import items: %w", err)
+	}
+	if err := im.importStaff(doc.Staff); err != nil {
+		return nil, fmt.Errorf("failed to import staff: %w", err)
+	}
+	if err := im.importSettings(doc.Settings); err != nil {
+		return nil, fmt.Errorf("failed to import settings: %w", err)
+	}
+
+	if dryRun {
+		return im.result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return im.result, nil
+}
+
+// importer loads one document within a transaction
+type importer struct {
+	tx      *sql.Tx
+	replace bool
+	now     time.Time
+	result  *models.ImportResult
+}
+
+func (im *importer) conflict(kind, key, reason string, fields ...string) {
+	im.result.Conflicts = append(im.result.Conflicts, models.ImportConflict{
+		Kind:   kind,
+		Key:    key,
+		Fields: fields,
+		Reason: reason,
+	})
+}
+
+// differs adds the conflict for a record that differs from this server,
+// and reports whether the record should be skipped
+func (im *importer) differs(kind, key string, fields []string, counts *models.ImportCounts) bool {
+	if len(fields) == 0 || im.replace {
+		return false
+	}
+	im.conflict(kind, key, "differs from this server; kept as it is", fields...)
+	counts.Skipped++
+	return true
+}
+
+// storeRef returns the database ID of the store with the business key
+// storeID, nil for no store, and false if there is no such store
+func (im *importer) storeRef(storeID string) (*int, bool, error) {
+	if storeID == "" {
+		return nil, true, nil
+	}
+	var id int
+	err := im.tx.QueryRow(`SELECT id FROM store WHERE storeId = ?`, storeID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &id, true, nil
+}
+
+func (im *importer) importStores(stores []models.ExportStore) error {
+	counts := &im.result.Stores
+	for _, store := range stores {
+		var id int
+		var name string
+		err := im.tx.QueryRow(`SELECT id, name FROM store WHERE storeId = ?`, store.StoreID).Scan(&id, &name)
+		if err == sql.ErrNoRows {
+			_, err = im.tx.Exec(`INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)`,
+				store.StoreID, store.Name, im.now, im.now)
+			if err != nil {
+				return err
+			}
+			counts.Created++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var fields []string
+		if name != store.Name {
+			fields = append(fields, "name")
+		}
+		if im.differs(models.AuditStore, store.StoreID, fields, counts) {
+			continue
+		}
+		if len(fields) == 0 {
+			counts.Unchanged++
+			continue
+		}
+		if _, err := im.tx.Exec(`UPDATE store SET name = ?, updatedAt = ? WHERE id = ?`, store.Name, im.now, id); err != nil {
+			return err
+		}
+		counts.Updated++
+	}
+	return nil
+}
+
+// importedItem is an item of this server as it was before the import
+type importedItem struct {
+	id          int
+	name        string
+	price       int
+	stock       int
+	taxExcluded bool
+	storeID     string
+	isDeleted   bool
+}
+
+func (im *importer) importItems(items []models.ExportItem) error {
+	counts := &im.result.Items
+
+	oldAliases, err := aliasesByItem(im.tx)
+	if err != nil {
+		return err
+	}
+	if im.replace {
+		listed := make(map[string]bool, len(items))
+		for _, item := range items {
+			listed[item.ItemID] = true
+		}
+		if counts.Deleted, err = im.deleteUnlistedItems(listed); err != nil {
+			return err
+		}
+		// The document decides the aliases; clearing them first lets an
+		// alias move from one item to another
+		if _, err := im.tx.Exec(`DELETE FROM item_alias`); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		storeID, ok, err := im.storeRef(item.StoreID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			im.conflict(models.AuditItem, item.ItemID, fmt.Sprintf("store %s does not exist", item.StoreID))
+			counts.Skipped++
+			continue
+		}
+
+		existing, err := im.findItem(item.ItemID)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			if inUse, err := im.codeInUse(item.ItemID); err != nil {
+				return err
+			} else if inUse {
+				im.conflict(models.AuditItem, item.ItemID, "the code is already an alias of another item")
+				counts.Skipped++
+				continue
+			}
+			query := `INSERT INTO item (itemId, name, price, stock, taxExcluded, storeId, createdAt, updatedAt)
+					  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+			result, err := im.tx.Exec(query, item.ItemID, item.Name, item.Price, item.Stock, item.TaxExcluded, storeID, im.now, im.now)
+			if err != nil {
+				return err
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			if _, err := im.addAliases(int(id), item, nil); err != nil {
+				return err
+			}
+			counts.Created++
+			continue
+		}
+
+		var fields []string
+		if !existing.isDeleted {
+			fields = existing.diff(item)
+			if im.differs(models.AuditItem, item.ItemID, fields, counts) {
+				continue
+			}
+		}
+
+		if existing.isDeleted || len(fields) > 0 {
+			// A deleted item with the same code comes back
+			query := `UPDATE item SET name = ?, price = ?, stock = ?, taxExcluded = ?, storeId = ?, isDeleted = 0, updatedAt = ?
+					  WHERE id = ?`
+			_, err := im.tx.Exec(query, item.Name, item.Price, item.Stock, item.TaxExcluded, storeID, im.now, existing.id)
+			if err != nil {
+				return err
+			}
+		}
+
+		var current []string
+		if !im.replace {
+			current = oldAliases[existing.id]
+		}
+		aliases, err := im.addAliases(existing.id, item, current)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case existing.isDeleted:
+			counts.Created++
+		case len(fields) > 0 || !sameCodes(oldAliases[existing.id], aliases):
+			counts.Updated++
+		default:
+			counts.Unchanged++
+		}
+	}
+	return nil
+}
+
+// findItem returns the item with the business key itemID, including
+// deleted ones, or nil if there is none
+func (im *importer) findItem(itemID string) (*importedItem, error) {
+	query := `SELECT i.id, i.name, i.price, i.stock, i.taxExcluded, COALESCE(s.storeId, ''), i.isDeleted
+			  FROM item i LEFT JOIN store s ON s.id = i.storeId WHERE i.itemId = ?`
+
+	item := &importedItem{}
+	err := im.tx.QueryRow(query, itemID).Scan(&item.id, &item.name, &item.price, &item.stock,
+		&item.taxExcluded, &item.storeID, &item.isDeleted)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// diff returns the fields in which the imported item differs
+func (e *importedItem) diff(item models.ExportItem) []string {
+	var fields []string
+	if e.name != item.Name {
+		fields = append(fields, "name")
+	}
+	if e.price != item.Price {
+		fields = append(fields, "price")
+	}
+	if e.stock != item.Stock {
+		fields = append(fields, "stock")
+	}
+	if e.taxExcluded != item.TaxExcluded {
+		fields = append(fields, "taxExcluded")
+	}
+	if e.storeID != item.StoreID {
+		fields = append(fields, "storeId")
+	}
+	return fields
+}
+
+// deleteUnlistedItems deletes the items whose code is not listed
+func (im *importer) deleteUnlistedItems(listed map[string]bool) (int, error) {
+	rows, err := im.tx.Query(`SELECT id, itemId FROM item WHERE isDeleted = 0`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		var itemID string
+		if err := rows.Scan(&id, &itemID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !listed[itemID] {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if _, err := im.tx.Exec(`UPDATE item SET isDeleted = 1, updatedAt = ? WHERE id = ?`, im.now, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// addAliases gives the item the aliases of the imported item it does not
+// have yet. Codes that another item already uses are reported as conflicts.
+// It returns the aliases the item ends up with.
+func (im *importer) addAliases(id int, item models.ExportItem, current []string) ([]string, error) {
+	aliases := append([]string{}, current...)
+	for _, code := range item.Aliases {
+		if containsCode(aliases, code) {
+			continue
+		}
+		if inUse, err := im.codeInUse(code); err != nil {
+			return nil, err
+		} else if inUse {
+			im.conflict(models.AuditItem, item.ItemID, fmt.Sprintf("alias %s is already in use", code), "aliases")
+			continue
+		}
+		if _, err := im.tx.Exec(`INSERT INTO item_alias (itemId, code, createdAt) VALUES (?, ?, ?)`, id, code, im.now); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, code)
+	}
+	return aliases, nil
+}
+
+// codeInUse reports whether code is the itemId or an alias of any item,
+// including deleted ones
+func (im *importer) codeInUse(code string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM item WHERE itemId = ?)
+			  OR EXISTS (SELECT 1 FROM item_alias WHERE code = ?)`
+
+	var inUse bool
+	err := im.tx.QueryRow(query, code, code).Scan(&inUse)
+	return inUse, err
+}
+
+func (im *importer) importStaff(staffs []models.ExportStaff) error {
+	counts := &im.result.Staff
+	for _, staff := range staffs {
+		storeID, ok, err := im.storeRef(staff.StoreID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			im.conflict(models.AuditStaff, staff.StaffID, fmt.Sprintf("store %s does not exist", staff.StoreID))
+			counts.Skipped++
+			continue
+		}
+
+		var id int
+		var name, role, storeKey string
+		query := `SELECT st.id, st.name, st.role, COALESCE(s.storeId, '')
+				  FROM staff st LEFT JOIN store s ON s.id = st.storeId WHERE st.staffId = ?`
+		err = im.tx.QueryRow(query, staff.StaffID).Scan(&id, &name, &role, &storeKey)
+		if err == sql.ErrNoRows {
+			// Imported staff have no PIN; an admin gives them one
+			_, err = im.tx.Exec(`INSERT INTO staff (staffId, name, role, storeId, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)`,
+				staff.StaffID, staff.Name, staff.Role, storeID, im.now, im.now)
+			if err != nil {
+				return err
+			}
+			counts.Created++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var fields []string
+		if name != staff.Name {
+			fields = append(fields, "name")
+		}
+		if role != staff.Role {
+			fields = append(fields, "role")
+		}
+		if storeKey != staff.StoreID {
+			fields = append(fields, "storeId")
+		}
+		if im.differs(models.AuditStaff, staff.StaffID, fields, counts) {
+			continue
+		}
+		if len(fields) == 0 {
+			counts.Unchanged++
+			continue
+		}
+		_, err = im.tx.Exec(`UPDATE staff SET name = ?, role = ?, storeId = ?, updatedAt = ? WHERE id = ?`,
+			staff.Name, staff.Role, storeID, im.now, id)
+		if err != nil {
+			return err
+		}
+		counts.Updated++
+	}
+	return nil
+}
+
+func (im *importer) importSettings(settings []models.ExportSetting) error {
+	counts := &im.result.Settings
+	for _, setting := range settings {
+		var value string
+		err := im.tx.QueryRow(`SELECT value FROM setting WHERE key = ?`, setting.Key).Scan(&value)
+		if err == sql.ErrNoRows {
+			_, err = im.tx.Exec(`INSERT INTO setting (key, value, type, description, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)`,
+				setting.Key, setting.Value, setting.Type, setting.Description, im.now, im.now)
+			if err != nil {
+				return err
+			}
+			counts.Created++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var fields []string
+		if value != setting.Value {
+			fields = append(fields, "value")
+		}
+		if im.differs(models.AuditSetting, setting.Key, fields, counts) {
+			continue
+		}
+		if len(fields) == 0 {
+			counts.Unchanged++
+			continue
+		}
+		if _, err := im.tx.Exec(`UPDATE setting SET value = ?, updatedAt = ? WHERE key = ?`, setting.Value, im.now, setting.Key); err != nil {
+			return err
+		}
+		counts.Updated++
+	}
+	return nil
+}
+
+func containsCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// sameCodes reports whether a and b hold the same codes in any order
+func sameCodes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles are the numbered up-migrations, named like
+// 0002_sale_reversals.sql. A migration must never be edited once it has
+// been released; changes go into a new one.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered step of the schema
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string
+}
+
+// addColumnPattern finds the columns a migration adds
+var addColumnPattern = regexp.MustCompile(`(?is)ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+(\w+)[^;]*;`)
+
+// Migrations returns the embedded migrations in order
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		number, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s is not numbered", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			SQL:      string(data),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s have the same number", migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+	return migrations, nil
+}
+
+// PendingMigrations returns the migrations that have not been applied to
+// the database yet. It fails if an applied migration has been changed, or
+// the database has one this build does not know. Nothing is written, so it
+// serves as a dry run.
+func PendingMigrations(db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := tableExists(db, "schema_migrations")
+	if err != nil || !exists {
+		return migrations, err
+	}
+
+	rows, err := db.Query(`SELECT version, name, checksum FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var name, checksum string
+		if err := rows.Scan(&version, &name, &checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = checksum
+		if !hasMigration(migrations, version) {
+			return nil, fmt.Errorf("database has migration %s, which this version does not know; use a newer server", name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		checksum, ok := applied[migration.Version]
+		if !ok {
+			pending = append(pending, migration)
+			continue
+		}
+		if checksum != migration.Checksum {
+			return nil, fmt.Errorf("migration %s has been changed since it was applied", migration.Name)
+		}
+	}
+	return pending, nil
+}
+
+// RunMigrations applies the pending migrations, each in a transaction of
+// its own together with its schema_migrations row
+func RunMigrations(db *sql.DB) error {
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		appliedAt DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	for _, migration := range pending {
+		if err := applyMigration(db, migration); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", migration.Name, err)
+		}
+		log.Printf("Applied migration %s", migration.Name)
+	}
+	return nil
+}
+
+// applyMigration runs one migration and records it. Databases from before
+// schema_migrations existed already have some of the columns that the
+// migrations add, and an upgrade of one may have stopped halfway, so
+// columns that are there already are never added again.
+func applyMigration(db *sql.DB, migration Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, err := skipExistingColumns(tx, migration.SQL)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, checksum, appliedAt) VALUES (?, ?, ?, ?)`,
+		migration.Version, migration.Name, migration.Checksum, time.Now())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// skipExistingColumns removes the ALTER TABLE ... ADD COLUMN statements for
+// columns the table already has
+func skipExistingColumns(tx *sql.Tx, script string) (string, error) {
+	var err error
+	script = addColumnPattern.ReplaceAllStringFunc(script, func(statement string) string {
+		match := addColumnPattern.FindStringSubmatch(statement)
+		exists, checkErr := columnExists(tx, match[1], match[2])
+		if checkErr != nil {
+			err = checkErr
+		}
+		if exists {
+			return ""
+		}
+		return statement
+	})
+	return script, err
+}
+
+func hasMigration(migrations []Migration, version int) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func tableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count)
+	return count > 0, err
+}
+
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	return count > 0, err
+}
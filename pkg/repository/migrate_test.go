@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMigrateTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func columnNames(t *testing.T, db *sql.DB, table string) []string {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?) ORDER BY name`, table)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestRunMigrations(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.Equal(t, 1, migrations[0].Version)
+
+	require.NoError(t, RunMigrations(db))
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count))
+	assert.Equal(t, len(migrations), count)
+	assert.Contains(t, columnNames(t, db, "sale"), "deviceId")
+	assert.Contains(t, columnNames(t, db, "staff"), "role")
+
+	var role string
+	require.NoError(t, db.QueryRow(`SELECT role FROM staff WHERE staffId = 'STAFF001'`).Scan(&role))
+	assert.Equal(t, "admin", role)
+
+	t.Run("nothing is pending afterwards", func(t *testing.T) {
+		pending, err := PendingMigrations(db)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		assert.NoError(t, RunMigrations(db))
+	})
+
+	t.Run("changed migration", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 2`)
+		require.NoError(t, err)
+		defer db.Exec(`UPDATE schema_migrations SET checksum = ? WHERE version = 2`, migrations[1].Checksum)
+
+		err = RunMigrations(db)
+		assert.ErrorContains(t, err, "has been changed since it was applied")
+	})
+
+	t.Run("migration from a newer version", func(t *testing.T) {
+		_, err := db.Exec(`INSERT INTO schema_migrations (version, name, checksum, appliedAt) VALUES (9999, '9999_future', '', ?)`, time.Now())
+		require.NoError(t, err)
+		defer db.Exec(`DELETE FROM schema_migrations WHERE version = 9999`)
+
+		_, err = PendingMigrations(db)
+		assert.ErrorContains(t, err, "9999_future")
+	})
+}
+
+func TestPendingMigrationsIsADryRun(t *testing.T) {
+	db := setupMigrateTestDB(t)
+
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	assert.Equal(t, migrations, pending)
+
+	exists, err := tableExists(db, "schema_migrations")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRunMigrationsUpgradesFirstRelease(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+
+	// A database as the first release left it
+	_, err = db.Exec(migrations[0].SQL)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO item (itemId, name, price) VALUES ('ITEM-001', 'Candy', 100)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO sale (storeId, staffId, totalPrice, deposit, saleAt) VALUES (1, 1, 200, 500, ?)`, time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (1, 1, 2, 100)`)
+	require.NoError(t, err)
+	jst := time.FixedZone("JST", 9*60*60)
+	_, err = db.Exec(`INSERT INTO sale (storeId, staffId, totalPrice, deposit, saleAt) VALUES (1, 1, 100, 100, ?)`,
+		time.Date(2026, 10, 16, 8, 0, 0, 500000000, jst))
+	require.NoError(t, err)
+
+	require.NoError(t, RunMigrations(db))
+
+	var saleAt string
+	require.NoError(t, db.QueryRow(`SELECT CAST(saleAt AS TEXT) FROM sale WHERE id = 2`).Scan(&saleAt))
+	assert.Equal(t, "2026-10-15 23:00:00.5 +0000 UTC", saleAt)
+
+	var change, subtotal, listPrice int
+	var saleType string
+	require.NoError(t, db.QueryRow(`SELECT change, subtotal, type FROM sale WHERE id = 1`).Scan(&change, &subtotal, &saleType))
+	assert.Equal(t, 300, change)
+	assert.Equal(t, 200, subtotal)
+	assert.Equal(t, "sale", saleType)
+	require.NoError(t, db.QueryRow(`SELECT listPrice FROM sale_detail WHERE id = 1`).Scan(&listPrice))
+	assert.Equal(t, 100, listPrice)
+
+	var role string
+	require.NoError(t, db.QueryRow(`SELECT role FROM staff WHERE staffId = 'STAFF001'`).Scan(&role))
+	assert.Equal(t, "admin", role)
+
+	fresh := setupMigrateTestDB(t)
+	require.NoError(t, RunMigrations(fresh))
+	for _, table := range []string{"item", "staff", "sale", "sale_detail", "device", "audit_log"} {
+		assert.Equal(t, columnNames(t, fresh, table), columnNames(t, db, table), table)
+	}
+}
+
+func TestRunMigrationsAdoptsExistingColumns(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+
+	// A database made before schema_migrations existed, which already has
+	// some of the later columns
+	for _, migration := range migrations[:4] {
+		_, err := db.Exec(migration.SQL)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, RunMigrations(db))
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRunMigrationsResumesLegacyUpgrade(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+
+	// A database from before schema_migrations existed, whose upgrade
+	// stopped after the first migration
+	for _, migration := range migrations[:4] {
+		_, err := db.Exec(migration.SQL)
+		require.NoError(t, err)
+	}
+	_, err = db.Exec(`CREATE TABLE schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		appliedAt DATETIME NOT NULL
+	)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, name, checksum, appliedAt) VALUES (?, ?, ?, ?)`,
+		migrations[0].Version, migrations[0].Name, migrations[0].Checksum, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, RunMigrations(db))
+	require.NoError(t, RunMigrations(db), "a restart finds nothing to do")
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestApplyMigrationRollsBack(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	require.NoError(t, RunMigrations(db))
+
+	err := applyMigration(db, Migration{
+		Version: 9999,
+		Name:    "9999_broken",
+		SQL:     "CREATE TABLE half_done (id INTEGER); SELECT nothing FROM missing;",
+	})
+	require.Error(t, err)
+
+	exists, err := tableExists(db, "half_done")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = 9999`).Scan(&count))
+	assert.Zero(t, count)
+}
@@ -0,0 +1,104 @@
+-- The schema of the first release. Databases created before migrations
+-- existed already have it, so everything here must be safe to run again.
+
+CREATE TABLE IF NOT EXISTS item (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	itemId TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	price INTEGER NOT NULL,
+	stock INTEGER NOT NULL DEFAULT 0,
+	isDeleted INTEGER NOT NULL DEFAULT 0,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS store (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	storeId TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS staff (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	staffId TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sale (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	storeId INTEGER NOT NULL,
+	staffId INTEGER NOT NULL,
+	totalPrice INTEGER NOT NULL,
+	deposit INTEGER NOT NULL,
+	saleAt DATETIME NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (storeId) REFERENCES store(id),
+	FOREIGN KEY (staffId) REFERENCES staff(id)
+);
+
+CREATE TABLE IF NOT EXISTS sale_detail (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	saleId INTEGER NOT NULL,
+	itemId INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	price INTEGER NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (saleId) REFERENCES sale(id) ON DELETE CASCADE,
+	FOREIGN KEY (itemId) REFERENCES item(id)
+);
+
+CREATE TABLE IF NOT EXISTS setting (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	key TEXT NOT NULL UNIQUE,
+	value TEXT NOT NULL,
+	type TEXT NOT NULL DEFAULT 'string',
+	description TEXT,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS apk_versions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	version TEXT NOT NULL UNIQUE,
+	versionCode INTEGER NOT NULL,
+	fileName TEXT NOT NULL,
+	fileSize INTEGER NOT NULL,
+	filePath TEXT NOT NULL,
+	releaseNotes TEXT,
+	isActive INTEGER NOT NULL DEFAULT 1,
+	uploadedAt DATETIME NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_item_itemId ON item(itemId);
+CREATE INDEX IF NOT EXISTS idx_item_isDeleted ON item(isDeleted);
+CREATE INDEX IF NOT EXISTS idx_sale_storeId ON sale(storeId);
+CREATE INDEX IF NOT EXISTS idx_sale_staffId ON sale(staffId);
+CREATE INDEX IF NOT EXISTS idx_sale_saleAt ON sale(saleAt);
+CREATE INDEX IF NOT EXISTS idx_sale_detail_saleId ON sale_detail(saleId);
+CREATE INDEX IF NOT EXISTS idx_sale_detail_itemId ON sale_detail(itemId);
+CREATE INDEX IF NOT EXISTS idx_apk_versions_versionCode ON apk_versions(versionCode);
+CREATE INDEX IF NOT EXISTS idx_apk_versions_isActive ON apk_versions(isActive);
+CREATE INDEX IF NOT EXISTS idx_apk_versions_uploadedAt ON apk_versions(uploadedAt);
+
+-- Insert default settings if not exists
+INSERT OR IGNORE INTO setting (key, value, type, description) VALUES
+	('shopName', 'KidsPOS Shop', 'string', 'Shop name'),
+	('receiptFooter', 'Thank you!', 'string', 'Receipt footer message'),
+	('taxRate', '10', 'number', 'Tax rate in percentage'),
+	('currency', 'JPY', 'string', 'Currency code');
+
+-- Insert sample data if tables are empty
+INSERT OR IGNORE INTO store (storeId, name) VALUES
+	('STORE001', 'Main Store');
+
+INSERT OR IGNORE INTO staff (staffId, name) VALUES
+	('STAFF001', 'Admin');
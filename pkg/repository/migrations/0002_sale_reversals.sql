@@ -0,0 +1,8 @@
+-- Voids and refunds are sales of their own that point at the original
+ALTER TABLE sale ADD COLUMN type TEXT NOT NULL DEFAULT 'sale';
+ALTER TABLE sale ADD COLUMN originalSaleId INTEGER REFERENCES sale(id);
+ALTER TABLE sale ADD COLUMN reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE sale_detail ADD COLUMN originalDetailId INTEGER REFERENCES sale_detail(id);
+
+CREATE INDEX IF NOT EXISTS idx_sale_originalSaleId ON sale(originalSaleId);
+CREATE INDEX IF NOT EXISTS idx_sale_detail_originalDetailId ON sale_detail(originalDetailId);
@@ -0,0 +1,7 @@
+-- Sale lines keep the catalog price next to the price charged
+ALTER TABLE sale_detail ADD COLUMN listPrice INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sale_detail ADD COLUMN priceOverridden INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sale_detail ADD COLUMN overrideReason TEXT NOT NULL DEFAULT '';
+
+-- Earlier lines were charged the catalog price
+UPDATE sale_detail SET listPrice = price WHERE listPrice = 0 AND priceOverridden = 0;
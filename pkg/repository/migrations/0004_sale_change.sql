@@ -0,0 +1,7 @@
+-- Sales record the change given back
+ALTER TABLE sale ADD COLUMN change INTEGER NOT NULL DEFAULT 0;
+
+UPDATE sale SET change = deposit - totalPrice WHERE change = 0 AND type = 'sale' AND deposit > totalPrice;
+
+INSERT OR IGNORE INTO setting (key, value, type, description) VALUES
+	('changeDenominations', 'auto', 'string', 'Coins and bills used for change, e.g. 1000:bill,500:coin,100:coin (auto = by currency)');
@@ -0,0 +1,10 @@
+-- Retried sale requests return the sale created the first time
+CREATE TABLE IF NOT EXISTS sale_idempotency_key (
+	key TEXT PRIMARY KEY,
+	saleId INTEGER NOT NULL,
+	expiresAt DATETIME NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (saleId) REFERENCES sale(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sale_idempotency_key_expiresAt ON sale_idempotency_key(expiresAt);
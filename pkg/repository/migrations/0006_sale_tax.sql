@@ -0,0 +1,13 @@
+-- Items are priced with or without tax, and sales record their tax
+ALTER TABLE item ADD COLUMN taxExcluded INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sale ADD COLUMN subtotal INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sale ADD COLUMN tax INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sale ADD COLUMN taxRate REAL NOT NULL DEFAULT 0;
+ALTER TABLE sale ADD COLUMN taxRounding TEXT NOT NULL DEFAULT 'floor';
+ALTER TABLE sale_detail ADD COLUMN taxExcluded INTEGER NOT NULL DEFAULT 0;
+
+-- Earlier sales recorded no tax
+UPDATE sale SET subtotal = totalPrice WHERE subtotal = 0 AND tax = 0;
+
+INSERT OR IGNORE INTO setting (key, value, type, description) VALUES
+	('taxRounding', 'floor', 'string', 'Tax rounding: floor, round or ceil');
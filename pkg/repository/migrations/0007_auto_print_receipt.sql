@@ -0,0 +1,3 @@
+-- Receipts can be printed after every sale without asking
+INSERT OR IGNORE INTO setting (key, value, type, description) VALUES
+	('autoPrintReceipt', 'false', 'boolean', 'Print a receipt after every sale');
@@ -0,0 +1,17 @@
+-- Receipts wait in a queue until the printer takes them
+CREATE TABLE IF NOT EXISTS print_job (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	saleId INTEGER,
+	printer TEXT NOT NULL,
+	data BLOB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	lastError TEXT NOT NULL DEFAULT '',
+	nextAttemptAt DATETIME NOT NULL,
+	printedAt DATETIME,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (saleId) REFERENCES sale(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_print_job_status ON print_job(status);
@@ -0,0 +1,10 @@
+-- Other codes, such as JAN barcodes, that find an item
+CREATE TABLE IF NOT EXISTS item_alias (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	itemId INTEGER NOT NULL,
+	code TEXT NOT NULL UNIQUE,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (itemId) REFERENCES item(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_alias_itemId ON item_alias(itemId);
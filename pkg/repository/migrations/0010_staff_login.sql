@@ -0,0 +1,16 @@
+-- Staff log in with a PIN
+ALTER TABLE staff ADD COLUMN pinHash TEXT NOT NULL DEFAULT '';
+ALTER TABLE staff ADD COLUMN failedLogins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE staff ADD COLUMN lockedUntil DATETIME;
+
+CREATE TABLE IF NOT EXISTS staff_session (
+	tokenHash TEXT PRIMARY KEY,
+	staffId INTEGER NOT NULL,
+	kind TEXT NOT NULL DEFAULT 'web',
+	expiresAt DATETIME NOT NULL,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (staffId) REFERENCES staff(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_staff_session_staffId ON staff_session(staffId);
+CREATE INDEX IF NOT EXISTS idx_staff_session_expiresAt ON staff_session(expiresAt);
@@ -0,0 +1,10 @@
+-- Staff roles, and the store a store manager and an item belong to
+ALTER TABLE staff ADD COLUMN role TEXT NOT NULL DEFAULT 'cashier';
+ALTER TABLE staff ADD COLUMN storeId INTEGER REFERENCES store(id);
+ALTER TABLE item ADD COLUMN storeId INTEGER REFERENCES store(id);
+
+CREATE INDEX IF NOT EXISTS idx_item_storeId ON item(storeId);
+
+-- The seeded staff member keeps managing everything
+UPDATE staff SET role = 'admin'
+	WHERE staffId = 'STAFF001' AND NOT EXISTS (SELECT 1 FROM staff WHERE role = 'admin');
@@ -0,0 +1,18 @@
+-- Register tablets and the sales they make
+CREATE TABLE IF NOT EXISTS device (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	tokenHash TEXT NOT NULL UNIQUE,
+	status TEXT NOT NULL DEFAULT 'pending',
+	appVersion TEXT NOT NULL DEFAULT '',
+	lastIp TEXT NOT NULL DEFAULT '',
+	lastSeenAt DATETIME,
+	approvedAt DATETIME,
+	revokedAt DATETIME,
+	createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE sale ADD COLUMN deviceId INTEGER REFERENCES device(id);
+
+CREATE INDEX IF NOT EXISTS idx_sale_deviceId ON sale(deviceId);
@@ -0,0 +1,18 @@
+-- Changes with who made them. Staff and devices are not foreign keys, so
+-- the log outlives them.
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entity TEXT NOT NULL,
+	entityId TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL,
+	staffId INTEGER,
+	staffName TEXT NOT NULL DEFAULT '',
+	deviceId INTEGER,
+	beforeJson TEXT,
+	afterJson TEXT,
+	createdAt DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_createdAt ON audit_log(createdAt);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity, entityId);
+CREATE INDEX IF NOT EXISTS idx_audit_log_staffId ON audit_log(staffId);
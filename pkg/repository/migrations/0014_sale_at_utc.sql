@@ -0,0 +1,21 @@
+-- Sale times are kept in UTC so that they sort by instant as text. Earlier
+-- ones were stored in the zone they were made in, formatted like
+-- "2026-10-16 08:00:00.123 +0900 JST m=+1.5"; they are moved to UTC with
+-- their fraction of a second kept.
+UPDATE sale SET saleAt = utc.saleAt
+FROM (
+	SELECT id,
+		datetime(substr(saleAt, 1, 19), printf('%+d minutes',
+			-(CASE substr(zoneOffset, 1, 1) WHEN '-' THEN -1 ELSE 1 END)
+			* (CAST(substr(zoneOffset, 2, 2) AS INTEGER) * 60 + CAST(substr(zoneOffset, 4, 2) AS INTEGER))))
+		|| fraction || ' +0000 UTC' AS saleAt
+	FROM (
+		SELECT id, saleAt,
+			substr(rest, 1, instr(rest, ' ') - 1) AS fraction,
+			substr(rest, instr(rest, ' ') + 1, 5) AS zoneOffset
+		FROM (SELECT id, saleAt, substr(saleAt, 20) AS rest FROM sale)
+		WHERE saleAt GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *'
+	)
+	WHERE zoneOffset != '+0000'
+) AS utc
+WHERE sale.id = utc.id;
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+)
+
+// PrintJobRepository handles print job data access
+type PrintJobRepository struct {
+	db *sql.DB
+}
+
+const printJobColumns = `id, saleId, printer, data, status, attempts, lastError, nextAttemptAt,
+			  printedAt, createdAt, updatedAt`
+
+func scanPrintJob(row interface{ Scan(...interface{}) error }) (*models.PrintJob, error) {
+	job := &models.PrintJob{}
+	err := row.Scan(&job.ID, &job.SaleID, &job.Printer, &job.Data, &job.Status, &job.Attempts,
+		&job.LastError, &job.NextAttemptAt, &job.PrintedAt, &job.CreatedAt, &job.UpdatedAt)
+	return job, err
+}
+
+func (r *PrintJobRepository) queryJobs(query string, args ...interface{}) ([]*models.PrintJob, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*models.PrintJob
+	for rows.Next() {
+		job, err := scanPrintJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// FindByID finds a print job by ID
+func (r *PrintJobRepository) FindByID(id int) (*models.PrintJob, error) {
+	job, err := scanPrintJob(r.db.QueryRow(`SELECT `+printJobColumns+` FROM print_job WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("print job not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// FindByStatus returns the most recent print jobs, newest first. An empty
+// status returns jobs of every status.
+func (r *PrintJobRepository) FindByStatus(status string, limit int) ([]*models.PrintJob, error) {
+	query := `SELECT ` + printJobColumns + ` FROM print_job`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	return r.queryJobs(query, args...)
+}
+
+// FindQueued returns all queued jobs in the order they were created
+func (r *PrintJobRepository) FindQueued() ([]*models.PrintJob, error) {
+	return r.queryJobs(`SELECT `+printJobColumns+` FROM print_job WHERE status = ? ORDER BY id`,
+		models.PrintJobQueued)
+}
+
+// Create stores a new print job
+func (r *PrintJobRepository) Create(job *models.PrintJob) error {
+	now := time.Now()
+	if job.Status == "" {
+		job.Status = models.PrintJobQueued
+	}
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = now
+	}
+
+	result, err := r.db.Exec(`INSERT INTO print_job (saleId, printer, data, status, attempts, lastError,
+			  nextAttemptAt, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.SaleID, job.Printer, job.Data, job.Status, job.Attempts, job.LastError, job.NextAttemptAt, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	job.ID = int(id)
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	return nil
+}
+
+// UpdateState records the outcome of a print attempt
+func (r *PrintJobRepository) UpdateState(job *models.PrintJob) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE print_job SET status = ?, attempts = ?, lastError = ?, nextAttemptAt = ?,
+			  printedAt = ?, updatedAt = ? WHERE id = ?`,
+		job.Status, job.Attempts, job.LastError, job.NextAttemptAt, job.PrintedAt, now, job.ID)
+	if err != nil {
+		return err
+	}
+	job.UpdatedAt = now
+	return nil
+}
+
+// PrinterStatuses summarizes the queue of every printer that has jobs
+func (r *PrintJobRepository) PrinterStatuses() ([]models.PrinterStatus, error) {
+	rows, err := r.db.Query(`SELECT printer, status, COUNT(*) FROM print_job GROUP BY printer, status ORDER BY printer`)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []models.PrinterStatus
+	for rows.Next() {
+		var printer, status string
+		var count int
+		if err := rows.Scan(&printer, &status, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(statuses) == 0 || statuses[len(statuses)-1].Printer != printer {
+			statuses = append(statuses, models.PrinterStatus{Printer: printer})
+		}
+		switch status {
+		case models.PrintJobQueued:
+			statuses[len(statuses)-1].Queued = count
+		case models.PrintJobFailed:
+			statuses[len(statuses)-1].Failed = count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range statuses {
+		status := &statuses[i]
+
+		// The job at the head of the queue shows why the printer is stuck
+		err := r.db.QueryRow(`SELECT lastError FROM print_job WHERE printer = ? AND status = ?
+				  ORDER BY id LIMIT 1`, status.Printer, models.PrintJobQueued).Scan(&status.LastError)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		var printedAt time.Time
+		err = r.db.QueryRow(`SELECT printedAt FROM print_job WHERE printer = ? AND status = ?
+				  ORDER BY id DESC LIMIT 1`, status.Printer, models.PrintJobDone).Scan(&printedAt)
+		if err == nil {
+			status.LastPrintedAt = &printedAt
+		} else if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
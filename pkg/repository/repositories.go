@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
@@ -13,9 +14,15 @@ type Repositories struct {
 	Item       *ItemRepository
 	Store      *StoreRepository
 	Staff      *StaffRepository
+	Session    *SessionRepository
+	Device     *DeviceRepository
+	Audit      *AuditRepository
 	Sale       *SaleRepository
 	Setting    *SettingRepository
+	PrintJob   *PrintJobRepository
 	ApkVersion *ApkVersionRepository
+	Backup     *BackupRepository
+	Export     *ExportRepository
 }
 
 // NewRepositories creates all repository instances
@@ -24,9 +31,15 @@ func NewRepositories(db *sql.DB) *Repositories {
 		Item:       &ItemRepository{db: db},
 		Store:      &StoreRepository{db: db},
 		Staff:      &StaffRepository{db: db},
+		Session:    &SessionRepository{db: db},
+		Device:     &DeviceRepository{db: db},
+		Audit:      &AuditRepository{db: db},
 		Sale:       &SaleRepository{db: db},
 		Setting:    &SettingRepository{db: db},
+		PrintJob:   &PrintJobRepository{db: db},
 		ApkVersion: &ApkVersionRepository{db: db},
+		Backup:     &BackupRepository{db: db},
+		Export:     &ExportRepository{db: db},
 	}
 }
 
@@ -36,7 +49,7 @@ type ItemRepository struct {
 }
 
 func (r *ItemRepository) FindAll() ([]*models.Item, error) {
-	query := `SELECT id, itemId, name, price, stock, isDeleted, createdAt, updatedAt
+	query := `SELECT id, itemId, name, price, stock, taxExcluded, storeId, isDeleted, createdAt, updatedAt
 			  FROM item WHERE isDeleted = 0 ORDER BY id DESC`
 
 	rows, err := r.db.Query(query)
@@ -49,22 +62,30 @@ func (r *ItemRepository) FindAll() ([]*models.Item, error) {
 	for rows.Next() {
 		item := &models.Item{}
 		err := rows.Scan(&item.ID, &item.ItemID, &item.Name, &item.Price,
-			&item.Stock, &item.IsDeleted, &item.CreatedAt, &item.UpdatedAt)
+			&item.Stock, &item.TaxExcluded, &item.StoreID, &item.IsDeleted, &item.CreatedAt, &item.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := r.loadAliases(items...); err != nil {
+		return nil, err
+	}
 	return items, nil
 }
 
 func (r *ItemRepository) FindByID(id int) (*models.Item, error) {
-	query := `SELECT id, itemId, name, price, stock, isDeleted, createdAt, updatedAt
+	query := `SELECT id, itemId, name, price, stock, taxExcluded, storeId, isDeleted, createdAt, updatedAt
 			  FROM item WHERE id = ? AND isDeleted = 0`
 
 	item := &models.Item{}
 	err := r.db.QueryRow(query, id).Scan(&item.ID, &item.ItemID, &item.Name,
-		&item.Price, &item.Stock, &item.IsDeleted, &item.CreatedAt, &item.UpdatedAt)
+		&item.Price, &item.Stock, &item.TaxExcluded, &item.StoreID, &item.IsDeleted, &item.CreatedAt, &item.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("item not found")
@@ -72,16 +93,122 @@ func (r *ItemRepository) FindByID(id int) (*models.Item, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := r.loadAliases(item); err != nil {
+		return nil, err
+	}
 	return item, nil
 }
 
+// FindByCode finds an item by its itemId or one of its alias codes
+func (r *ItemRepository) FindByCode(code string) (*models.Item, error) {
+	query := `SELECT id, itemId, name, price, stock, taxExcluded, storeId, isDeleted, createdAt, updatedAt
+			  FROM item WHERE itemId = ? AND isDeleted = 0`
+
+	item := &models.Item{}
+	err := r.db.QueryRow(query, code).Scan(&item.ID, &item.ItemID, &item.Name,
+		&item.Price, &item.Stock, &item.TaxExcluded, &item.StoreID, &item.IsDeleted, &item.CreatedAt, &item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		aliasQuery := `SELECT i.id, i.itemId, i.name, i.price, i.stock, i.taxExcluded, i.storeId, i.isDeleted, i.createdAt, i.updatedAt
+				  FROM item_alias a JOIN item i ON i.id = a.itemId
+				  WHERE a.code = ? AND i.isDeleted = 0`
+		err = r.db.QueryRow(aliasQuery, code).Scan(&item.ID, &item.ItemID, &item.Name,
+			&item.Price, &item.Stock, &item.TaxExcluded, &item.StoreID, &item.IsDeleted, &item.CreatedAt, &item.UpdatedAt)
+	}
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("item not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadAliases(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// CodeInUse reports whether code is the itemId or an alias of any item,
+// including deleted ones
+func (r *ItemRepository) CodeInUse(code string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM item WHERE itemId = ?)
+			  OR EXISTS (SELECT 1 FROM item_alias WHERE code = ?)`
+
+	var inUse bool
+	err := r.db.QueryRow(query, code, code).Scan(&inUse)
+	return inUse, err
+}
+
+// AddAlias adds an alias code to an item
+func (r *ItemRepository) AddAlias(itemID int, code string) error {
+	query := `INSERT INTO item_alias (itemId, code, createdAt) VALUES (?, ?, ?)`
+
+	_, err := r.db.Exec(query, itemID, code, time.Now())
+	return err
+}
+
+// DeleteAlias removes an alias code from an item
+func (r *ItemRepository) DeleteAlias(itemID int, code string) error {
+	query := `DELETE FROM item_alias WHERE itemId = ? AND code = ?`
+
+	result, err := r.db.Exec(query, itemID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("alias not found")
+	}
+	return nil
+}
+
+// loadAliases fills in the alias codes of the given items
+func (r *ItemRepository) loadAliases(items ...*models.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	query := `SELECT itemId, code FROM item_alias ORDER BY id`
+	args := []interface{}{}
+	if len(items) == 1 {
+		query = `SELECT itemId, code FROM item_alias WHERE itemId = ? ORDER BY id`
+		args = append(args, items[0].ID)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID int
+		var code string
+		if err := rows.Scan(&itemID, &code); err != nil {
+			return err
+		}
+		if item, ok := byID[itemID]; ok {
+			item.Aliases = append(item.Aliases, code)
+		}
+	}
+	return rows.Err()
+}
+
 func (r *ItemRepository) Create(item *models.Item) error {
-	query := `INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt)
-			  VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO item (itemId, name, price, stock, taxExcluded, storeId, createdAt, updatedAt)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
 	now := time.Now()
 	result, err := r.db.Exec(query, item.ItemID, item.Name, item.Price,
-		item.Stock, now, now)
+		item.Stock, item.TaxExcluded, item.StoreID, now, now)
 	if err != nil {
 		return err
 	}
@@ -98,11 +225,11 @@ func (r *ItemRepository) Create(item *models.Item) error {
 }
 
 func (r *ItemRepository) Update(item *models.Item) error {
-	query := `UPDATE item SET name = ?, price = ?, stock = ?, updatedAt = ?
+	query := `UPDATE item SET name = ?, price = ?, stock = ?, taxExcluded = ?, updatedAt = ?
 			  WHERE id = ? AND isDeleted = 0`
 
 	now := time.Now()
-	_, err := r.db.Exec(query, item.Name, item.Price, item.Stock, now, item.ID)
+	_, err := r.db.Exec(query, item.Name, item.Price, item.Stock, item.TaxExcluded, now, item.ID)
 	if err != nil {
 		return err
 	}
@@ -111,10 +238,23 @@ func (r *ItemRepository) Update(item *models.Item) error {
 }
 
 func (r *ItemRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `UPDATE item SET isDeleted = 1, updatedAt = ? WHERE id = ?`
+	if _, err := tx.Exec(query, time.Now(), id); err != nil {
+		return err
+	}
 
-	_, err := r.db.Exec(query, time.Now(), id)
-	return err
+	// Free the alias codes so they can be given to another item
+	if _, err := tx.Exec(`DELETE FROM item_alias WHERE itemId = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // StoreRepository handles store data access
@@ -212,6 +352,20 @@ func (r *StoreRepository) Delete(id int) error {
 		return fmt.Errorf("cannot delete store: referenced by %d sale(s)", count)
 	}
 
+	// Check if store has managers or items of its own
+	checkQuery = `SELECT (SELECT COUNT(*) FROM staff WHERE storeId = ?),
+				  (SELECT COUNT(*) FROM item WHERE storeId = ? AND isDeleted = 0)`
+	var staffCount, itemCount int
+	if err := r.db.QueryRow(checkQuery, id, id).Scan(&staffCount, &itemCount); err != nil {
+		return err
+	}
+	if staffCount > 0 {
+		return fmt.Errorf("cannot delete store: referenced by %d staff", staffCount)
+	}
+	if itemCount > 0 {
+		return fmt.Errorf("cannot delete store: referenced by %d item(s)", itemCount)
+	}
+
 	// Delete store
 	query := `DELETE FROM store WHERE id = ?`
 	result, err := r.db.Exec(query, id)
@@ -236,7 +390,7 @@ type StaffRepository struct {
 }
 
 func (r *StaffRepository) FindAll() ([]*models.Staff, error) {
-	query := `SELECT id, staffId, name, createdAt, updatedAt FROM staff ORDER BY id DESC`
+	query := `SELECT id, staffId, name, role, storeId, pinHash != '', createdAt, updatedAt FROM staff ORDER BY id DESC`
 
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -247,7 +401,7 @@ func (r *StaffRepository) FindAll() ([]*models.Staff, error) {
 	var staffs []*models.Staff
 	for rows.Next() {
 		staff := &models.Staff{}
-		err := rows.Scan(&staff.ID, &staff.StaffID, &staff.Name,
+		err := rows.Scan(&staff.ID, &staff.StaffID, &staff.Name, &staff.Role, &staff.StoreID, &staff.HasPIN,
 			&staff.CreatedAt, &staff.UpdatedAt)
 		if err != nil {
 			return nil, err
@@ -258,11 +412,11 @@ func (r *StaffRepository) FindAll() ([]*models.Staff, error) {
 }
 
 func (r *StaffRepository) FindByID(id int) (*models.Staff, error) {
-	query := `SELECT id, staffId, name, createdAt, updatedAt FROM staff WHERE id = ?`
+	query := `SELECT id, staffId, name, role, storeId, pinHash != '', createdAt, updatedAt FROM staff WHERE id = ?`
 
 	staff := &models.Staff{}
 	err := r.db.QueryRow(query, id).Scan(&staff.ID, &staff.StaffID,
-		&staff.Name, &staff.CreatedAt, &staff.UpdatedAt)
+		&staff.Name, &staff.Role, &staff.StoreID, &staff.HasPIN, &staff.CreatedAt, &staff.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("staff not found")
@@ -274,10 +428,10 @@ func (r *StaffRepository) FindByID(id int) (*models.Staff, error) {
 }
 
 func (r *StaffRepository) Create(staff *models.Staff) error {
-	query := `INSERT INTO staff (staffId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)`
+	query := `INSERT INTO staff (staffId, name, role, storeId, pinHash, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
 
 	now := time.Now()
-	result, err := r.db.Exec(query, staff.StaffID, staff.Name, now, now)
+	result, err := r.db.Exec(query, staff.StaffID, staff.Name, staff.Role, staff.StoreID, staff.PINHash, now, now)
 	if err != nil {
 		return err
 	}
@@ -287,17 +441,80 @@ func (r *StaffRepository) Create(staff *models.Staff) error {
 		return err
 	}
 	staff.ID = int(id)
+	staff.HasPIN = staff.PINHash != ""
 	staff.CreatedAt = now
 	staff.UpdatedAt = now
 
 	return nil
 }
 
+// FindByStaffID finds a staff member by staff ID, including the PIN hash
+// and login state needed to check a login
+func (r *StaffRepository) FindByStaffID(staffID string) (*models.Staff, error) {
+	query := `SELECT id, staffId, name, role, storeId, pinHash, failedLogins, lockedUntil, createdAt, updatedAt
+			  FROM staff WHERE staffId = ?`
+
+	staff := &models.Staff{}
+	err := r.db.QueryRow(query, staffID).Scan(&staff.ID, &staff.StaffID, &staff.Name,
+		&staff.Role, &staff.StoreID, &staff.PINHash, &staff.FailedLogins, &staff.LockedUntil, &staff.CreatedAt, &staff.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("staff not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	staff.HasPIN = staff.PINHash != ""
+	return staff, nil
+}
+
+// CountWithPIN counts the staff members who can log in
+func (r *StaffRepository) CountWithPIN() (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM staff WHERE pinHash != ''`).Scan(&count)
+	return count, err
+}
+
+// SetPINHash replaces a staff member's PIN. It clears the login lockout and
+// ends the staff member's sessions, which were opened with the old PIN.
+func (r *StaffRepository) SetPINHash(id int, pinHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE staff SET pinHash = ?, failedLogins = 0, lockedUntil = NULL, updatedAt = ?
+			  WHERE id = ?`, pinHash, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("staff not found")
+	}
+
+	if _, err := tx.Exec(`DELETE FROM staff_session WHERE staffId = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateLoginState stores the count of failed logins since the last
+// successful one and until when logins are refused
+func (r *StaffRepository) UpdateLoginState(id, failedLogins int, lockedUntil *time.Time) error {
+	_, err := r.db.Exec(`UPDATE staff SET failedLogins = ?, lockedUntil = ? WHERE id = ?`,
+		failedLogins, lockedUntil, id)
+	return err
+}
+
 func (r *StaffRepository) Update(staff *models.Staff) error {
-	query := `UPDATE staff SET name = ?, updatedAt = ? WHERE id = ?`
+	query := `UPDATE staff SET name = ?, role = ?, storeId = ?, updatedAt = ? WHERE id = ?`
 
 	now := time.Now()
-	result, err := r.db.Exec(query, staff.Name, now, staff.ID)
+	result, err := r.db.Exec(query, staff.Name, staff.Role, staff.StoreID, now, staff.ID)
 	if err != nil {
 		return err
 	}
@@ -349,14 +566,23 @@ type SaleRepository struct {
 }
 
 func (r *SaleRepository) FindAll() ([]*models.Sale, error) {
-	query := `SELECT s.id, s.storeId, s.staffId, s.totalPrice, s.deposit, s.saleAt,
+	return r.FindByFilter(models.SaleFilter{})
+}
+
+// FindByFilter returns sales matching the given filter, newest first
+func (r *SaleRepository) FindByFilter(filter models.SaleFilter) ([]*models.Sale, error) {
+	query := `SELECT s.id, s.storeId, s.staffId, s.subtotal, s.tax, s.taxRate, s.taxRounding,
+			  s.totalPrice, s.deposit, s.change, s.saleAt,
+			  s.type, s.originalSaleId, s.reason, s.deviceId,
 			  s.createdAt, s.updatedAt, st.storeId, st.name, sf.staffId, sf.name
 			  FROM sale s
 			  JOIN store st ON s.storeId = st.id
-			  JOIN staff sf ON s.staffId = sf.id
-			  ORDER BY s.id DESC`
+			  JOIN staff sf ON s.staffId = sf.id`
 
-	rows, err := r.db.Query(query)
+	where, args := saleFilterClause(filter)
+	query += where + " ORDER BY s.id DESC"
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -368,19 +594,132 @@ func (r *SaleRepository) FindAll() ([]*models.Sale, error) {
 			Store: &models.Store{},
 			Staff: &models.Staff{},
 		}
-		err := rows.Scan(&sale.ID, &sale.StoreID, &sale.StaffID, &sale.TotalPrice,
-			&sale.Deposit, &sale.SaleAt, &sale.CreatedAt, &sale.UpdatedAt,
+		err := rows.Scan(&sale.ID, &sale.StoreID, &sale.StaffID, &sale.Subtotal, &sale.Tax,
+			&sale.TaxRate, &sale.TaxRounding, &sale.TotalPrice, &sale.Deposit, &sale.Change, &sale.SaleAt, &sale.Type, &sale.OriginalSaleID, &sale.Reason, &sale.DeviceID,
+			&sale.CreatedAt, &sale.UpdatedAt,
 			&sale.Store.StoreID, &sale.Store.Name,
 			&sale.Staff.StaffID, &sale.Staff.Name)
 		if err != nil {
 			return nil, err
 		}
+		sale.SaleAt = sale.SaleAt.Local()
 		sales = append(sales, sale)
 	}
 	return sales, nil
 }
 
+// FindDetailsByFilter returns the line items of all sales matching the filter,
+// ordered by sale and line
+func (r *SaleRepository) FindDetailsByFilter(filter models.SaleFilter) ([]models.SaleDetail, error) {
+	query := `SELECT d.id, d.saleId, d.itemId, d.quantity, d.price, d.listPrice, d.priceOverridden,
+			  d.overrideReason, d.taxExcluded, d.originalDetailId, d.createdAt, d.updatedAt,
+			  i.id, i.itemId, i.name, i.price, i.stock, i.taxExcluded, i.isDeleted, i.createdAt, i.updatedAt
+			  FROM sale_detail d
+			  JOIN sale s ON d.saleId = s.id
+			  JOIN item i ON d.itemId = i.id`
+
+	where, args := saleFilterClause(filter)
+	query += where + " ORDER BY d.saleId, d.id"
+
+	return r.queryDetails(query, args...)
+}
+
+func (r *SaleRepository) FindByID(id int) (*models.Sale, error) {
+	query := `SELECT s.id, s.storeId, s.staffId, s.subtotal, s.tax, s.taxRate, s.taxRounding,
+			  s.totalPrice, s.deposit, s.change, s.saleAt,
+			  s.type, s.originalSaleId, s.reason, s.deviceId,
+			  s.createdAt, s.updatedAt, st.id, st.storeId, st.name, sf.id, sf.staffId, sf.name
+			  FROM sale s
+			  JOIN store st ON s.storeId = st.id
+			  JOIN staff sf ON s.staffId = sf.id
+			  WHERE s.id = ?`
+
+	sale := &models.Sale{
+		Store: &models.Store{},
+		Staff: &models.Staff{},
+	}
+	err := r.db.QueryRow(query, id).Scan(&sale.ID, &sale.StoreID, &sale.StaffID,
+		&sale.Subtotal, &sale.Tax, &sale.TaxRate, &sale.TaxRounding, &sale.TotalPrice, &sale.Deposit, &sale.Change, &sale.SaleAt, &sale.Type, &sale.OriginalSaleID,
+		&sale.Reason, &sale.DeviceID, &sale.CreatedAt, &sale.UpdatedAt,
+		&sale.Store.ID, &sale.Store.StoreID, &sale.Store.Name,
+		&sale.Staff.ID, &sale.Staff.StaffID, &sale.Staff.Name)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("sale not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sale.SaleAt = sale.SaleAt.Local()
+
+	details, err := r.findDetails(sale.ID)
+	if err != nil {
+		return nil, err
+	}
+	sale.Details = details
+
+	return sale, nil
+}
+
+// findDetails loads the line items of a sale together with their items.
+// Deleted items are included so that past sales stay readable.
+func (r *SaleRepository) findDetails(saleID int) ([]models.SaleDetail, error) {
+	query := `SELECT d.id, d.saleId, d.itemId, d.quantity, d.price, d.listPrice, d.priceOverridden,
+			  d.overrideReason, d.taxExcluded, d.originalDetailId, d.createdAt, d.updatedAt,
+			  i.id, i.itemId, i.name, i.price, i.stock, i.taxExcluded, i.isDeleted, i.createdAt, i.updatedAt
+			  FROM sale_detail d
+			  JOIN item i ON d.itemId = i.id
+			  WHERE d.saleId = ?
+			  ORDER BY d.id`
+
+	return r.queryDetails(query, saleID)
+}
+
+func (r *SaleRepository) queryDetails(query string, args ...interface{}) ([]models.SaleDetail, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var details []models.SaleDetail
+	for rows.Next() {
+		detail := models.SaleDetail{Item: &models.Item{}}
+		err := rows.Scan(&detail.ID, &detail.SaleID, &detail.ItemID, &detail.Quantity,
+			&detail.Price, &detail.ListPrice, &detail.PriceOverridden, &detail.OverrideReason,
+			&detail.TaxExcluded, &detail.OriginalDetailID, &detail.CreatedAt, &detail.UpdatedAt,
+			&detail.Item.ID, &detail.Item.ItemID, &detail.Item.Name, &detail.Item.Price,
+			&detail.Item.Stock, &detail.Item.TaxExcluded, &detail.Item.IsDeleted, &detail.Item.CreatedAt, &detail.Item.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, detail)
+	}
+	return details, rows.Err()
+}
+
+// Create records a sale and takes its items out of stock. The client ID of
+// the sale is not checked; use CreateIdempotent for that.
 func (r *SaleRepository) Create(sale *models.Sale) error {
+	return r.CreateIdempotent(sale, time.Time{})
+}
+
+// FindIDByClientID returns the ID of the sale recorded under a client ID or
+// idempotency key that has not expired yet, or 0 if there is none
+func (r *SaleRepository) FindIDByClientID(clientID string) (int, error) {
+	var saleID int
+	err := r.db.QueryRow(`SELECT saleId FROM sale_idempotency_key WHERE key = ? AND expiresAt > ?`,
+		clientID, time.Now()).Scan(&saleID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return saleID, err
+}
+
+// CreateIdempotent records a sale like Create. When the sale carries a client
+// ID, the ID is kept until expiresAt and a sale submitted again with it fails
+// with a DuplicateSaleError instead of being recorded twice.
+func (r *SaleRepository) CreateIdempotent(sale *models.Sale, expiresAt time.Time) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
@@ -388,12 +727,20 @@ func (r *SaleRepository) Create(sale *models.Sale) error {
 	defer tx.Rollback()
 
 	// Insert sale
-	query := `INSERT INTO sale (storeId, staffId, totalPrice, deposit, saleAt, createdAt, updatedAt)
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO sale (storeId, staffId, subtotal, tax, taxRate, taxRounding, totalPrice,
+			  deposit, change, saleAt, type, deviceId, createdAt, updatedAt)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if sale.Type == "" {
+		sale.Type = models.SaleTypeSale
+	}
+	if sale.TaxRounding == "" {
+		sale.TaxRounding = models.TaxRoundingFloor
+	}
 
 	now := time.Now()
-	result, err := tx.Exec(query, sale.StoreID, sale.StaffID, sale.TotalPrice,
-		sale.Deposit, sale.SaleAt, now, now)
+	result, err := tx.Exec(query, sale.StoreID, sale.StaffID, sale.Subtotal, sale.Tax, sale.TaxRate,
+		sale.TaxRounding, sale.TotalPrice, sale.Deposit, sale.Change, saleTime(sale.SaleAt), sale.Type, sale.DeviceID, now, now)
 	if err != nil {
 		return err
 	}
@@ -404,18 +751,256 @@ func (r *SaleRepository) Create(sale *models.Sale) error {
 	}
 	sale.ID = int(saleID)
 
+	// The sale insert holds the write lock, so a concurrent retry with the
+	// same key cannot slip in between this check and the key insert
+	if sale.ClientID != "" && !expiresAt.IsZero() {
+		if err := claimClientID(tx, sale, expiresAt, now); err != nil {
+			return err
+		}
+	}
+
 	// Insert sale details
-	for _, detail := range sale.Details {
-		query := `INSERT INTO sale_detail (saleId, itemId, quantity, price, createdAt, updatedAt)
-				  VALUES (?, ?, ?, ?, ?, ?)`
-		_, err := tx.Exec(query, saleID, detail.ItemID, detail.Quantity, detail.Price, now, now)
+	for i := range sale.Details {
+		detail := &sale.Details[i]
+		query := `INSERT INTO sale_detail (saleId, itemId, quantity, price, listPrice, priceOverridden,
+				  overrideReason, taxExcluded, createdAt, updatedAt)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		result, err := tx.Exec(query, saleID, detail.ItemID, detail.Quantity, detail.Price,
+			detail.ListPrice, detail.PriceOverridden, detail.OverrideReason, detail.TaxExcluded, now, now)
+		if err != nil {
+			return err
+		}
+		detailID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		detail.ID = int(detailID)
+		detail.SaleID = sale.ID
+
+		// Check and decrement stock in one statement so that concurrent
+		// sales of the last item cannot both succeed
+		updateQuery := `UPDATE item SET stock = stock - ?, updatedAt = ?
+				  WHERE id = ? AND isDeleted = 0 AND stock >= ?`
+		result, err = tx.Exec(updateQuery, detail.Quantity, now, detail.ItemID, detail.Quantity)
 		if err != nil {
 			return err
 		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return insufficientStock(tx, detail.ItemID, detail.Quantity)
+		}
+	}
 
-		// Update item stock
-		updateQuery := `UPDATE item SET stock = stock - ? WHERE id = ?`
-		_, err = tx.Exec(updateQuery, detail.Quantity, detail.ItemID)
+	return tx.Commit()
+}
+
+// claimClientID stores the client ID of a new sale, failing with a
+// DuplicateSaleError if an unexpired sale already holds it
+func claimClientID(tx *sql.Tx, sale *models.Sale, expiresAt, now time.Time) error {
+	// Expired keys no longer protect anything and may be reused
+	if _, err := tx.Exec(`DELETE FROM sale_idempotency_key WHERE expiresAt <= ?`, now); err != nil {
+		return err
+	}
+
+	var existingID int
+	err := tx.QueryRow(`SELECT saleId FROM sale_idempotency_key WHERE key = ?`, sale.ClientID).Scan(&existingID)
+	if err == nil {
+		return &models.DuplicateSaleError{ClientID: sale.ClientID, SaleID: existingID}
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO sale_idempotency_key (key, saleId, expiresAt, createdAt) VALUES (?, ?, ?, ?)`,
+		sale.ClientID, sale.ID, expiresAt, now)
+	return err
+}
+
+// insufficientStock explains why a stock decrement did not match any row
+func insufficientStock(tx *sql.Tx, itemID, requested int) error {
+	stockErr := &models.InsufficientStockError{ItemID: itemID, Requested: requested}
+	var isDeleted bool
+	err := tx.QueryRow(`SELECT itemId, name, stock, isDeleted FROM item WHERE id = ?`, itemID).
+		Scan(&stockErr.ItemCode, &stockErr.ItemName, &stockErr.Available, &isDeleted)
+	if err == sql.ErrNoRows || isDeleted {
+		return fmt.Errorf("item not found: %d", itemID)
+	}
+	if err != nil {
+		return err
+	}
+	return stockErr
+}
+
+// CreateReversal stores a void or refund of reversal.OriginalSaleID. The
+// reversal lines point at the original detail rows and carry negative
+// quantities, so reports can simply sum them up. Passing no lines reverses
+// everything that has not been refunded yet. Stock of the returned items is
+// restored in the same transaction.
+func (r *SaleRepository) CreateReversal(reversal *models.Sale, lines []models.RefundLine) error {
+	if reversal.OriginalSaleID == nil {
+		return fmt.Errorf("original sale is required")
+	}
+	originalID := *reversal.OriginalSaleID
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Load the original sale together with what earlier reversals took back
+	var originalType string
+	var originalTax, originalTotal int
+	var taxRate float64
+	var taxRounding string
+	err = tx.QueryRow(`SELECT s.type, s.storeId, s.staffId, s.taxRate, s.taxRounding,
+			  s.tax + COALESCE((SELECT SUM(r.tax) FROM sale r WHERE r.originalSaleId = s.id), 0),
+			  s.totalPrice + COALESCE((SELECT SUM(r.totalPrice) FROM sale r WHERE r.originalSaleId = s.id), 0)
+			  FROM sale s WHERE s.id = ?`, originalID).
+		Scan(&originalType, &reversal.StoreID, &reversal.StaffID, &taxRate, &taxRounding,
+			&originalTax, &originalTotal)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("sale not found")
+	}
+	if err != nil {
+		return err
+	}
+	if originalType != models.SaleTypeSale {
+		return fmt.Errorf("only regular sales can be voided or refunded")
+	}
+
+	// Work out how much of every line is still left to reverse
+	type remainingLine struct {
+		detailID    int
+		itemID      int
+		price       int
+		listPrice   int
+		taxExcluded bool
+		quantity    int
+	}
+	rows, err := tx.Query(`SELECT d.id, d.itemId, d.price, d.listPrice, d.taxExcluded,
+			  d.quantity + COALESCE((SELECT SUM(rd.quantity) FROM sale_detail rd WHERE rd.originalDetailId = d.id), 0)
+			  FROM sale_detail d WHERE d.saleId = ? ORDER BY d.id`, originalID)
+	if err != nil {
+		return err
+	}
+	var remaining []*remainingLine
+	remainingByID := map[int]*remainingLine{}
+	for rows.Next() {
+		line := &remainingLine{}
+		if err := rows.Scan(&line.detailID, &line.itemID, &line.price, &line.listPrice,
+			&line.taxExcluded, &line.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		remaining = append(remaining, line)
+		remainingByID[line.detailID] = line
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Without explicit lines everything left is reversed
+	if len(lines) == 0 {
+		for _, line := range remaining {
+			if line.quantity > 0 {
+				lines = append(lines, models.RefundLine{DetailID: line.detailID})
+			}
+		}
+		if len(lines) == 0 {
+			return fmt.Errorf("sale has already been voided or fully refunded")
+		}
+	}
+
+	reversal.Details = nil
+	for _, requested := range lines {
+		line, ok := remainingByID[requested.DetailID]
+		if !ok {
+			return fmt.Errorf("sale detail %d does not belong to sale %d", requested.DetailID, originalID)
+		}
+		quantity := requested.Quantity
+		if quantity == 0 {
+			quantity = line.quantity
+		}
+		if quantity < 0 {
+			return fmt.Errorf("refund quantity must be positive")
+		}
+		if quantity == 0 || quantity > line.quantity {
+			return fmt.Errorf("cannot refund %d of sale detail %d: %d left", quantity, requested.DetailID, line.quantity)
+		}
+		line.quantity -= quantity
+
+		detailID := line.detailID
+		reversal.Details = append(reversal.Details, models.SaleDetail{
+			ItemID:           line.itemID,
+			Quantity:         -quantity,
+			Price:            line.price,
+			ListPrice:        line.listPrice,
+			TaxExcluded:      line.taxExcluded,
+			OriginalDetailID: &detailID,
+		})
+	}
+
+	// Tax is worked out with the rate of the original sale. The reversal that
+	// takes back the last items takes back exactly what is left, so rounding
+	// differences between partial refunds do not add up.
+	reversal.ApplyTax(taxRate, taxRounding)
+	everythingReversed := true
+	for _, line := range remaining {
+		if line.quantity > 0 {
+			everythingReversed = false
+		}
+	}
+	if everythingReversed {
+		reversal.Tax = -originalTax
+		reversal.TotalPrice = -originalTotal
+		reversal.Subtotal = reversal.TotalPrice - reversal.Tax
+	}
+
+	// The money handed back is recorded as a negative deposit
+	reversal.Deposit = reversal.TotalPrice
+	if reversal.SaleAt.IsZero() {
+		reversal.SaleAt = time.Now()
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`INSERT INTO sale (storeId, staffId, subtotal, tax, taxRate, taxRounding,
+			  totalPrice, deposit, saleAt, type, originalSaleId, reason, deviceId, createdAt, updatedAt)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		reversal.StoreID, reversal.StaffID, reversal.Subtotal, reversal.Tax, reversal.TaxRate,
+		reversal.TaxRounding, reversal.TotalPrice, reversal.Deposit, saleTime(reversal.SaleAt),
+		reversal.Type, originalID, reversal.Reason, reversal.DeviceID, now, now)
+	if err != nil {
+		return err
+	}
+	reversalID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	reversal.ID = int(reversalID)
+
+	for i := range reversal.Details {
+		detail := &reversal.Details[i]
+		detail.SaleID = reversal.ID
+		result, err := tx.Exec(`INSERT INTO sale_detail (saleId, itemId, quantity, price, listPrice, taxExcluded,
+				  originalDetailId, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			reversal.ID, detail.ItemID, detail.Quantity, detail.Price, detail.ListPrice, detail.TaxExcluded,
+			detail.OriginalDetailID, now, now)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		detail.ID = int(id)
+
+		// Put the returned items back on the shelf
+		_, err = tx.Exec(`UPDATE item SET stock = stock - ? WHERE id = ?`, detail.Quantity, detail.ItemID)
 		if err != nil {
 			return err
 		}
@@ -424,6 +1009,42 @@ func (r *SaleRepository) Create(sale *models.Sale) error {
 	return tx.Commit()
 }
 
+// saleTime converts a sale time to the way it is stored. The driver stores
+// times as text, which SQLite compares character by character, so all of
+// them are kept in UTC; with mixed zones the text would not sort by instant.
+// Sales are read back in local time.
+func saleTime(t time.Time) time.Time {
+	return t.UTC()
+}
+
+// saleFilterClause builds the WHERE clause for a sale filter.
+// The sale table must be aliased as "s".
+func saleFilterClause(filter models.SaleFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "s.saleAt >= ?")
+		args = append(args, saleTime(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "s.saleAt < ?")
+		args = append(args, saleTime(filter.To))
+	}
+	if filter.StoreID > 0 {
+		conditions = append(conditions, "s.storeId = ?")
+		args = append(args, filter.StoreID)
+	}
+	if filter.StaffID > 0 {
+		conditions = append(conditions, "s.staffId = ?")
+		args = append(args, filter.StaffID)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
 // SettingRepository handles setting data access
 type SettingRepository struct {
 	db *sql.DB
@@ -452,6 +1073,24 @@ func (r *SettingRepository) FindAll() ([]*models.Setting, error) {
 	return settings, nil
 }
 
+// FindByKey returns the setting with the given key, or nil if it does not exist
+func (r *SettingRepository) FindByKey(key string) (*models.Setting, error) {
+	query := `SELECT id, key, value, type, COALESCE(description, ''), createdAt, updatedAt
+			  FROM setting WHERE key = ?`
+
+	setting := &models.Setting{}
+	err := r.db.QueryRow(query, key).Scan(&setting.ID, &setting.Key, &setting.Value, &setting.Type,
+		&setting.Description, &setting.CreatedAt, &setting.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
 func (r *SettingRepository) Update(key, value string) error {
 	query := `UPDATE setting SET value = ?, updatedAt = ? WHERE key = ?`
 
@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func setupSaleTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+
+	// Every connection to :memory: opens a fresh database, so keep a single one
+	db.SetMaxOpenConns(1)
+
+	// Use the real schema; it seeds STORE001 and STAFF001 with id 1
+	require.NoError(t, RunMigrations(db))
+
+	return db
+}
+
+func createTestSale(t *testing.T, db *sql.DB, storeID, staffID, totalPrice int, saleAt time.Time) int {
+	result, err := db.Exec(`INSERT INTO sale (storeId, staffId, totalPrice, deposit, saleAt) VALUES (?, ?, ?, ?, ?)`,
+		storeID, staffID, totalPrice, totalPrice, saleTime(saleAt))
+	require.NoError(t, err)
+
+	id, err := result.LastInsertId()
+	require.NoError(t, err)
+
+	return int(id)
+}
+
+func TestSaleRepository_FindByID(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+
+	result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock) VALUES (?, ?, ?, ?)`,
+		"ITEM-001", "Candy", 100, 10)
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+
+	t.Run("find sale with details", func(t *testing.T) {
+		saleID := createTestSale(t, db, 1, 1, 200, time.Now())
+		_, err := db.Exec(`INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (?, ?, ?, ?)`,
+			saleID, itemID, 2, 100)
+		require.NoError(t, err)
+
+		sale, err := repo.FindByID(saleID)
+		require.NoError(t, err)
+		assert.Equal(t, 200, sale.TotalPrice)
+		assert.Equal(t, "STORE001", sale.Store.StoreID)
+		assert.Equal(t, "STAFF001", sale.Staff.StaffID)
+		require.Len(t, sale.Details, 1)
+		assert.Equal(t, 2, sale.Details[0].Quantity)
+		assert.Equal(t, "Candy", sale.Details[0].Item.Name)
+	})
+
+	t.Run("details of deleted items are still returned", func(t *testing.T) {
+		saleID := createTestSale(t, db, 1, 1, 100, time.Now())
+		_, err := db.Exec(`INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (?, ?, ?, ?)`,
+			saleID, itemID, 1, 100)
+		require.NoError(t, err)
+		_, err = db.Exec(`UPDATE item SET isDeleted = 1 WHERE id = ?`, itemID)
+		require.NoError(t, err)
+
+		sale, err := repo.FindByID(saleID)
+		require.NoError(t, err)
+		require.Len(t, sale.Details, 1)
+		assert.True(t, sale.Details[0].Item.IsDeleted)
+	})
+
+	t.Run("find non-existent sale", func(t *testing.T) {
+		sale, err := repo.FindByID(999)
+		assert.Error(t, err)
+		assert.Nil(t, sale)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestSaleRepository_FindByFilter(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+
+	_, err := db.Exec(`INSERT INTO store (storeId, name) VALUES ('STORE002', 'Second Store')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO staff (staffId, name) VALUES ('STAFF002', 'Second Staff')`)
+	require.NoError(t, err)
+
+	day := time.Date(2025, 10, 1, 0, 0, 0, 0, time.Local)
+	createTestSale(t, db, 1, 1, 100, day.Add(9*time.Hour))
+	createTestSale(t, db, 1, 2, 200, day.Add(13*time.Hour))
+	createTestSale(t, db, 2, 1, 300, day.AddDate(0, 0, 1).Add(10*time.Hour))
+
+	t.Run("no filter returns all sales", func(t *testing.T) {
+		sales, err := repo.FindByFilter(models.SaleFilter{})
+		require.NoError(t, err)
+		assert.Len(t, sales, 3)
+	})
+
+	t.Run("filter by date range", func(t *testing.T) {
+		sales, err := repo.FindByFilter(models.SaleFilter{From: day, To: day.AddDate(0, 0, 1)})
+		require.NoError(t, err)
+		require.Len(t, sales, 2)
+		assert.Equal(t, 200, sales[0].TotalPrice)
+		assert.Equal(t, 100, sales[1].TotalPrice)
+	})
+
+	t.Run("filter by store", func(t *testing.T) {
+		sales, err := repo.FindByFilter(models.SaleFilter{StoreID: 2})
+		require.NoError(t, err)
+		require.Len(t, sales, 1)
+		assert.Equal(t, 300, sales[0].TotalPrice)
+	})
+
+	t.Run("filter by staff and date", func(t *testing.T) {
+		sales, err := repo.FindByFilter(models.SaleFilter{From: day, StaffID: 1})
+		require.NoError(t, err)
+		assert.Len(t, sales, 2)
+	})
+}
+
+func TestSaleRepository_FindByFilterMixedZones(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+	jst := time.FixedZone("JST", 9*60*60)
+
+	// Registers may send their times in any zone
+	for i, saleAt := range []time.Time{
+		time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC), // 08:00 on the 16th in JST
+		time.Date(2026, 10, 16, 7, 30, 0, 0, jst),
+		time.Date(2026, 10, 16, 23, 30, 0, 0, jst),
+		time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC), // 00:30 on the 17th in JST
+	} {
+		sale := &models.Sale{StoreID: 1, StaffID: 1, TotalPrice: (i + 1) * 100, SaleAt: saleAt}
+		require.NoError(t, repo.Create(sale))
+	}
+
+	t.Run("a day in JST", func(t *testing.T) {
+		day := time.Date(2026, 10, 16, 0, 0, 0, 0, jst)
+		sales, err := repo.FindByFilter(models.SaleFilter{From: day, To: day.AddDate(0, 0, 1)})
+		require.NoError(t, err)
+		var totals []int
+		for _, sale := range sales {
+			totals = append(totals, sale.TotalPrice)
+		}
+		assert.Equal(t, []int{300, 200, 100}, totals)
+	})
+
+	t.Run("bounds in UTC", func(t *testing.T) {
+		sales, err := repo.FindByFilter(models.SaleFilter{
+			From: time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC),
+			To:   time.Date(2026, 10, 15, 23, 30, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+		require.Len(t, sales, 2)
+		assert.Equal(t, 200, sales[0].TotalPrice)
+		assert.Equal(t, 100, sales[1].TotalPrice)
+		assert.True(t, sales[1].SaleAt.Equal(time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestSaleRepository_CreateReversal(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+
+	result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock) VALUES (?, ?, ?, ?)`,
+		"ITEM-001", "Candy", 100, 5)
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+
+	saleID := createTestSale(t, db, 1, 1, 200, time.Now())
+	result, err = db.Exec(`INSERT INTO sale_detail (saleId, itemId, quantity, price) VALUES (?, ?, ?, ?)`,
+		saleID, itemID, 2, 100)
+	require.NoError(t, err)
+	detailID, _ := result.LastInsertId()
+
+	t.Run("refund a line restores stock", func(t *testing.T) {
+		result, err := db.Exec(`INSERT INTO device (name, tokenHash, status) VALUES (?, ?, ?)`,
+			"Register 1", "hash", models.DeviceApproved)
+		require.NoError(t, err)
+		deviceID, _ := result.LastInsertId()
+		device := int(deviceID)
+
+		refund := &models.Sale{Type: models.SaleTypeRefund, OriginalSaleID: &saleID, DeviceID: &device}
+		err = repo.CreateReversal(refund, []models.RefundLine{{DetailID: int(detailID), Quantity: 1}})
+		require.NoError(t, err)
+		assert.Equal(t, -100, refund.TotalPrice)
+		require.Len(t, refund.Details, 1)
+		assert.Equal(t, -1, refund.Details[0].Quantity)
+
+		var stock int
+		require.NoError(t, db.QueryRow(`SELECT stock FROM item WHERE id = ?`, itemID).Scan(&stock))
+		assert.Equal(t, 6, stock)
+
+		var storedDevice int
+		require.NoError(t, db.QueryRow(`SELECT deviceId FROM sale WHERE id = ?`, refund.ID).Scan(&storedDevice))
+		assert.Equal(t, device, storedDevice)
+	})
+
+	t.Run("detail of another sale - should fail", func(t *testing.T) {
+		refund := &models.Sale{Type: models.SaleTypeRefund, OriginalSaleID: &saleID}
+		err := repo.CreateReversal(refund, []models.RefundLine{{DetailID: 999, Quantity: 1}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "does not belong")
+	})
+
+	t.Run("reversing a reversal - should fail", func(t *testing.T) {
+		var refundID int
+		require.NoError(t, db.QueryRow(`SELECT id FROM sale WHERE originalSaleId = ?`, saleID).Scan(&refundID))
+
+		void := &models.Sale{Type: models.SaleTypeVoid, OriginalSaleID: &refundID}
+		err := repo.CreateReversal(void, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "only regular sales")
+	})
+
+	t.Run("void non-existent sale", func(t *testing.T) {
+		missing := 999
+		void := &models.Sale{Type: models.SaleTypeVoid, OriginalSaleID: &missing}
+		err := repo.CreateReversal(void, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestSaleRepository_CreateReversalTax(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+
+	result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock, taxExcluded) VALUES (?, ?, ?, ?, 1)`,
+		"ITEM-001", "Pencil", 15, 10)
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+
+	// Three pencils at 15 plus 10% tax, rounded up: 45 + 5 = 50
+	sale := &models.Sale{StoreID: 1, StaffID: 1, Deposit: 50, SaleAt: time.Now(),
+		Details: []models.SaleDetail{{ItemID: int(itemID), Quantity: 3, Price: 15, TaxExcluded: true}}}
+	sale.ApplyTax(10, models.TaxRoundingCeil)
+	require.NoError(t, repo.Create(sale))
+	require.Equal(t, 50, sale.TotalPrice)
+	detailID := sale.Details[0].ID
+
+	var refunded int
+	for i := 0; i < 3; i++ {
+		refund := &models.Sale{Type: models.SaleTypeRefund, OriginalSaleID: &sale.ID}
+		err := repo.CreateReversal(refund, []models.RefundLine{{DetailID: detailID, Quantity: 1}})
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, refund.TaxRate)
+		assert.Equal(t, refund.Subtotal+refund.Tax, refund.TotalPrice)
+		refunded += refund.TotalPrice
+	}
+
+	// Each pencil alone rounds up to 17, but the last refund only gives
+	// back what is left of the 50
+	assert.Equal(t, -50, refunded)
+
+	saved, err := repo.FindByID(sale.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 45, saved.Subtotal)
+	assert.Equal(t, 5, saved.Tax)
+	assert.Equal(t, models.TaxRoundingCeil, saved.TaxRounding)
+	assert.True(t, saved.Details[0].TaxExcluded)
+}
+
+func TestSaleRepository_Create(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+
+	result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock) VALUES (?, ?, ?, ?)`,
+		"ITEM-001", "Candy", 100, 2)
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+
+	t.Run("successful create decrements stock", func(t *testing.T) {
+		sale := &models.Sale{StoreID: 1, StaffID: 1, TotalPrice: 100, Deposit: 100, SaleAt: time.Now(),
+			Details: []models.SaleDetail{{ItemID: int(itemID), Quantity: 1, Price: 100}}}
+		require.NoError(t, repo.Create(sale))
+		assert.NotZero(t, sale.ID)
+
+		var stock int
+		require.NoError(t, db.QueryRow(`SELECT stock FROM item WHERE id = ?`, itemID).Scan(&stock))
+		assert.Equal(t, 1, stock)
+	})
+
+	t.Run("insufficient stock - nothing is written", func(t *testing.T) {
+		sale := &models.Sale{StoreID: 1, StaffID: 1, TotalPrice: 200, Deposit: 200, SaleAt: time.Now(),
+			Details: []models.SaleDetail{{ItemID: int(itemID), Quantity: 2, Price: 100}}}
+		err := repo.Create(sale)
+
+		var stockErr *models.InsufficientStockError
+		require.ErrorAs(t, err, &stockErr)
+		assert.Equal(t, "ITEM-001", stockErr.ItemCode)
+		assert.Equal(t, 2, stockErr.Requested)
+		assert.Equal(t, 1, stockErr.Available)
+
+		var count int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sale`).Scan(&count))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("same item on two lines counts together", func(t *testing.T) {
+		sale := &models.Sale{StoreID: 1, StaffID: 1, TotalPrice: 200, Deposit: 200, SaleAt: time.Now(),
+			Details: []models.SaleDetail{
+				{ItemID: int(itemID), Quantity: 1, Price: 100},
+				{ItemID: int(itemID), Quantity: 1, Price: 100},
+			}}
+		err := repo.Create(sale)
+
+		var stockErr *models.InsufficientStockError
+		assert.ErrorAs(t, err, &stockErr)
+	})
+
+	t.Run("deleted item", func(t *testing.T) {
+		result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock, isDeleted) VALUES (?, ?, ?, ?, 1)`,
+			"ITEM-DEL", "Gone", 100, 10)
+		require.NoError(t, err)
+		deletedID, _ := result.LastInsertId()
+
+		sale := &models.Sale{StoreID: 1, StaffID: 1, TotalPrice: 100, Deposit: 100, SaleAt: time.Now(),
+			Details: []models.SaleDetail{{ItemID: int(deletedID), Quantity: 1, Price: 100}}}
+		err = repo.Create(sale)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "item not found")
+	})
+}
+
+func TestSaleRepository_CreateConcurrent(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "kidspos.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, RunMigrations(db))
+
+	repo := &SaleRepository{db: db}
+
+	const stock = 3
+	result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock) VALUES (?, ?, ?, ?)`,
+		"ITEM-LAST", "Last One", 100, stock)
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+
+	// Ten registers try to sell one each at the same moment
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sale := &models.Sale{StoreID: 1, StaffID: 1, TotalPrice: 100, Deposit: 100, SaleAt: time.Now(),
+				Details: []models.SaleDetail{{ItemID: int(itemID), Quantity: 1, Price: 100}}}
+			errs <- repo.Create(sale)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		var stockErr *models.InsufficientStockError
+		assert.ErrorAs(t, err, &stockErr)
+	}
+	assert.Equal(t, stock, succeeded)
+
+	var remaining int
+	require.NoError(t, db.QueryRow(`SELECT stock FROM item WHERE id = ?`, itemID).Scan(&remaining))
+	assert.Equal(t, 0, remaining)
+}
+
+func TestSaleRepository_CreateIdempotent(t *testing.T) {
+	db := setupSaleTestDB(t)
+	defer db.Close()
+
+	repo := &SaleRepository{db: db}
+
+	result, err := db.Exec(`INSERT INTO item (itemId, name, price, stock) VALUES (?, ?, ?, ?)`,
+		"ITEM-001", "Candy", 100, 10)
+	require.NoError(t, err)
+	itemID, _ := result.LastInsertId()
+
+	newSale := func(clientID string) *models.Sale {
+		return &models.Sale{ClientID: clientID, StoreID: 1, StaffID: 1, TotalPrice: 100, Deposit: 100,
+			SaleAt: time.Now(), Details: []models.SaleDetail{{ItemID: int(itemID), Quantity: 1, Price: 100}}}
+	}
+
+	first := newSale("register-1-0001")
+	require.NoError(t, repo.CreateIdempotent(first, time.Now().Add(time.Hour)))
+
+	t.Run("retry is rejected without touching stock", func(t *testing.T) {
+		err := repo.CreateIdempotent(newSale("register-1-0001"), time.Now().Add(time.Hour))
+
+		var dupErr *models.DuplicateSaleError
+		require.ErrorAs(t, err, &dupErr)
+		assert.Equal(t, first.ID, dupErr.SaleID)
+
+		var stock, count int
+		require.NoError(t, db.QueryRow(`SELECT stock FROM item WHERE id = ?`, itemID).Scan(&stock))
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sale`).Scan(&count))
+		assert.Equal(t, 9, stock)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("find by client ID", func(t *testing.T) {
+		saleID, err := repo.FindIDByClientID("register-1-0001")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, saleID)
+
+		saleID, err = repo.FindIDByClientID("unknown")
+		require.NoError(t, err)
+		assert.Zero(t, saleID)
+	})
+
+	t.Run("expired key can be reused", func(t *testing.T) {
+		expiring := newSale("register-1-0002")
+		require.NoError(t, repo.CreateIdempotent(expiring, time.Now().Add(-time.Second)))
+
+		saleID, err := repo.FindIDByClientID("register-1-0002")
+		require.NoError(t, err)
+		assert.Zero(t, saleID)
+
+		again := newSale("register-1-0002")
+		require.NoError(t, repo.CreateIdempotent(again, time.Now().Add(time.Hour)))
+		assert.NotEqual(t, expiring.ID, again.ID)
+	})
+}
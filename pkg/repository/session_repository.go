@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+)
+
+// SessionRepository handles staff session data access. Sessions are stored
+// by a hash of their token, so a copy of the database does not log anyone in.
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// Create stores a new session under the hash of its token
+func (r *SessionRepository) Create(session *models.StaffSession, tokenHash string) error {
+	now := time.Now()
+	_, err := r.db.Exec(`INSERT INTO staff_session (tokenHash, staffId, kind, expiresAt, createdAt)
+			  VALUES (?, ?, ?, ?, ?)`, tokenHash, session.StaffID, session.Kind, session.ExpiresAt, now)
+	if err != nil {
+		return err
+	}
+	session.CreatedAt = now
+	return nil
+}
+
+// FindByTokenHash finds a session that has not expired yet, with its staff
+func (r *SessionRepository) FindByTokenHash(tokenHash string, now time.Time) (*models.StaffSession, error) {
+	query := `SELECT s.staffId, s.kind, s.expiresAt, s.createdAt,
+			  st.staffId, st.name, st.role, st.storeId, st.pinHash != '', st.createdAt, st.updatedAt
+			  FROM staff_session s
+			  JOIN staff st ON st.id = s.staffId
+			  WHERE s.tokenHash = ? AND s.expiresAt > ?`
+
+	session := &models.StaffSession{Staff: &models.Staff{}}
+	err := r.db.QueryRow(query, tokenHash, now).Scan(&session.StaffID, &session.Kind,
+		&session.ExpiresAt, &session.CreatedAt, &session.Staff.StaffID, &session.Staff.Name,
+		&session.Staff.Role, &session.Staff.StoreID, &session.Staff.HasPIN, &session.Staff.CreatedAt, &session.Staff.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.Staff.ID = session.StaffID
+	return session, nil
+}
+
+// Delete ends a session
+func (r *SessionRepository) Delete(tokenHash string) error {
+	_, err := r.db.Exec(`DELETE FROM staff_session WHERE tokenHash = ?`, tokenHash)
+	return err
+}
+
+// DeleteExpired removes the sessions that have expired
+func (r *SessionRepository) DeleteExpired(now time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM staff_session WHERE expiresAt <= ?`, now)
+	return err
+}
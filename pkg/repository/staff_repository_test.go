@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"database/sql"
 	"testing"
 	"time"
 
@@ -11,41 +10,8 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite", ":memory:")
-	require.NoError(t, err)
-
-	// Create tables
-	_, err = db.Exec(`
-		CREATE TABLE staff (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			staffId TEXT NOT NULL UNIQUE,
-			name TEXT NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	require.NoError(t, err)
-
-	_, err = db.Exec(`
-		CREATE TABLE sale (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			staffId INTEGER NOT NULL,
-			storeId INTEGER NOT NULL,
-			totalPrice INTEGER NOT NULL,
-			deposit INTEGER NOT NULL,
-			saleAt DATETIME NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (staffId) REFERENCES staff(id)
-		)
-	`)
-	require.NoError(t, err)
-
-	return db
-}
-
 func TestStaffRepository_Update(t *testing.T) {
-	db := setupTestDB(t)
+	db := setupSaleTestDB(t)
 	defer db.Close()
 
 	repo := &StaffRepository{db: db}
@@ -89,7 +55,7 @@ func TestStaffRepository_Update(t *testing.T) {
 }
 
 func TestStaffRepository_Delete(t *testing.T) {
-	db := setupTestDB(t)
+	db := setupSaleTestDB(t)
 	defer db.Close()
 
 	repo := &StaffRepository{db: db}
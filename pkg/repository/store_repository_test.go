@@ -14,31 +14,13 @@ import (
 func setupStoreTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite", ":memory:")
 	require.NoError(t, err)
+	// Every connection to :memory: would get a database of its own
+	db.SetMaxOpenConns(1)
 
-	// Create tables
-	_, err = db.Exec(`
-		CREATE TABLE store (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			storeId TEXT NOT NULL UNIQUE,
-			name TEXT NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	require.NoError(t, err)
+	require.NoError(t, RunMigrations(db))
 
-	_, err = db.Exec(`
-		CREATE TABLE sale (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			staffId INTEGER NOT NULL,
-			storeId INTEGER NOT NULL,
-			totalPrice INTEGER NOT NULL,
-			deposit INTEGER NOT NULL,
-			saleAt DATETIME NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (storeId) REFERENCES store(id)
-		)
-	`)
+	// Tests add the stores they need, so the sample data goes
+	_, err = db.Exec(`DELETE FROM staff; DELETE FROM store`)
 	require.NoError(t, err)
 
 	return db
@@ -130,6 +112,21 @@ func TestStoreRepository_Delete(t *testing.T) {
 		assert.Contains(t, err.Error(), "referenced by")
 	})
 
+	t.Run("delete store with a manager - should fail", func(t *testing.T) {
+		result, err := db.Exec("INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)",
+			"STORE-004", "Test Store 4", time.Now(), time.Now())
+		require.NoError(t, err)
+		storeID, _ := result.LastInsertId()
+
+		_, err = db.Exec("INSERT INTO staff (staffId, name, role, storeId) VALUES (?, ?, ?, ?)",
+			"STAFF-004", "Manager", models.RoleStoreManager, storeID)
+		require.NoError(t, err)
+
+		err = repo.Delete(int(storeID))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "referenced by 1 staff")
+	})
+
 	t.Run("delete non-existent store", func(t *testing.T) {
 		err := repo.Delete(999)
 		assert.Error(t, err)
@@ -17,12 +17,13 @@ import (
 // ApkVersionService handles APK version business logic
 type ApkVersionService struct {
 	repo        *repository.ApkVersionRepository
+	audit       *AuditService
 	uploadDir   string
 	maxFileSize int64
 }
 
 // NewApkVersionService creates a new APK version service
-func NewApkVersionService(repo *repository.ApkVersionRepository) *ApkVersionService {
+func NewApkVersionService(repo *repository.ApkVersionRepository, audit *AuditService) *ApkVersionService {
 	uploadDir := "./uploads/apk"
 	// Create upload directory if it doesn't exist
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
@@ -31,6 +32,7 @@ func NewApkVersionService(repo *repository.ApkVersionRepository) *ApkVersionServ
 
 	return &ApkVersionService{
 		repo:        repo,
+		audit:       audit,
 		uploadDir:   uploadDir,
 		maxFileSize: 100 * 1024 * 1024, // 100MB
 	}
@@ -57,7 +59,7 @@ func (s *ApkVersionService) GetVersion(id int) (*models.ApkVersion, error) {
 }
 
 // UploadApk uploads a new APK file
-func (s *ApkVersionService) UploadApk(file *multipart.FileHeader, version string, versionCode int, releaseNotes string) (*models.ApkVersion, error) {
+func (s *ApkVersionService) UploadApk(actor models.Actor, file *multipart.FileHeader, version string, versionCode int, releaseNotes string) (*models.ApkVersion, error) {
 	// Validate inputs
 	if version == "" {
 		return nil, fmt.Errorf("version is required")
@@ -107,6 +109,7 @@ func (s *ApkVersionService) UploadApk(file *multipart.FileHeader, version string
 		return nil, fmt.Errorf("failed to create APK version: %w", err)
 	}
 
+	s.audit.Record(actor, models.AuditApk, apk.ID, models.AuditCreate, nil, apk)
 	return apk, nil
 }
 
@@ -126,7 +129,7 @@ func (s *ApkVersionService) GetApkFilePath(id int) (string, error) {
 }
 
 // DeleteVersion deletes an APK version and its file
-func (s *ApkVersionService) DeleteVersion(id int) error {
+func (s *ApkVersionService) DeleteVersion(actor models.Actor, id int) error {
 	apk, err := s.repo.FindByID(id)
 	if err != nil {
 		return err
@@ -138,12 +141,25 @@ func (s *ApkVersionService) DeleteVersion(id int) error {
 	}
 
 	// Delete database record
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditApk, id, models.AuditDelete, apk, nil)
+	return nil
 }
 
 // DeactivateVersion deactivates an APK version
-func (s *ApkVersionService) DeactivateVersion(id int) (*models.ApkVersion, error) {
-	return s.repo.Deactivate(id)
+func (s *ApkVersionService) DeactivateVersion(actor models.Actor, id int) (*models.ApkVersion, error) {
+	before, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	apk, err := s.repo.Deactivate(id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, models.AuditApk, id, models.AuditDeactivate, before, apk)
+	return apk, nil
 }
 
 // saveFile saves an uploaded file to the specified path
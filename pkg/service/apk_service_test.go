@@ -11,6 +11,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,24 +21,10 @@ import (
 func setupAPKServiceTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite", ":memory:")
 	require.NoError(t, err)
+	// Every connection to :memory: would get a database of its own
+	db.SetMaxOpenConns(1)
 
-	// Create apk_versions table
-	_, err = db.Exec(`
-		CREATE TABLE apk_versions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			version TEXT NOT NULL UNIQUE,
-			versionCode INTEGER NOT NULL,
-			fileName TEXT NOT NULL,
-			fileSize INTEGER NOT NULL,
-			filePath TEXT NOT NULL,
-			releaseNotes TEXT,
-			isActive INTEGER NOT NULL DEFAULT 1,
-			uploadedAt DATETIME NOT NULL,
-			createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	require.NoError(t, err)
+	require.NoError(t, repository.RunMigrations(db))
 
 	return db
 }
@@ -76,7 +63,7 @@ func TestApkVersionService_UploadApk(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	repo := &repository.ApkVersionRepository{}
-	service := NewApkVersionService(repo)
+	service := NewApkVersionService(repo, nil)
 	service.uploadDir = tmpDir
 
 	// Set the repository's db
@@ -88,7 +75,7 @@ func TestApkVersionService_UploadApk(t *testing.T) {
 	t.Run("successful upload", func(t *testing.T) {
 		fileHeader := createTestFileHeader(t, "test.apk", []byte("test content"))
 
-		apk, err := service.UploadApk(fileHeader, "1.0.0", 1, "Test release")
+		apk, err := service.UploadApk(models.Actor{}, fileHeader, "1.0.0", 1, "Test release")
 		require.NoError(t, err)
 		require.NotNil(t, apk)
 
@@ -105,7 +92,7 @@ func TestApkVersionService_UploadApk(t *testing.T) {
 	t.Run("missing version", func(t *testing.T) {
 		fileHeader := createTestFileHeader(t, "test.apk", []byte("test content"))
 
-		apk, err := service.UploadApk(fileHeader, "", 1, "Test release")
+		apk, err := service.UploadApk(models.Actor{}, fileHeader, "", 1, "Test release")
 		assert.Error(t, err)
 		assert.Nil(t, apk)
 		assert.Contains(t, err.Error(), "version is required")
@@ -114,7 +101,7 @@ func TestApkVersionService_UploadApk(t *testing.T) {
 	t.Run("invalid version code", func(t *testing.T) {
 		fileHeader := createTestFileHeader(t, "test.apk", []byte("test content"))
 
-		apk, err := service.UploadApk(fileHeader, "1.0.0", 0, "Test release")
+		apk, err := service.UploadApk(models.Actor{}, fileHeader, "1.0.0", 0, "Test release")
 		assert.Error(t, err)
 		assert.Nil(t, apk)
 		assert.Contains(t, err.Error(), "version code must be positive")
@@ -125,7 +112,7 @@ func TestApkVersionService_UploadApk(t *testing.T) {
 		largeContent := make([]byte, 101*1024*1024)
 		fileHeader := createTestFileHeader(t, "large.apk", largeContent)
 
-		apk, err := service.UploadApk(fileHeader, "1.0.0", 1, "Test release")
+		apk, err := service.UploadApk(models.Actor{}, fileHeader, "1.0.0", 1, "Test release")
 		assert.Error(t, err)
 		assert.Nil(t, apk)
 		assert.Contains(t, err.Error(), "file size exceeds")
@@ -134,7 +121,7 @@ func TestApkVersionService_UploadApk(t *testing.T) {
 	t.Run("non-apk file", func(t *testing.T) {
 		fileHeader := createTestFileHeader(t, "test.txt", []byte("test content"))
 
-		apk, err := service.UploadApk(fileHeader, "1.0.0", 1, "Test release")
+		apk, err := service.UploadApk(models.Actor{}, fileHeader, "1.0.0", 1, "Test release")
 		assert.Error(t, err)
 		assert.Nil(t, apk)
 		assert.Contains(t, err.Error(), "must be an APK file")
@@ -146,7 +133,7 @@ func TestApkVersionService_GetLatestVersion(t *testing.T) {
 	defer db.Close()
 
 	repo := &repository.ApkVersionRepository{}
-	service := NewApkVersionService(repo)
+	service := NewApkVersionService(repo, nil)
 
 	// Set the repository's db using reflection
 	repoStruct := (*struct {
@@ -182,7 +169,7 @@ func TestApkVersionService_CheckForUpdate(t *testing.T) {
 	defer db.Close()
 
 	repo := &repository.ApkVersionRepository{}
-	service := NewApkVersionService(repo)
+	service := NewApkVersionService(repo, nil)
 
 	// Set the repository's db
 	repoStruct := (*struct {
@@ -223,7 +210,7 @@ func TestApkVersionService_DeleteVersion(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	repo := &repository.ApkVersionRepository{}
-	service := NewApkVersionService(repo)
+	service := NewApkVersionService(repo, nil)
 	service.uploadDir = tmpDir
 
 	// Set the repository's db
@@ -246,7 +233,7 @@ func TestApkVersionService_DeleteVersion(t *testing.T) {
 		id, err := result.LastInsertId()
 		require.NoError(t, err)
 
-		err = service.DeleteVersion(int(id))
+		err = service.DeleteVersion(models.Actor{}, int(id))
 		assert.NoError(t, err)
 
 		// Verify file was deleted
@@ -263,7 +250,7 @@ func TestApkVersionService_DeleteVersion(t *testing.T) {
 		id, err := result.LastInsertId()
 		require.NoError(t, err)
 
-		err = service.DeleteVersion(int(id))
+		err = service.DeleteVersion(models.Actor{}, int(id))
 		assert.NoError(t, err) // Should succeed even if file doesn't exist
 	})
 }
@@ -278,7 +265,7 @@ func TestApkVersionService_GetApkFilePath(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	repo := &repository.ApkVersionRepository{}
-	service := NewApkVersionService(repo)
+	service := NewApkVersionService(repo, nil)
 
 	// Set the repository's db
 	repoStruct := (*struct {
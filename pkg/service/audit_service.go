@@ -0,0 +1,75 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+)
+
+// Audit log page sizes
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditService keeps the audit log of changes made through the services.
+// A nil AuditService records nothing, so services work without one.
+type AuditService struct {
+	repo *repository.AuditRepository
+}
+
+// Record adds an entry for a change that has been made. The change stands
+// even if it cannot be recorded, so a failure is only logged.
+func (s *AuditService) Record(actor models.Actor, entity string, entityID interface{}, action string, before, after interface{}) {
+	if s == nil {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Entity:    entity,
+		EntityID:  fmt.Sprint(entityID),
+		Action:    action,
+		StaffName: actor.StaffName,
+		DeviceID:  actor.DeviceID,
+	}
+	if actor.StaffID > 0 {
+		staffID := actor.StaffID
+		entry.StaffID = &staffID
+	}
+
+	var err error
+	if entry.Before, err = auditJSON(before); err == nil {
+		entry.After, err = auditJSON(after)
+	}
+	if err == nil {
+		err = s.repo.Create(entry)
+	}
+	if err != nil {
+		log.Printf("Failed to record %s %s %s in the audit log: %v", action, entity, entry.EntityID, err)
+	}
+}
+
+// GetEntries returns the newest entries matching the filter
+func (s *AuditService) GetEntries(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, fmt.Errorf("end date must not be before start date")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	return s.repo.FindByFilter(filter)
+}
+
+// auditJSON encodes the state of an entity; nil means there is none
+func auditJSON(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
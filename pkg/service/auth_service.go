@@ -0,0 +1,244 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login limits. A staff member is locked out after maxFailedLogins wrong
+// PINs in a row; a single address may try maxLoginAttempts times per
+// loginAttemptWindow, whichever staff IDs it guesses.
+const (
+	maxFailedLogins    = 5
+	loginLockout       = 15 * time.Minute
+	maxLoginAttempts   = 10
+	loginAttemptWindow = time.Minute
+)
+
+// PINs are 4 to 8 digits, short enough to type on a register
+const (
+	minPINLength = 4
+	maxPINLength = 8
+)
+
+// pinHashCost is the bcrypt cost of stored PINs
+var pinHashCost = bcrypt.DefaultCost
+
+// dummyPINHash is compared against when a staff ID does not exist, so a
+// wrong staff ID takes as long as a wrong PIN
+var dummyPINHash, _ = bcrypt.GenerateFromPassword([]byte("00000000"), bcrypt.DefaultCost)
+
+// AuthService logs staff members in with their PIN and keeps their sessions
+type AuthService struct {
+	staffRepo   *repository.StaffRepository
+	sessionRepo *repository.SessionRepository
+	sessionTTL  time.Duration
+
+	mu       sync.Mutex
+	attempts map[string][]time.Time // recent login attempts by address
+}
+
+// NewAuthService creates an auth service whose sessions last sessionTTL
+func NewAuthService(staffRepo *repository.StaffRepository, sessionRepo *repository.SessionRepository, sessionTTL time.Duration) *AuthService {
+	return &AuthService{
+		staffRepo:   staffRepo,
+		sessionRepo: sessionRepo,
+		sessionTTL:  sessionTTL,
+		attempts:    make(map[string][]time.Time),
+	}
+}
+
+// Login checks a staff member's PIN and opens a session of the given kind.
+// It fails with models.ErrInvalidLogin for a wrong staff ID or PIN and with
+// a LoginLockedError while the staff member or the address is locked out.
+func (s *AuthService) Login(staffID, pin, address, kind string) (*models.StaffSession, error) {
+	if kind != models.SessionWeb && kind != models.SessionAPI {
+		return nil, fmt.Errorf("invalid session kind: %s", kind)
+	}
+
+	now := time.Now()
+	if err := s.throttle(address, now); err != nil {
+		return nil, err
+	}
+
+	staff, err := s.staffRepo.FindByStaffID(strings.TrimSpace(staffID))
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyPINHash, []byte(pin))
+		return nil, models.ErrInvalidLogin
+	}
+
+	// A locked out staff member gets no answer about the PIN at all
+	if staff.LockedUntil != nil && now.Before(*staff.LockedUntil) {
+		return nil, &models.LoginLockedError{Until: *staff.LockedUntil}
+	}
+
+	if staff.PINHash == "" || bcrypt.CompareHashAndPassword([]byte(staff.PINHash), []byte(pin)) != nil {
+		failed := staff.FailedLogins + 1
+		var lockedUntil *time.Time
+		if failed >= maxFailedLogins {
+			until := now.Add(loginLockout)
+			lockedUntil, failed = &until, 0
+			log.Printf("Staff %s locked out until %s after %d failed logins", staff.StaffID, until.Format(time.RFC3339), maxFailedLogins)
+		}
+		if err := s.staffRepo.UpdateLoginState(staff.ID, failed, lockedUntil); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrInvalidLogin
+	}
+
+	if staff.FailedLogins > 0 || staff.LockedUntil != nil {
+		if err := s.staffRepo.UpdateLoginState(staff.ID, 0, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.sessionRepo.DeleteExpired(now); err != nil {
+		return nil, err
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	session := &models.StaffSession{
+		Token:     token,
+		Kind:      kind,
+		StaffID:   staff.ID,
+		ExpiresAt: now.Add(s.sessionTTL),
+	}
+	if err := s.sessionRepo.Create(session, hashToken(token)); err != nil {
+		return nil, err
+	}
+
+	staff.PINHash, staff.FailedLogins, staff.LockedUntil = "", 0, nil
+	session.Staff = staff
+	return session, nil
+}
+
+// throttle records a login attempt from an address and refuses it when the
+// address has used up its attempts
+func (s *AuthService) throttle(address string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget attempts that have left the window, for every address, so the
+	// map does not grow with each address ever seen
+	for addr, times := range s.attempts {
+		recent := times[:0]
+		for _, t := range times {
+			if now.Sub(t) < loginAttemptWindow {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(s.attempts, addr)
+		} else {
+			s.attempts[addr] = recent
+		}
+	}
+
+	times := s.attempts[address]
+	if len(times) >= maxLoginAttempts {
+		return &models.LoginLockedError{Until: times[0].Add(loginAttemptWindow)}
+	}
+	s.attempts[address] = append(times, now)
+	return nil
+}
+
+// Authenticate returns the session of a token, if it is still valid
+func (s *AuthService) Authenticate(token string) (*models.StaffSession, error) {
+	if token == "" {
+		return nil, fmt.Errorf("session not found")
+	}
+	return s.sessionRepo.FindByTokenHash(hashToken(token), time.Now())
+}
+
+// Logout ends the session of a token
+func (s *AuthService) Logout(token string) error {
+	return s.sessionRepo.Delete(hashToken(token))
+}
+
+// EnsureInitialPIN gives the first staff member the PIN and the admin role
+// when no staff member has a PIN, so that somebody can log in to a fresh
+// install and manage the rest
+func (s *AuthService) EnsureInitialPIN(pin string) error {
+	count, err := s.staffRepo.CountWithPIN()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if pin == "" {
+		log.Printf("Warning: no staff member has a PIN, so nobody can log in. Set INITIAL_STAFF_PIN to give one to the first staff member.")
+		return nil
+	}
+	hash, err := hashPIN(pin)
+	if err != nil {
+		return fmt.Errorf("INITIAL_STAFF_PIN: %w", err)
+	}
+
+	staffs, err := s.staffRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	if len(staffs) == 0 {
+		return fmt.Errorf("no staff member to give the initial PIN to")
+	}
+	first := staffs[len(staffs)-1] // FindAll returns the newest first
+	if first.Role != models.RoleAdmin {
+		first.Role, first.StoreID = models.RoleAdmin, nil
+		if err := s.staffRepo.Update(first); err != nil {
+			return err
+		}
+	}
+	if err := s.staffRepo.SetPINHash(first.ID, hash); err != nil {
+		return err
+	}
+	log.Printf("Set the initial PIN of staff %s (%s); change it after logging in", first.StaffID, first.Name)
+	return nil
+}
+
+// hashPIN validates a PIN and hashes it for storage
+func hashPIN(pin string) (string, error) {
+	if len(pin) < minPINLength || len(pin) > maxPINLength {
+		return "", fmt.Errorf("PIN must be %d to %d digits", minPINLength, maxPINLength)
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("PIN must be %d to %d digits", minPINLength, maxPINLength)
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), pinHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// newSessionToken returns a random token for a new session
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how a session token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
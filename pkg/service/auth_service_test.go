@@ -0,0 +1,166 @@
+package service
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setupAuthTest(t *testing.T) (*sql.DB, *StaffService, *AuthService) {
+	pinHashCost = bcrypt.MinCost
+	t.Cleanup(func() { pinHashCost = bcrypt.DefaultCost })
+
+	db, err := repository.InitDB(filepath.Join(t.TempDir(), "auth.db"))
+	require.NoError(t, err)
+	require.NoError(t, repository.RunMigrations(db))
+	t.Cleanup(func() { db.Close() })
+
+	repos := repository.NewRepositories(db)
+	return db, &StaffService{repo: repos.Staff}, NewAuthService(repos.Staff, repos.Session, time.Hour)
+}
+
+func TestAuthServiceLogin(t *testing.T) {
+	db, staffService, auth := setupAuthTest(t)
+
+	staff := &models.Staff{StaffID: "CASHIER", Name: "Cashier", PIN: "4321"}
+	require.NoError(t, staffService.CreateStaff(models.Actor{}, staff))
+	assert.Empty(t, staff.PIN)
+	assert.True(t, staff.HasPIN)
+
+	t.Run("correct PIN opens a session", func(t *testing.T) {
+		session, err := auth.Login("CASHIER", "4321", "192.168.1.10", models.SessionAPI)
+		require.NoError(t, err)
+		assert.NotEmpty(t, session.Token)
+		assert.Equal(t, staff.ID, session.StaffID)
+		assert.Empty(t, session.Staff.PINHash)
+
+		found, err := auth.Authenticate(session.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "Cashier", found.Staff.Name)
+
+		// Only a hash of the token is stored
+		var stored int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM staff_session WHERE tokenHash = ?`, session.Token).Scan(&stored))
+		assert.Zero(t, stored)
+
+		require.NoError(t, auth.Logout(session.Token))
+		_, err = auth.Authenticate(session.Token)
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong PIN and unknown staff look the same", func(t *testing.T) {
+		_, err := auth.Login("CASHIER", "0000", "192.168.1.11", models.SessionWeb)
+		assert.ErrorIs(t, err, models.ErrInvalidLogin)
+		_, err = auth.Login("NOBODY", "4321", "192.168.1.11", models.SessionWeb)
+		assert.ErrorIs(t, err, models.ErrInvalidLogin)
+	})
+
+	t.Run("staff without a PIN cannot log in", func(t *testing.T) {
+		require.NoError(t, staffService.CreateStaff(models.Actor{}, &models.Staff{StaffID: "NOPIN", Name: "No PIN"}))
+		_, err := auth.Login("NOPIN", "", "192.168.1.12", models.SessionWeb)
+		assert.ErrorIs(t, err, models.ErrInvalidLogin)
+	})
+
+	t.Run("expired sessions are rejected", func(t *testing.T) {
+		session, err := auth.Login("CASHIER", "4321", "192.168.1.13", models.SessionWeb)
+		require.NoError(t, err)
+		_, err = db.Exec(`UPDATE staff_session SET expiresAt = ?`, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		_, err = auth.Authenticate(session.Token)
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthServiceLockout(t *testing.T) {
+	_, staffService, auth := setupAuthTest(t)
+
+	staff := &models.Staff{StaffID: "CASHIER", Name: "Cashier", PIN: "4321"}
+	require.NoError(t, staffService.CreateStaff(models.Actor{}, staff))
+
+	t.Run("staff is locked out after repeated wrong PINs", func(t *testing.T) {
+		for i := 0; i < maxFailedLogins; i++ {
+			_, err := auth.Login("CASHIER", "0000", "", models.SessionAPI)
+			assert.ErrorIs(t, err, models.ErrInvalidLogin)
+		}
+
+		// Even the right PIN is refused while locked out, from any address
+		_, err := auth.Login("CASHIER", "4321", "192.168.1.50", models.SessionAPI)
+		var lockedErr *models.LoginLockedError
+		require.ErrorAs(t, err, &lockedErr)
+		assert.WithinDuration(t, time.Now().Add(loginLockout), lockedErr.Until, time.Minute)
+	})
+
+	t.Run("a new PIN lifts the lockout and ends sessions", func(t *testing.T) {
+		staff.PIN = "5678"
+		require.NoError(t, staffService.UpdateStaff(models.Actor{}, staff))
+
+		session, err := auth.Login("CASHIER", "5678", "192.168.1.51", models.SessionAPI)
+		require.NoError(t, err)
+
+		staff.PIN = "8765"
+		require.NoError(t, staffService.UpdateStaff(models.Actor{}, staff))
+		_, err = auth.Authenticate(session.Token)
+		assert.Error(t, err)
+	})
+
+	t.Run("an address is throttled whichever staff it tries", func(t *testing.T) {
+		for i := 0; i < maxLoginAttempts; i++ {
+			_, err := auth.Login("GUESS", "0000", "203.0.113.9", models.SessionAPI)
+			assert.ErrorIs(t, err, models.ErrInvalidLogin)
+		}
+
+		_, err := auth.Login("CASHIER", "8765", "203.0.113.9", models.SessionAPI)
+		var lockedErr *models.LoginLockedError
+		assert.ErrorAs(t, err, &lockedErr)
+
+		_, err = auth.Login("CASHIER", "8765", "203.0.113.10", models.SessionAPI)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthServicePINs(t *testing.T) {
+	_, staffService, auth := setupAuthTest(t)
+
+	for _, pin := range []string{"123", "123456789", "12a4", "12 4"} {
+		err := staffService.CreateStaff(models.Actor{}, &models.Staff{Name: "Bad PIN", PIN: pin})
+		assert.Error(t, err, pin)
+	}
+
+	t.Run("staff members are cashiers unless given a role", func(t *testing.T) {
+		staff := &models.Staff{Name: "New", StoreID: new(int)}
+		require.NoError(t, staffService.CreateStaff(models.Actor{}, staff))
+		assert.Equal(t, models.RoleCashier, staff.Role)
+		assert.Nil(t, staff.StoreID)
+
+		staff.Role = ""
+		staff.Name = "Renamed"
+		require.NoError(t, staffService.UpdateStaff(models.Actor{}, staff))
+		assert.Equal(t, models.RoleCashier, staff.Role)
+	})
+
+	t.Run("initial PIN goes to the first staff member once", func(t *testing.T) {
+		require.NoError(t, auth.EnsureInitialPIN("2468"))
+		session, err := auth.Login("STAFF001", "2468", "", models.SessionWeb)
+		require.NoError(t, err)
+		assert.Equal(t, 1, session.StaffID)
+		assert.Equal(t, models.RoleAdmin, session.Staff.Role)
+
+		require.NoError(t, auth.EnsureInitialPIN("1357"))
+		_, err = auth.Login("STAFF001", "1357", "", models.SessionWeb)
+		assert.ErrorIs(t, err, models.ErrInvalidLogin)
+	})
+
+	t.Run("an invalid initial PIN is an error", func(t *testing.T) {
+		_, _, auth := setupAuthTest(t)
+		assert.Error(t, auth.EnsureInitialPIN("12"))
+		assert.NoError(t, auth.EnsureInitialPIN(""))
+	})
+}
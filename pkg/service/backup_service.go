@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/encrypt"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+)
+
+// Backup files are named after the time they were taken, so that their
+// names sort from oldest to newest. They are encrypted; backups taken
+// before encryption was added end in plainBackupSuffix.
+const (
+	backupPrefix      = "kidspos-"
+	backupSuffix      = ".db.enc"
+	plainBackupSuffix = ".db"
+	backupTimeFormat  = "20060102-150405.000"
+)
+
+// BackupService takes encrypted copies of the database while the server
+// runs, keeps the newest few and restores them
+type BackupService struct {
+	repo     *repository.BackupRepository
+	audit    *AuditService
+	dir      string
+	interval time.Duration
+	keep     int
+	// key is the passphrase the backups are encrypted with
+	key string
+	// mu keeps backups and restores from running at the same time
+	mu sync.Mutex
+}
+
+// NewBackupService creates a backup service keeping keep backups in dir,
+// encrypted with key
+func NewBackupService(repo *repository.BackupRepository, audit *AuditService, dir string, interval time.Duration, keep int, key string) *BackupService {
+	if keep < 1 {
+		keep = 1
+	}
+	return &BackupService{
+		repo:     repo,
+		audit:    audit,
+		dir:      dir,
+		interval: interval,
+		keep:     keep,
+		key:      key,
+	}
+}
+
+// CreateBackup takes a backup now and deletes the ones beyond the number
+// to keep
+func (s *BackupService) CreateBackup() (*models.Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createBackup("")
+}
+
+// createBackup takes a backup; the caller holds mu. The copy is written
+// and encrypted under temporary names first, so a backup that fails halfway
+// is never listed.
+func (s *BackupService) createBackup(label string) (*models.Backup, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := backupPrefix + time.Now().Format(backupTimeFormat)
+	if label != "" {
+		name += "-" + label
+	}
+	name += backupSuffix
+	path := filepath.Join(s.dir, name)
+	plainPath := path + ".plain.tmp"
+	tmpPath := path + ".tmp"
+	defer os.Remove(plainPath)
+
+	os.Remove(plainPath)
+	if err := s.repo.BackupTo(plainPath); err != nil {
+		return nil, fmt.Errorf("failed to back up database: %w", err)
+	}
+	if err := encryptFile(plainPath, tmpPath, s.key); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to save backup: %w", err)
+	}
+
+	if err := s.prune(); err != nil {
+		log.Printf("Failed to delete old backups: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Backup{Name: name, Size: info.Size(), CreatedAt: info.ModTime()}, nil
+}
+
+// GetBackups returns the kept backups, newest first
+func (s *BackupService) GetBackups() ([]*models.Backup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []*models.Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isBackupName(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, &models.Backup{Name: name, Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// GetBackupPath returns the path of a kept backup by its name
+func (s *BackupService) GetBackupPath(name string) (string, error) {
+	if name != filepath.Base(name) || !isBackupName(name) {
+		return "", fmt.Errorf("backup not found")
+	}
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("backup not found")
+	}
+	return path, nil
+}
+
+// Restore replaces the database with an uploaded backup, decrypting it if
+// it is encrypted. The file is checked first, and the current database is
+// backed up before it is replaced, so a wrong restore can be undone. That
+// backup is returned. Devices and logins stay as they are now rather than
+// as in the backup; devices only the backup knows are revoked.
+func (s *BackupService) Restore(actor models.Actor, name string, file io.Reader) (*models.Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	upload, err := os.CreateTemp(s.dir, "restore-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(upload.Name())
+
+	_, err = io.Copy(upload, file)
+	if closeErr := upload.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save upload: %w", err)
+	}
+
+	dbPath := upload.Name()
+	if encrypted, err := isEncryptedFile(dbPath); err != nil {
+		return nil, err
+	} else if encrypted {
+		dbPath = upload.Name() + ".plain"
+		defer os.Remove(dbPath)
+		if err := decryptFile(upload.Name(), dbPath, s.key); err != nil {
+			return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+	}
+
+	if err := repository.ValidateBackup(dbPath); err != nil {
+		return nil, fmt.Errorf("invalid backup: %w", err)
+	}
+
+	previous, err := s.createBackup("before-restore")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RestoreFrom(dbPath); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditDatabase, name, models.AuditRestore, nil, map[string]string{
+		"file":           name,
+		"previousBackup": previous.Name,
+	})
+	return previous, nil
+}
+
+// Run takes a backup every interval until ctx is cancelled. A backup is
+// taken at once if the newest one is older than the interval.
+func (s *BackupService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	if backups, err := s.GetBackups(); err != nil || len(backups) == 0 || time.Since(backups[0].CreatedAt) >= s.interval {
+		s.runOnce()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce()
+		}
+	}
+}
+
+func (s *BackupService) runOnce() {
+	backup, err := s.CreateBackup()
+	if err != nil {
+		log.Printf("Backup: %v", err)
+		return
+	}
+	log.Printf("Backup: saved %s", backup.Name)
+}
+
+// prune deletes the oldest backups beyond the number to keep
+func (s *BackupService) prune() error {
+	backups, err := s.GetBackups()
+	if err != nil {
+		return err
+	}
+	for i := s.keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(s.dir, backups[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupPrefix) &&
+		(strings.HasSuffix(name, backupSuffix) || strings.HasSuffix(name, plainBackupSuffix))
+}
+
+// isEncryptedFile reports whether the file starts like an encrypted file
+func isEncryptedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, len(encrypt.Magic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return encrypt.IsEncrypted(header[:n]), nil
+}
+
+// encryptFile writes an encrypted copy of src to dst
+func encryptFile(src, dst, key string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w, err := encrypt.NewWriter(out, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// decryptFile writes a decrypted copy of src to dst
+func decryptFile(src, dst, key string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	r, err := encrypt.NewReader(in, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/encrypt"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBackupTest(t *testing.T, keep int) (*sql.DB, *BackupService) {
+	db, err := repository.InitDB(filepath.Join(t.TempDir(), "kidspos.db"))
+	require.NoError(t, err)
+	require.NoError(t, repository.RunMigrations(db))
+	t.Cleanup(func() { db.Close() })
+
+	repos := repository.NewRepositories(db)
+	audit := &AuditService{repo: repos.Audit}
+	return db, NewBackupService(repos.Backup, audit, filepath.Join(t.TempDir(), "backups"), time.Hour, keep, "test-key")
+}
+
+func countItems(t *testing.T, db *sql.DB) int {
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM item").Scan(&count))
+	return count
+}
+
+func TestBackupServiceCreateBackup(t *testing.T) {
+	_, service := setupBackupTest(t, 2)
+
+	backups, err := service.GetBackups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		backup, err := service.CreateBackup()
+		require.NoError(t, err)
+		assert.Positive(t, backup.Size)
+		names = append(names, backup.Name)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	backups, err = service.GetBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2, "only the newest are kept")
+	assert.Equal(t, names[2], backups[0].Name)
+	assert.Equal(t, names[1], backups[1].Name)
+
+	path, err := service.GetBackupPath(names[2])
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, encrypt.IsEncrypted(data))
+	assert.False(t, bytes.Contains(data, []byte("STAFF001")), "names are not readable")
+
+	_, err = service.GetBackupPath(names[0])
+	assert.Error(t, err)
+	_, err = service.GetBackupPath("../kidspos.db")
+	assert.Error(t, err)
+}
+
+func TestBackupServiceRestore(t *testing.T) {
+	db, service := setupBackupTest(t, 5)
+
+	_, err := db.Exec("INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		"ITEM-001", "Candy", 100, 10, time.Now(), time.Now())
+	require.NoError(t, err)
+	backup, err := service.CreateBackup()
+	require.NoError(t, err)
+	path, err := service.GetBackupPath(backup.Name)
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	_, err = db.Exec("DELETE FROM item")
+	require.NoError(t, err)
+	require.Equal(t, 0, countItems(t, db))
+
+	t.Run("restores a backup", func(t *testing.T) {
+		previous, err := service.Restore(models.Actor{StaffName: "Admin"}, backup.Name, bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Contains(t, previous.Name, "before-restore")
+		assert.Equal(t, 1, countItems(t, db))
+
+		entries, err := service.audit.GetEntries(models.AuditFilter{Entity: models.AuditDatabase})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, models.AuditRestore, entries[0].Action)
+		assert.Equal(t, "Admin", entries[0].StaffName)
+	})
+
+	t.Run("restores a backup taken before encryption", func(t *testing.T) {
+		plainPath := filepath.Join(t.TempDir(), "plain.db")
+		require.NoError(t, service.repo.BackupTo(plainPath))
+		plainData, err := os.ReadFile(plainPath)
+		require.NoError(t, err)
+
+		_, err = service.Restore(models.Actor{}, "plain.db", bytes.NewReader(plainData))
+		require.NoError(t, err)
+		assert.Equal(t, 1, countItems(t, db))
+	})
+
+	t.Run("needs the same key", func(t *testing.T) {
+		other := NewBackupService(service.repo, nil, t.TempDir(), time.Hour, 1, "other-key")
+		_, err := other.Restore(models.Actor{}, backup.Name, bytes.NewReader(data))
+		assert.ErrorContains(t, err, "failed to decrypt backup")
+	})
+
+	t.Run("rejects files that are not backups", func(t *testing.T) {
+		_, err := service.Restore(models.Actor{}, "notes.txt", bytes.NewReader([]byte("hello")))
+		assert.ErrorContains(t, err, "not a SQLite database")
+
+		_, err = service.Restore(models.Actor{}, "broken.db", bytes.NewReader(data[:len(data)/2]))
+		assert.Error(t, err)
+
+		otherPath := filepath.Join(t.TempDir(), "other.db")
+		other, err := repository.InitDB(otherPath)
+		require.NoError(t, err)
+		_, err = other.Exec("CREATE TABLE notes (body TEXT)")
+		require.NoError(t, err)
+		require.NoError(t, other.Close())
+		otherData, err := os.ReadFile(otherPath)
+		require.NoError(t, err)
+		_, err = service.Restore(models.Actor{}, "other.db", bytes.NewReader(otherData))
+		assert.ErrorContains(t, err, "table item is missing")
+
+		assert.Equal(t, 1, countItems(t, db), "the database is left alone")
+	})
+
+	t.Run("keeps the devices and sessions", func(t *testing.T) {
+		mustExec(t, db, `INSERT INTO device (id, name, tokenHash, status) VALUES (1, 'Register 1', 'hash-1', 'approved')`)
+		mustExec(t, db, `INSERT INTO device (id, name, tokenHash, status) VALUES (2, 'Register 2', 'hash-2', 'approved')`)
+		mustExec(t, db, `INSERT INTO staff_session (tokenHash, staffId, kind, expiresAt) VALUES ('old', 1, 'web', ?)`, time.Now().Add(time.Hour))
+		backupPath := filepath.Join(t.TempDir(), "sessions.db")
+		require.NoError(t, service.repo.BackupTo(backupPath))
+		backupData, err := os.ReadFile(backupPath)
+		require.NoError(t, err)
+
+		// Since the backup, a device was revoked, one is gone, one is new
+		// and the staff member logged out and in again
+		mustExec(t, db, `UPDATE device SET status = 'revoked' WHERE id = 1`)
+		mustExec(t, db, `DELETE FROM device WHERE id = 2`)
+		mustExec(t, db, `INSERT INTO device (id, name, tokenHash, status) VALUES (3, 'Register 3', 'hash-3', 'approved')`)
+		mustExec(t, db, `DELETE FROM staff_session`)
+		mustExec(t, db, `INSERT INTO staff_session (tokenHash, staffId, kind, expiresAt) VALUES ('new', 1, 'web', ?)`, time.Now().Add(time.Hour))
+
+		_, err = service.Restore(models.Actor{}, "sessions.db", bytes.NewReader(backupData))
+		require.NoError(t, err)
+
+		statuses := map[int]string{}
+		rows, err := db.Query(`SELECT id, status FROM device`)
+		require.NoError(t, err)
+		for rows.Next() {
+			var id int
+			var status string
+			require.NoError(t, rows.Scan(&id, &status))
+			statuses[id] = status
+		}
+		require.NoError(t, rows.Close())
+		assert.Equal(t, map[int]string{1: "revoked", 2: "revoked", 3: "approved"}, statuses)
+
+		var tokens []string
+		rows, err = db.Query(`SELECT tokenHash FROM staff_session`)
+		require.NoError(t, err)
+		for rows.Next() {
+			var token string
+			require.NoError(t, rows.Scan(&token))
+			tokens = append(tokens, token)
+		}
+		require.NoError(t, rows.Close())
+		assert.Equal(t, []string{"new"}, tokens)
+	})
+
+	t.Run("rejects backups from a newer version", func(t *testing.T) {
+		newerPath := filepath.Join(t.TempDir(), "newer.db")
+		require.NoError(t, service.repo.BackupTo(newerPath))
+		newer, err := repository.InitDB(newerPath)
+		require.NoError(t, err)
+		_, err = newer.Exec(`INSERT INTO schema_migrations (version, name, checksum, appliedAt) VALUES (9999, '9999_future', '', ?)`, time.Now())
+		require.NoError(t, err)
+		_, err = newer.Exec("DELETE FROM item")
+		require.NoError(t, err)
+		require.NoError(t, newer.Close())
+		newerData, err := os.ReadFile(newerPath)
+		require.NoError(t, err)
+
+		_, err = service.Restore(models.Actor{}, "newer.db", bytes.NewReader(newerData))
+		assert.ErrorContains(t, err, "9999_future")
+		assert.Equal(t, 1, countItems(t, db), "the database is left alone")
+
+		pending, err := repository.PendingMigrations(db)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
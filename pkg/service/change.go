@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+)
+
+// currencyDenominations holds the coins and bills in circulation per currency,
+// in the currency's smallest unit, largest first
+var currencyDenominations = map[string][]models.Denomination{
+	"JPY": {
+		{Value: 10000, Kind: models.DenominationBill},
+		{Value: 5000, Kind: models.DenominationBill},
+		{Value: 2000, Kind: models.DenominationBill},
+		{Value: 1000, Kind: models.DenominationBill},
+		{Value: 500, Kind: models.DenominationCoin},
+		{Value: 100, Kind: models.DenominationCoin},
+		{Value: 50, Kind: models.DenominationCoin},
+		{Value: 10, Kind: models.DenominationCoin},
+		{Value: 5, Kind: models.DenominationCoin},
+		{Value: 1, Kind: models.DenominationCoin},
+	},
+	"USD": {
+		{Value: 10000, Kind: models.DenominationBill},
+		{Value: 5000, Kind: models.DenominationBill},
+		{Value: 2000, Kind: models.DenominationBill},
+		{Value: 1000, Kind: models.DenominationBill},
+		{Value: 500, Kind: models.DenominationBill},
+		{Value: 100, Kind: models.DenominationBill},
+		{Value: 25, Kind: models.DenominationCoin},
+		{Value: 10, Kind: models.DenominationCoin},
+		{Value: 5, Kind: models.DenominationCoin},
+		{Value: 1, Kind: models.DenominationCoin},
+	},
+	"EUR": {
+		{Value: 50000, Kind: models.DenominationBill},
+		{Value: 20000, Kind: models.DenominationBill},
+		{Value: 10000, Kind: models.DenominationBill},
+		{Value: 5000, Kind: models.DenominationBill},
+		{Value: 2000, Kind: models.DenominationBill},
+		{Value: 1000, Kind: models.DenominationBill},
+		{Value: 500, Kind: models.DenominationBill},
+		{Value: 200, Kind: models.DenominationCoin},
+		{Value: 100, Kind: models.DenominationCoin},
+		{Value: 50, Kind: models.DenominationCoin},
+		{Value: 20, Kind: models.DenominationCoin},
+		{Value: 10, Kind: models.DenominationCoin},
+		{Value: 5, Kind: models.DenominationCoin},
+		{Value: 2, Kind: models.DenominationCoin},
+		{Value: 1, Kind: models.DenominationCoin},
+	},
+}
+
+// ParseDenominations parses a comma separated list of value:kind pairs such as
+// "1000:bill,500:coin,100:coin". The result is sorted largest first.
+func ParseDenominations(value string) ([]models.Denomination, error) {
+	var denominations []models.Denomination
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		amount, kind, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid denomination %q: expected value:coin or value:bill", part)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(amount))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid denomination value %q", amount)
+		}
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind != models.DenominationCoin && kind != models.DenominationBill {
+			return nil, fmt.Errorf("invalid denomination kind %q: must be coin or bill", kind)
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("duplicate denomination %d", n)
+		}
+		seen[n] = true
+
+		denominations = append(denominations, models.Denomination{Value: n, Kind: kind})
+	}
+
+	if len(denominations) == 0 {
+		return nil, fmt.Errorf("at least one denomination is required")
+	}
+
+	sort.Slice(denominations, func(i, j int) bool {
+		return denominations[i].Value > denominations[j].Value
+	})
+	return denominations, nil
+}
+
+// BreakDownChange splits an amount into as few coins and bills as the
+// denominations allow, taking the largest first. Denominations must be sorted
+// largest first. Any remainder smaller than the smallest denomination is left
+// out of the breakdown.
+func BreakDownChange(amount int, denominations []models.Denomination) []models.ChangeCount {
+	var breakdown []models.ChangeCount
+	for _, d := range denominations {
+		if amount < d.Value {
+			continue
+		}
+		count := amount / d.Value
+		amount -= count * d.Value
+		breakdown = append(breakdown, models.ChangeCount{Denomination: d, Count: count})
+	}
+	return breakdown
+}
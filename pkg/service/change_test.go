@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakDownChange(t *testing.T) {
+	t.Run("JPY", func(t *testing.T) {
+		breakdown := BreakDownChange(7680, currencyDenominations["JPY"])
+
+		assert.Equal(t, []models.ChangeCount{
+			{Denomination: models.Denomination{Value: 5000, Kind: "bill"}, Count: 1},
+			{Denomination: models.Denomination{Value: 2000, Kind: "bill"}, Count: 1},
+			{Denomination: models.Denomination{Value: 500, Kind: "coin"}, Count: 1},
+			{Denomination: models.Denomination{Value: 100, Kind: "coin"}, Count: 1},
+			{Denomination: models.Denomination{Value: 50, Kind: "coin"}, Count: 1},
+			{Denomination: models.Denomination{Value: 10, Kind: "coin"}, Count: 3},
+		}, breakdown)
+	})
+
+	t.Run("Zero", func(t *testing.T) {
+		assert.Empty(t, BreakDownChange(0, currencyDenominations["JPY"]))
+	})
+
+	t.Run("Remainder below smallest denomination", func(t *testing.T) {
+		denominations, err := ParseDenominations("100:coin,10:coin")
+		require.NoError(t, err)
+
+		breakdown := BreakDownChange(125, denominations)
+
+		assert.Equal(t, []models.ChangeCount{
+			{Denomination: models.Denomination{Value: 100, Kind: "coin"}, Count: 1},
+			{Denomination: models.Denomination{Value: 10, Kind: "coin"}, Count: 2},
+		}, breakdown)
+	})
+}
+
+func TestParseDenominations(t *testing.T) {
+	t.Run("Sorted largest first", func(t *testing.T) {
+		denominations, err := ParseDenominations("100:coin, 1000:BILL ,500:coin")
+
+		require.NoError(t, err)
+		assert.Equal(t, []models.Denomination{
+			{Value: 1000, Kind: "bill"},
+			{Value: 500, Kind: "coin"},
+			{Value: 100, Kind: "coin"},
+		}, denominations)
+	})
+
+	for _, value := range []string{"", "100", "abc:coin", "0:coin", "100:note", "100:coin,100:bill"} {
+		t.Run("Invalid "+value, func(t *testing.T) {
+			_, err := ParseDenominations(value)
+			assert.Error(t, err)
+		})
+	}
+}
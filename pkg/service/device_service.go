@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+)
+
+// deviceTouchInterval is how often the last-seen time of a device is
+// written while nothing else about it changes
+const deviceTouchInterval = time.Minute
+
+// DeviceService registers register tablets and checks their tokens. A
+// tablet gets its token when it registers, but the token only opens the
+// register API once an admin has approved the tablet.
+type DeviceService struct {
+	repo  *repository.DeviceRepository
+	audit *AuditService
+}
+
+func (s *DeviceService) GetAllDevices() ([]*models.Device, error) {
+	return s.repo.FindAll()
+}
+
+func (s *DeviceService) GetDevice(id int) (*models.Device, error) {
+	return s.repo.FindByID(id)
+}
+
+// Register adds a pending device and returns it with its token, which is
+// not shown again
+func (s *DeviceService) Register(name, appVersion, ip string) (*models.Device, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("device name is required")
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	device := &models.Device{
+		Name:       name,
+		Status:     models.DevicePending,
+		AppVersion: appVersion,
+		LastIP:     ip,
+	}
+	if err := s.repo.Create(device, hashToken(token)); err != nil {
+		return nil, err
+	}
+	s.audit.Record(models.Actor{DeviceID: &device.ID}, models.AuditDevice, device.ID, models.AuditCreate, nil, device)
+	device.Token = token
+	return device, nil
+}
+
+// Authenticate finds the device of a token and records that it was seen.
+// Pending devices are returned too, so that they can wait for approval; it
+// is up to the caller to check the status.
+func (s *DeviceService) Authenticate(token, ip, appVersion string) (*models.Device, error) {
+	device, err := s.repo.FindByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, models.ErrInvalidDeviceToken
+	}
+	if device.Status == models.DeviceRevoked {
+		return nil, models.ErrDeviceRevoked
+	}
+
+	now := time.Now()
+	changed := device.LastIP != ip || (appVersion != "" && device.AppVersion != appVersion)
+	if changed || device.LastSeenAt == nil || now.Sub(*device.LastSeenAt) >= deviceTouchInterval {
+		if err := s.repo.Touch(device.ID, ip, appVersion, now); err != nil {
+			return nil, err
+		}
+		device.LastIP, device.LastSeenAt = ip, &now
+		if appVersion != "" {
+			device.AppVersion = appVersion
+		}
+	}
+	return device, nil
+}
+
+// Approve lets a pending device use the register API
+func (s *DeviceService) Approve(actor models.Actor, id int) (*models.Device, error) {
+	device, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if device.Status != models.DevicePending {
+		return nil, fmt.Errorf("only pending devices can be approved")
+	}
+	if err := s.repo.UpdateStatus(id, device.Status, models.DeviceApproved); err != nil {
+		return nil, err
+	}
+	approved, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, models.AuditDevice, id, models.AuditApprove, device, approved)
+	return approved, nil
+}
+
+// Revoke stops a device's token from working for good. The tablet has to
+// register again to get a new one.
+func (s *DeviceService) Revoke(actor models.Actor, id int) (*models.Device, error) {
+	device, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if device.Status == models.DeviceRevoked {
+		return nil, fmt.Errorf("device has already been revoked")
+	}
+	if err := s.repo.UpdateStatus(id, device.Status, models.DeviceRevoked); err != nil {
+		return nil, err
+	}
+	revoked, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, models.AuditDevice, id, models.AuditRevoke, device, revoked)
+	return revoked, nil
+}
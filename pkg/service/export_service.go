@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/encrypt"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+)
+
+// ExportService writes the catalog as a versioned JSON document and loads
+// such documents, so that several shops can share one catalog
+type ExportService struct {
+	repo  *repository.ExportRepository
+	audit *AuditService
+	// key is the passphrase exports are encrypted with, the same as backups
+	key string
+}
+
+// NewExportService creates an export service encrypting with key
+func NewExportService(repo *repository.ExportRepository, audit *AuditService, key string) *ExportService {
+	return &ExportService{repo: repo, audit: audit, key: key}
+}
+
+// Export returns the stores, items, staff and settings, and the sales if
+// includeSales is set
+func (s *ExportService) Export(includeSales bool) (*models.Export, error) {
+	return s.repo.Export(includeSales)
+}
+
+// Encode writes doc as JSON, encrypted if encrypted is set
+func (s *ExportService) Encode(w io.Writer, doc *models.Export, encrypted bool) error {
+	if !encrypted {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	}
+
+	ew, err := encrypt.NewWriter(w, s.key)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(ew).Encode(doc); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// Import loads an export document in merge or replace mode, decrypting it
+// first if it is encrypted. The document is checked as a whole before
+// anything is written. Records that clash with this server are reported in
+// the result. A dry run only reports what the import would do.
+func (s *ExportService) Import(actor models.Actor, r io.Reader, mode string, dryRun bool) (*models.ImportResult, error) {
+	if mode == "" {
+		mode = models.ImportMerge
+	}
+	if mode != models.ImportMerge && mode != models.ImportReplace {
+		return nil, fmt.Errorf("invalid import mode %q: must be merge or replace", mode)
+	}
+
+	doc, err := s.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExport(doc); err != nil {
+		return nil, fmt.Errorf("invalid export: %w", err)
+	}
+
+	result, err := s.repo.Import(doc, mode, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		s.audit.Record(actor, models.AuditDatabase, "import", models.AuditImport, nil, result)
+	}
+	return result, nil
+}
+
+func (s *ExportService) decode(r io.Reader) (*models.Export, error) {
+	br := bufio.NewReader(r)
+	var input io.Reader = br
+	if header, _ := br.Peek(len(encrypt.Magic)); encrypt.IsEncrypted(header) {
+		dr, err := encrypt.NewReader(br, s.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt export: %w", err)
+		}
+		input = dr
+	}
+
+	doc := &models.Export{}
+	if err := json.NewDecoder(input).Decode(doc); err != nil {
+		if errors.Is(err, encrypt.ErrDecrypt) {
+			return nil, fmt.Errorf("failed to decrypt export: %w", err)
+		}
+		return nil, fmt.Errorf("invalid export: %w", err)
+	}
+	return doc, nil
+}
+
+// validateExport checks the document and fills in defaults. Each record
+// needs a unique business key and the values the services would accept.
+func validateExport(doc *models.Export) error {
+	if doc.Version == 0 {
+		return fmt.Errorf("not a KidsPOS export")
+	}
+	if doc.Version > models.ExportVersion {
+		return fmt.Errorf("version %d is newer than this server supports (%d)", doc.Version, models.ExportVersion)
+	}
+
+	stores := map[string]bool{}
+	for _, store := range doc.Stores {
+		if store.StoreID == "" {
+			return fmt.Errorf("store ID is required")
+		}
+		if stores[store.StoreID] {
+			return fmt.Errorf("store %s is listed twice", store.StoreID)
+		}
+		stores[store.StoreID] = true
+		if store.Name == "" {
+			return fmt.Errorf("store %s: store name is required", store.StoreID)
+		}
+	}
+
+	codes := map[string]bool{}
+	for _, item := range doc.Items {
+		if item.ItemID == "" {
+			return fmt.Errorf("item ID is required")
+		}
+		if codes[item.ItemID] {
+			return fmt.Errorf("item code %s is listed twice", item.ItemID)
+		}
+		codes[item.ItemID] = true
+	}
+	for _, item := range doc.Items {
+		if item.Name == "" {
+			return fmt.Errorf("item %s: item name is required", item.ItemID)
+		}
+		if item.Price < 0 {
+			return fmt.Errorf("item %s: item price must be non-negative", item.ItemID)
+		}
+		if item.Stock < 0 {
+			return fmt.Errorf("item %s: item stock must be non-negative", item.ItemID)
+		}
+		for _, code := range item.Aliases {
+			if code == "" {
+				return fmt.Errorf("item %s: alias code is required", item.ItemID)
+			}
+			if codes[code] {
+				return fmt.Errorf("item code %s is listed twice", code)
+			}
+			codes[code] = true
+		}
+	}
+
+	staffs := map[string]bool{}
+	for i := range doc.Staff {
+		staff := &doc.Staff[i]
+		if staff.StaffID == "" {
+			return fmt.Errorf("staff ID is required")
+		}
+		if staffs[staff.StaffID] {
+			return fmt.Errorf("staff %s is listed twice", staff.StaffID)
+		}
+		staffs[staff.StaffID] = true
+		if staff.Name == "" {
+			return fmt.Errorf("staff %s: staff name is required", staff.StaffID)
+		}
+		if staff.Role == "" {
+			staff.Role = models.RoleCashier
+		}
+		if !models.ValidRole(staff.Role) {
+			return fmt.Errorf("staff %s: invalid role: %s", staff.StaffID, staff.Role)
+		}
+		if staff.Role != models.RoleStoreManager {
+			staff.StoreID = ""
+		} else if staff.StoreID == "" {
+			return fmt.Errorf("staff %s: a store manager needs a store", staff.StaffID)
+		}
+	}
+
+	settings := map[string]bool{}
+	for i := range doc.Settings {
+		setting := &doc.Settings[i]
+		if err := validateSetting(setting.Key, setting.Value); err != nil {
+			return fmt.Errorf("setting %s: %w", setting.Key, err)
+		}
+		if settings[setting.Key] {
+			return fmt.Errorf("setting %s is listed twice", setting.Key)
+		}
+		settings[setting.Key] = true
+		if setting.Type == "" {
+			setting.Type = "string"
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/encrypt"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupExportTest(t *testing.T, key string) (*sql.DB, *ExportService) {
+	db, err := repository.InitDB(filepath.Join(t.TempDir(), "kidspos.db"))
+	require.NoError(t, err)
+	require.NoError(t, repository.RunMigrations(db))
+	t.Cleanup(func() { db.Close() })
+
+	repos := repository.NewRepositories(db)
+	audit := &AuditService{repo: repos.Audit}
+	return db, NewExportService(repos.Export, audit, key)
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	_, err := db.Exec(query, args...)
+	require.NoError(t, err)
+}
+
+// seedExportTest adds a second store with an item, an alias, a store
+// manager and a sale to the seeded database
+func seedExportTest(t *testing.T, db *sql.DB) {
+	now := time.Now()
+	mustExec(t, db, `INSERT INTO store (storeId, name, createdAt, updatedAt) VALUES ('STORE002', 'Bakery', ?, ?)`, now, now)
+	mustExec(t, db, `INSERT INTO item (itemId, name, price, stock, storeId, createdAt, updatedAt) VALUES ('ITEM-001', 'Bread', 120, 5, 2, ?, ?)`, now, now)
+	mustExec(t, db, `INSERT INTO item (itemId, name, price, stock, createdAt, updatedAt) VALUES ('ITEM-002', 'Candy', 50, 20, ?, ?)`, now, now)
+	mustExec(t, db, `INSERT INTO item_alias (itemId, code, createdAt) VALUES (1, '4901234567894', ?)`, now)
+	mustExec(t, db, `INSERT INTO staff (staffId, name, role, storeId, createdAt, updatedAt) VALUES ('STAFF002', 'Hana', 'store_manager', 2, ?, ?)`, now, now)
+	mustExec(t, db, `INSERT INTO sale (storeId, staffId, subtotal, totalPrice, deposit, change, saleAt) VALUES (2, 2, 240, 240, 300, 60, ?)`, now)
+	mustExec(t, db, `INSERT INTO sale_detail (saleId, itemId, quantity, price, listPrice) VALUES (1, 1, 2, 120, 120)`)
+}
+
+func exportDocument(t *testing.T, service *ExportService, includeSales bool) *models.Export {
+	doc, err := service.Export(includeSales)
+	require.NoError(t, err)
+	return doc
+}
+
+func encodeExport(t *testing.T, service *ExportService, doc *models.Export, encrypted bool) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, service.Encode(&buf, doc, encrypted))
+	return buf.Bytes()
+}
+
+func TestExportServiceExport(t *testing.T) {
+	db, service := setupExportTest(t, "test-key")
+	seedExportTest(t, db)
+
+	doc := exportDocument(t, service, false)
+	assert.Equal(t, models.ExportVersion, doc.Version)
+	assert.Len(t, doc.Stores, 2)
+	require.Len(t, doc.Items, 2)
+	assert.Equal(t, models.ExportItem{
+		ItemID: "ITEM-001", Name: "Bread", Price: 120, Stock: 5, StoreID: "STORE002",
+		Aliases: []string{"4901234567894"},
+	}, doc.Items[0])
+	assert.Contains(t, doc.Staff, models.ExportStaff{StaffID: "STAFF002", Name: "Hana", Role: models.RoleStoreManager, StoreID: "STORE002"})
+	assert.NotEmpty(t, doc.Settings)
+	assert.Nil(t, doc.Sales)
+
+	doc = exportDocument(t, service, true)
+	require.Len(t, doc.Sales, 1)
+	assert.Equal(t, "STORE002", doc.Sales[0].StoreID)
+	assert.Equal(t, "STAFF002", doc.Sales[0].StaffID)
+	require.Len(t, doc.Sales[0].Details, 1)
+	assert.Equal(t, "ITEM-001", doc.Sales[0].Details[0].ItemID)
+
+	t.Run("encrypted", func(t *testing.T) {
+		data := encodeExport(t, service, doc, true)
+		assert.True(t, encrypt.IsEncrypted(data))
+		assert.False(t, bytes.Contains(data, []byte("Hana")), "names are not readable")
+	})
+}
+
+func TestExportServiceImportIntoNewServer(t *testing.T) {
+	db, service := setupExportTest(t, "test-key")
+	seedExportTest(t, db)
+	data := encodeExport(t, service, exportDocument(t, service, true), true)
+
+	target, targetService := setupExportTest(t, "test-key")
+	result, err := targetService.Import(models.Actor{StaffName: "Admin"}, bytes.NewReader(data), "", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.ImportMerge, result.Mode)
+	assert.Equal(t, models.ImportCounts{Created: 1, Unchanged: 1}, result.Stores)
+	assert.Equal(t, models.ImportCounts{Created: 2}, result.Items)
+	assert.Equal(t, models.ImportCounts{Created: 1, Unchanged: 1}, result.Staff)
+	assert.Zero(t, result.Settings.Created+result.Settings.Updated)
+	assert.Equal(t, 1, result.SalesSkipped)
+	assert.Empty(t, result.Conflicts, "skipped sales are no conflicts")
+	var sales int
+	require.NoError(t, target.QueryRow(`SELECT COUNT(*) FROM sale`).Scan(&sales))
+	assert.Zero(t, sales)
+
+	// References point at the target's own rows
+	var storeKey string
+	require.NoError(t, target.QueryRow(`SELECT s.storeId FROM item i JOIN store s ON s.id = i.storeId WHERE i.itemId = 'ITEM-001'`).Scan(&storeKey))
+	assert.Equal(t, "STORE002", storeKey)
+	var alias string
+	require.NoError(t, target.QueryRow(`SELECT a.code FROM item_alias a JOIN item i ON i.id = a.itemId WHERE i.itemId = 'ITEM-001'`).Scan(&alias))
+	assert.Equal(t, "4901234567894", alias)
+
+	entries, err := targetService.audit.GetEntries(models.AuditFilter{Action: models.AuditImport})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Admin", entries[0].StaffName)
+
+	t.Run("importing again changes nothing", func(t *testing.T) {
+		result, err := targetService.Import(models.Actor{}, bytes.NewReader(data), models.ImportMerge, false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportCounts{Unchanged: 2}, result.Items)
+		assert.Equal(t, 1, result.SalesSkipped)
+		assert.Empty(t, result.Conflicts)
+	})
+}
+
+func TestExportServiceImportModes(t *testing.T) {
+	_, source := setupExportTest(t, "test-key")
+	doc := exportDocument(t, source, false)
+	// The alias moves from the first item to the second
+	doc.Items = []models.ExportItem{
+		{ItemID: "ITEM-001", Name: "Bread", Price: 150, Stock: 5},
+		{ItemID: "ITEM-002", Name: "Candy", Price: 50, Stock: 20, Aliases: []string{"CODE-A"}},
+	}
+	for i := range doc.Settings {
+		if doc.Settings[i].Key == "shopName" {
+			doc.Settings[i].Value = "Spring Fair"
+		}
+	}
+	data := encodeExport(t, source, doc, false)
+
+	setupTarget := func(t *testing.T) (*sql.DB, *ExportService) {
+		db, service := setupExportTest(t, "test-key")
+		seedExportTest(t, db)
+		mustExec(t, db, `UPDATE item_alias SET code = 'CODE-A' WHERE itemId = 1`)
+		return db, service
+	}
+
+	t.Run("merge keeps what differs", func(t *testing.T) {
+		db, service := setupTarget(t)
+
+		result, err := service.Import(models.Actor{}, bytes.NewReader(data), models.ImportMerge, false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportCounts{Unchanged: 1, Skipped: 1}, result.Items)
+		assert.Contains(t, result.Conflicts, models.ImportConflict{
+			Kind: models.AuditItem, Key: "ITEM-001", Fields: []string{"price", "storeId"},
+			Reason: "differs from this server; kept as it is",
+		})
+		assert.Contains(t, result.Conflicts, models.ImportConflict{
+			Kind: models.AuditItem, Key: "ITEM-002", Fields: []string{"aliases"},
+			Reason: "alias CODE-A is already in use",
+		})
+		assert.Contains(t, result.Conflicts, models.ImportConflict{
+			Kind: models.AuditSetting, Key: "shopName", Fields: []string{"value"},
+			Reason: "differs from this server; kept as it is",
+		})
+
+		var price int
+		require.NoError(t, db.QueryRow(`SELECT price FROM item WHERE itemId = 'ITEM-001'`).Scan(&price))
+		assert.Equal(t, 120, price)
+	})
+
+	t.Run("replace overwrites", func(t *testing.T) {
+		db, service := setupTarget(t)
+		mustExec(t, db, `INSERT INTO item (itemId, name, price, stock) VALUES ('ITEM-003', 'Juice', 80, 3)`)
+
+		result, err := service.Import(models.Actor{}, bytes.NewReader(data), models.ImportReplace, false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportCounts{Updated: 2, Deleted: 1}, result.Items)
+		assert.Equal(t, 1, result.Settings.Updated)
+		assert.Empty(t, result.Conflicts)
+
+		var price int
+		require.NoError(t, db.QueryRow(`SELECT price FROM item WHERE itemId = 'ITEM-001'`).Scan(&price))
+		assert.Equal(t, 150, price)
+		var owner string
+		require.NoError(t, db.QueryRow(`SELECT i.itemId FROM item_alias a JOIN item i ON i.id = a.itemId WHERE a.code = 'CODE-A'`).Scan(&owner))
+		assert.Equal(t, "ITEM-002", owner)
+		var deleted bool
+		require.NoError(t, db.QueryRow(`SELECT isDeleted FROM item WHERE itemId = 'ITEM-003'`).Scan(&deleted))
+		assert.True(t, deleted)
+		var stores int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM store`).Scan(&stores))
+		assert.Equal(t, 2, stores, "stores are never deleted")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		db, service := setupTarget(t)
+
+		result, err := service.Import(models.Actor{}, bytes.NewReader(data), models.ImportReplace, true)
+		require.NoError(t, err)
+		assert.True(t, result.DryRun)
+		assert.Equal(t, 2, result.Items.Updated)
+
+		var price int
+		require.NoError(t, db.QueryRow(`SELECT price FROM item WHERE itemId = 'ITEM-001'`).Scan(&price))
+		assert.Equal(t, 120, price)
+		entries, err := service.audit.GetEntries(models.AuditFilter{Action: models.AuditImport})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestExportServiceImportConflicts(t *testing.T) {
+	_, service := setupExportTest(t, "test-key")
+	doc := &models.Export{
+		Version: models.ExportVersion,
+		Items:   []models.ExportItem{{ItemID: "ITEM-009", Name: "Cookie", Price: 30, StoreID: "STORE404"}},
+		Staff:   []models.ExportStaff{{StaffID: "STAFF009", Name: "Ken", Role: models.RoleStoreManager, StoreID: "STORE404"}},
+	}
+
+	result, err := service.Import(models.Actor{}, bytes.NewReader(encodeExport(t, service, doc, false)), models.ImportMerge, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Items.Skipped)
+	assert.Equal(t, 1, result.Staff.Skipped)
+	require.Len(t, result.Conflicts, 2)
+	assert.Equal(t, "store STORE404 does not exist", result.Conflicts[0].Reason)
+}
+
+func TestExportServiceImportRejects(t *testing.T) {
+	_, service := setupExportTest(t, "test-key")
+	valid := exportDocument(t, service, false)
+
+	tests := []struct {
+		name   string
+		modify func(doc *models.Export)
+		mode   string
+		err    string
+	}{
+		{"unknown mode", func(doc *models.Export) {}, "overwrite", "invalid import mode"},
+		{"no version", func(doc *models.Export) { doc.Version = 0 }, "", "not a KidsPOS export"},
+		{"newer version", func(doc *models.Export) { doc.Version = models.ExportVersion + 1 }, "", "newer than this server supports"},
+		{"duplicate item", func(doc *models.Export) {
+			doc.Items = []models.ExportItem{{ItemID: "A", Name: "A"}, {ItemID: "B", Name: "B", Aliases: []string{"A"}}}
+		}, "", "item code A is listed twice"},
+		{"negative price", func(doc *models.Export) {
+			doc.Items = []models.ExportItem{{ItemID: "A", Name: "A", Price: -1}}
+		}, "", "item price must be non-negative"},
+		{"invalid role", func(doc *models.Export) {
+			doc.Staff = []models.ExportStaff{{StaffID: "S", Name: "S", Role: "owner"}}
+		}, "", "invalid role"},
+		{"invalid setting", func(doc *models.Export) {
+			doc.Settings = []models.ExportSetting{{Key: "taxRounding", Value: "up"}}
+		}, "", "invalid tax rounding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := *valid
+			tt.modify(&doc)
+			_, err := service.Import(models.Actor{}, bytes.NewReader(encodeExport(t, service, &doc, false)), tt.mode, false)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	t.Run("not JSON", func(t *testing.T) {
+		_, err := service.Import(models.Actor{}, strings.NewReader("itemId,name\n"), "", false)
+		assert.ErrorContains(t, err, "invalid export")
+	})
+
+	t.Run("needs the same key", func(t *testing.T) {
+		_, other := setupExportTest(t, "other-key")
+		data := encodeExport(t, other, valid, true)
+		_, err := service.Import(models.Actor{}, bytes.NewReader(data), "", false)
+		assert.ErrorContains(t, err, "failed to decrypt export")
+	})
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/barcode"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/pdf"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/receipt"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+)
+
+// A4 paper in millimeters
+const (
+	a4Width  = 210.0
+	a4Height = 297.0
+)
+
+// LabelLayout describes an A4 label sheet. Labels are laid out in a grid
+// centered on the page: the bottom and right margins mirror the top and
+// left ones. Lengths are in millimeters.
+type LabelLayout struct {
+	Name       string  `json:"name"`
+	Label      string  `json:"label"`
+	Columns    int     `json:"columns"`
+	Rows       int     `json:"rows"`
+	MarginTop  float64 `json:"marginTop"`
+	MarginLeft float64 `json:"marginLeft"`
+	GapX       float64 `json:"gapX"`
+	GapY       float64 `json:"gapY"`
+}
+
+// LabelLayouts are common Japanese A4 label sheets
+var LabelLayouts = []LabelLayout{
+	{Name: "a4-12", Label: "12面（2×6, 86.4×42.3mm）", Columns: 2, Rows: 6, MarginTop: 21.6, MarginLeft: 18.6},
+	{Name: "a4-18", Label: "18面（3×6, 70×42.3mm）", Columns: 3, Rows: 6, MarginTop: 21.6, MarginLeft: 0},
+	{Name: "a4-24", Label: "24面（3×8, 70×33.9mm）", Columns: 3, Rows: 8, MarginTop: 12.9, MarginLeft: 0},
+	{Name: "a4-44", Label: "44面（4×11, 48.3×25.4mm）", Columns: 4, Rows: 11, MarginTop: 8.8, MarginLeft: 8.4},
+	{Name: "a4-65", Label: "65面（5×13, 38.1×21.2mm）", Columns: 5, Rows: 13, MarginTop: 10.7, MarginLeft: 4.75, GapX: 2.5},
+}
+
+// FindLabelLayout returns the predefined layout with the given name
+func FindLabelLayout(name string) (LabelLayout, error) {
+	for _, layout := range LabelLayouts {
+		if layout.Name == name {
+			return layout, nil
+		}
+	}
+	return LabelLayout{}, fmt.Errorf("unknown label layout: %s", name)
+}
+
+// LabelSize returns the width and height of one label
+func (l LabelLayout) LabelSize() (float64, float64) {
+	width := (a4Width - 2*l.MarginLeft - float64(l.Columns-1)*l.GapX) / float64(l.Columns)
+	height := (a4Height - 2*l.MarginTop - float64(l.Rows-1)*l.GapY) / float64(l.Rows)
+	return width, height
+}
+
+// Validate checks that the labels fit on the page and are big enough to
+// carry a readable QR code
+func (l LabelLayout) Validate() error {
+	if l.Columns < 1 || l.Rows < 1 {
+		return fmt.Errorf("label sheet needs at least one column and one row")
+	}
+	if l.MarginTop < 0 || l.MarginLeft < 0 || l.GapX < 0 || l.GapY < 0 {
+		return fmt.Errorf("label margins and gaps must be non-negative")
+	}
+	width, height := l.LabelSize()
+	if width < 15 || height < 10 {
+		return fmt.Errorf("labels of %.1f×%.1fmm are too small", width, height)
+	}
+	return nil
+}
+
+// LabelService lays out price labels for items
+type LabelService struct {
+	itemRepo *repository.ItemRepository
+	settings *SettingService
+}
+
+// GetItems returns the items with the given IDs in that order, or all
+// items when no IDs are given
+func (s *LabelService) GetItems(ids []int) ([]*models.Item, error) {
+	if len(ids) == 0 {
+		return s.itemRepo.FindAll()
+	}
+
+	items := make([]*models.Item, 0, len(ids))
+	for _, id := range ids {
+		item, err := s.itemRepo.FindByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("item %d not found", id)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// WriteLabelSheetPDF writes labels for the items with the given IDs, or
+// for all items, as a PDF. Every item gets copies labels in a row.
+func (s *LabelService) WriteLabelSheetPDF(w io.Writer, ids []int, layout LabelLayout, copies int) error {
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+	if copies < 1 || copies > 100 {
+		return fmt.Errorf("copies must be between 1 and 100")
+	}
+
+	items, err := s.GetItems(ids)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("there are no items to print")
+	}
+	currency, err := s.settings.GetValue("currency", "JPY")
+	if err != nil {
+		return err
+	}
+
+	labelWidth, labelHeight := layout.LabelSize()
+	perPage := layout.Columns * layout.Rows
+
+	doc := pdf.New()
+	var page *pdf.Page
+	n := 0
+	for _, item := range items {
+		code, err := barcode.EncodeQR(item.ItemID)
+		if err != nil {
+			return err
+		}
+		price := receipt.FormatMoney(item.Price, currency)
+
+		for i := 0; i < copies; i++ {
+			if n%perPage == 0 {
+				page = doc.AddPage(a4Width*pdf.MM, a4Height*pdf.MM)
+			}
+			column := n % perPage % layout.Columns
+			row := n % perPage / layout.Columns
+			x := layout.MarginLeft + float64(column)*(labelWidth+layout.GapX)
+			y := layout.MarginTop + float64(row)*(labelHeight+layout.GapY)
+			drawLabel(page, x*pdf.MM, y*pdf.MM, labelWidth*pdf.MM, labelHeight*pdf.MM, item.Name, price, code)
+			n++
+		}
+	}
+
+	return doc.Write(w)
+}
+
+// drawLabel draws the QR code on the left of a label and the name and
+// price on the right. All lengths are in points.
+func drawLabel(page *pdf.Page, x, y, width, height float64, name, price string, code *barcode.QRCode) {
+	padding := height * 0.1
+	qrSize := height - 2*padding
+	if qrSize > width*0.45 {
+		qrSize = width * 0.45
+	}
+	drawQRCode(page, x+padding, y+(height-qrSize)/2, qrSize, code)
+
+	textX := x + 2*padding + qrSize
+	textWidth := x + width - padding - textX
+
+	priceSize := fitTextSize(price, height*0.28, textWidth)
+	nameSize := height * 0.16
+	nameLines := wrapText(name, nameSize, textWidth, 2)
+
+	lineHeight := nameSize * 1.25
+	top := y + padding + nameSize
+	for i, line := range nameLines {
+		page.Text(textX, top+float64(i)*lineHeight, nameSize, false, line)
+	}
+	page.Text(textX, y+height-padding-priceSize*0.2, priceSize, true, price)
+}
+
+// drawQRCode draws a QR code as a square of the given size, without a quiet
+// zone. Dark modules next to each other are merged into one rectangle.
+func drawQRCode(page *pdf.Page, x, y, size float64, code *barcode.QRCode) {
+	module := size / float64(code.Size)
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; col++ {
+			if !code.Dark(col, row) {
+				continue
+			}
+			start := col
+			for col+1 < code.Size && code.Dark(col+1, row) {
+				col++
+			}
+			page.Rect(x+float64(start)*module, y+float64(row)*module, float64(col-start+1)*module, module)
+		}
+	}
+}
+
+// fitTextSize shrinks the font size until text fits the width
+func fitTextSize(text string, size, width float64) float64 {
+	if textWidth := pdf.TextWidth(text, size); textWidth > width {
+		return size * width / textWidth
+	}
+	return size
+}
+
+// wrapText breaks text into at most maxLines lines that fit the width,
+// ending the last line with an ellipsis if the text does not fit
+func wrapText(text string, size, width float64, maxLines int) []string {
+	var lines []string
+	line := ""
+	for _, r := range text {
+		if line != "" && pdf.TextWidth(line+string(r), size) > width {
+			if len(lines) == maxLines-1 {
+				runes := []rune(line)
+				for len(runes) > 0 && pdf.TextWidth(string(runes)+"…", size) > width {
+					runes = runes[:len(runes)-1]
+				}
+				return append(lines, string(runes)+"…")
+			}
+			lines = append(lines, line)
+			line = ""
+		}
+		line += string(r)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelLayouts(t *testing.T) {
+	sizes := map[string][2]float64{
+		"a4-12": {86.4, 42.3},
+		"a4-18": {70, 42.3},
+		"a4-24": {70, 33.9},
+		"a4-44": {48.3, 25.4},
+		"a4-65": {38.1, 21.2},
+	}
+	for _, layout := range LabelLayouts {
+		require.NoError(t, layout.Validate(), layout.Name)
+		width, height := layout.LabelSize()
+		assert.InDelta(t, sizes[layout.Name][0], width, 0.01, layout.Name)
+		assert.InDelta(t, sizes[layout.Name][1], height, 0.01, layout.Name)
+	}
+
+	_, err := FindLabelLayout("a4-99")
+	assert.Error(t, err)
+
+	assert.Error(t, LabelLayout{Columns: 0, Rows: 1}.Validate())
+	assert.Error(t, LabelLayout{Columns: 20, Rows: 40}.Validate())
+	assert.Error(t, LabelLayout{Columns: 2, Rows: 2, MarginTop: -1}.Validate())
+}
+
+func TestWrapText(t *testing.T) {
+	// 10pt text, 5pt per half-width column: 6 columns fit in 30pt
+	assert.Equal(t, []string{"Candy"}, wrapText("Candy", 10, 30, 2))
+	assert.Equal(t, []string{"ちょこ", "れーと"}, wrapText("ちょこれーと", 10, 30, 2))
+	assert.Equal(t, []string{"ちょこ", "れー…"}, wrapText("ちょこれーとぱい", 10, 30, 2))
+	assert.Empty(t, wrapText("", 10, 30, 2))
+}
+
+func setupLabelTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	require.NoError(t, repository.RunMigrations(db))
+	return db
+}
+
+func TestLabelService_WriteLabelSheetPDF(t *testing.T) {
+	db := setupLabelTestDB(t)
+	defer db.Close()
+
+	repos := repository.NewRepositories(db)
+	labels := &LabelService{itemRepo: repos.Item, settings: &SettingService{repo: repos.Setting}}
+	for _, name := range []string{"あめ", "チョコレート", "ガム"} {
+		_, err := db.Exec("INSERT INTO item (itemId, name, price, stock) VALUES (?, ?, 100, 10)", "ITEM-"+name, name)
+		require.NoError(t, err)
+	}
+	layout, err := FindLabelLayout("a4-12")
+	require.NoError(t, err)
+
+	t.Run("all items", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, labels.WriteLabelSheetPDF(&buf, nil, layout, 1))
+		assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+		assert.Contains(t, buf.String(), "/Count 1")
+	})
+
+	t.Run("copies spill onto more pages", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, labels.WriteLabelSheetPDF(&buf, []int{1, 2}, layout, 7))
+		// 14 labels on sheets of 12
+		assert.Contains(t, buf.String(), "/Count 2")
+	})
+
+	t.Run("unknown item", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := labels.WriteLabelSheetPDF(&buf, []int{99}, layout, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("too many copies", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Error(t, labels.WriteLabelSheetPDF(&buf, nil, layout, 101))
+	})
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/printer"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+)
+
+const (
+	// printMaxAttempts is how often a job is tried before it is marked failed
+	printMaxAttempts = 8
+	// printBaseBackoff is the wait after the first failure; it doubles with
+	// every further failure up to printMaxBackoff
+	printBaseBackoff = 2 * time.Second
+	printMaxBackoff  = 5 * time.Minute
+	// printPollInterval is how often the worker looks for jobs that are due
+	printPollInterval = time.Second
+)
+
+// PrintQueueService queues receipts in the database and sends them to the
+// printers in the background, retrying while a printer is unreachable
+type PrintQueueService struct {
+	repo    *repository.PrintJobRepository
+	printer string
+	timeout time.Duration
+	wake    chan struct{}
+}
+
+// NewPrintQueueService creates a print queue sending jobs to the given printer
+func NewPrintQueueService(repo *repository.PrintJobRepository, printerAddr string) *PrintQueueService {
+	return &PrintQueueService{
+		repo:    repo,
+		printer: printerAddr,
+		timeout: printer.DefaultTimeout,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues ESC/POS data for the receipt printer
+func (s *PrintQueueService) Enqueue(saleID int, data []byte) (*models.PrintJob, error) {
+	job := &models.PrintJob{
+		SaleID:  &saleID,
+		Printer: s.printer,
+		Data:    data,
+	}
+	if err := s.repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return job, nil
+}
+
+// Reprint queues a copy of an earlier job, e.g. after the paper ran out
+func (s *PrintQueueService) Reprint(id int) (*models.PrintJob, error) {
+	original, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status == models.PrintJobQueued {
+		return nil, fmt.Errorf("print job %d is still queued", id)
+	}
+
+	job := &models.PrintJob{
+		SaleID:  original.SaleID,
+		Printer: original.Printer,
+		Data:    original.Data,
+	}
+	if err := s.repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return job, nil
+}
+
+// GetJob returns a print job by ID
+func (s *PrintQueueService) GetJob(id int) (*models.PrintJob, error) {
+	return s.repo.FindByID(id)
+}
+
+// GetJobs returns the most recent jobs, optionally only those with a status
+func (s *PrintQueueService) GetJobs(status string) ([]*models.PrintJob, error) {
+	switch status {
+	case "", models.PrintJobQueued, models.PrintJobDone, models.PrintJobFailed:
+	default:
+		return nil, fmt.Errorf("invalid print job status: %s", status)
+	}
+	return s.repo.FindByStatus(status, 200)
+}
+
+// GetPrinterStatuses summarizes the queue of every printer
+func (s *PrintQueueService) GetPrinterStatuses() ([]models.PrinterStatus, error) {
+	return s.repo.PrinterStatuses()
+}
+
+// Run processes the queue until ctx is cancelled. Jobs queued before a
+// restart are picked up again, since they live in the database.
+func (s *PrintQueueService) Run(ctx context.Context) {
+	ticker := time.NewTicker(printPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.ProcessDue(time.Now()); err != nil {
+			log.Printf("Print queue: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// ProcessDue sends every job that is due. Each printer gets its jobs in the
+// order they were queued: while the oldest job of a printer waits for a
+// retry, the jobs behind it wait too.
+func (s *PrintQueueService) ProcessDue(now time.Time) error {
+	jobs, err := s.repo.FindQueued()
+	if err != nil {
+		return err
+	}
+
+	blocked := map[string]bool{}
+	for _, job := range jobs {
+		if blocked[job.Printer] {
+			continue
+		}
+		if job.NextAttemptAt.After(now) {
+			blocked[job.Printer] = true
+			continue
+		}
+
+		p := &printer.Printer{Addr: job.Printer, Timeout: s.timeout}
+		job.Attempts++
+		if err := p.Print(job.Data); err != nil {
+			job.LastError = err.Error()
+			if job.Attempts >= printMaxAttempts {
+				job.Status = models.PrintJobFailed
+			} else {
+				job.NextAttemptAt = now.Add(printBackoff(job.Attempts))
+				blocked[job.Printer] = true
+			}
+		} else {
+			printedAt := time.Now()
+			job.Status = models.PrintJobDone
+			job.LastError = ""
+			job.PrintedAt = &printedAt
+		}
+
+		if err := s.repo.UpdateState(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printBackoff returns the wait before the next attempt after the given
+// number of failed attempts
+func printBackoff(attempts int) time.Duration {
+	backoff := printBaseBackoff
+	for i := 1; i < attempts && backoff < printMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > printMaxBackoff {
+		backoff = printMaxBackoff
+	}
+	return backoff
+}
+
+// notify wakes the worker without blocking when it is busy
+func (s *PrintQueueService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
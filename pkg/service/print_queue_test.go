@@ -0,0 +1,168 @@
+package service
+
+import (
+	"database/sql"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPrintQueueTestDB(t *testing.T, path string) (*sql.DB, int) {
+	db, err := repository.InitDB(path)
+	require.NoError(t, err)
+	require.NoError(t, repository.RunMigrations(db))
+
+	// The schema seeds store and staff 1
+	var saleID int
+	err = db.QueryRow(`INSERT INTO sale (storeId, staffId, totalPrice, deposit, saleAt)
+		VALUES (1, 1, 100, 100, ?) RETURNING id`, time.Now()).Scan(&saleID)
+	require.NoError(t, err)
+
+	return db, saleID
+}
+
+// startFakePrinter listens on addr and sends everything it receives to the
+// returned channel
+func startFakePrinter(t *testing.T, addr string) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Close()
+			received <- string(data)
+		}
+	}()
+	return listener, received
+}
+
+func TestPrintQueueService_RetryKeepsOrder(t *testing.T) {
+	db, saleID := setupPrintQueueTestDB(t, filepath.Join(t.TempDir(), "kidspos.db"))
+	defer db.Close()
+
+	// Reserve an address and take the printer offline
+	listener, _ := startFakePrinter(t, "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+
+	queue := NewPrintQueueService(repository.NewRepositories(db).PrintJob, addr)
+	first, err := queue.Enqueue(saleID, []byte("first"))
+	require.NoError(t, err)
+	second, err := queue.Enqueue(saleID, []byte("second"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, queue.ProcessDue(now))
+
+	job, err := queue.GetJob(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PrintJobQueued, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.NotEmpty(t, job.LastError)
+	assert.WithinDuration(t, now.Add(printBaseBackoff), job.NextAttemptAt, time.Second)
+
+	// The second job waits behind the first
+	job, err = queue.GetJob(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, job.Attempts)
+
+	// The printer comes back, but the first job is not due yet
+	listener, received := startFakePrinter(t, addr)
+	defer listener.Close()
+	require.NoError(t, queue.ProcessDue(now.Add(time.Second)))
+	assert.Empty(t, received)
+
+	require.NoError(t, queue.ProcessDue(now.Add(printBaseBackoff)))
+	for _, want := range []string{"first", "second"} {
+		select {
+		case data := <-received:
+			assert.Equal(t, want, data)
+		case <-time.After(time.Second):
+			t.Fatalf("printer did not receive %q", want)
+		}
+	}
+
+	jobs, err := queue.GetJobs(models.PrintJobDone)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+}
+
+func TestPrintQueueService_FailsAfterMaxAttempts(t *testing.T) {
+	db, saleID := setupPrintQueueTestDB(t, filepath.Join(t.TempDir(), "kidspos.db"))
+	defer db.Close()
+
+	listener, _ := startFakePrinter(t, "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+
+	queue := NewPrintQueueService(repository.NewRepositories(db).PrintJob, addr)
+	job, err := queue.Enqueue(saleID, []byte("receipt"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < printMaxAttempts; i++ {
+		require.NoError(t, queue.ProcessDue(now))
+		now = now.Add(printMaxBackoff)
+	}
+
+	job, err = queue.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PrintJobFailed, job.Status)
+	assert.Equal(t, printMaxAttempts, job.Attempts)
+
+	// A failed job can be printed again
+	reprint, err := queue.Reprint(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PrintJobQueued, reprint.Status)
+	assert.Equal(t, []byte("receipt"), reprint.Data)
+
+	_, err = queue.Reprint(reprint.ID)
+	assert.Error(t, err)
+}
+
+func TestPrintQueueService_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kidspos.db")
+	db, saleID := setupPrintQueueTestDB(t, path)
+
+	listener, received := startFakePrinter(t, "127.0.0.1:0")
+	defer listener.Close()
+
+	queue := NewPrintQueueService(repository.NewRepositories(db).PrintJob, listener.Addr().String())
+	_, err := queue.Enqueue(saleID, []byte("receipt"))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// A new server process picks the job up
+	db, err = repository.InitDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	queue = NewPrintQueueService(repository.NewRepositories(db).PrintJob, listener.Addr().String())
+	require.NoError(t, queue.ProcessDue(time.Now()))
+
+	select {
+	case data := <-received:
+		assert.Equal(t, "receipt", data)
+	case <-time.After(time.Second):
+		t.Fatal("printer received nothing")
+	}
+}
+
+func TestPrintBackoff(t *testing.T) {
+	assert.Equal(t, 2*time.Second, printBackoff(1))
+	assert.Equal(t, 4*time.Second, printBackoff(2))
+	assert.Equal(t, 8*time.Second, printBackoff(3))
+	assert.Equal(t, printMaxBackoff, printBackoff(20))
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"log"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/printer"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/receipt"
+)
+
+// ReceiptService lays out and prints sale receipts
+type ReceiptService struct {
+	sales    *SaleService
+	settings *SettingService
+	queue    *PrintQueueService
+}
+
+// GetReceipt returns the receipt of a sale with the shop settings filled in
+func (s *ReceiptService) GetReceipt(saleID int) (*receipt.Receipt, error) {
+	sale, err := s.sales.GetSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.receiptFor(sale)
+}
+
+func (s *ReceiptService) receiptFor(sale *models.Sale) (*receipt.Receipt, error) {
+	shopName, err := s.settings.GetValue("shopName", "KidsPOS")
+	if err != nil {
+		return nil, err
+	}
+	footer, err := s.settings.GetValue("receiptFooter", "")
+	if err != nil {
+		return nil, err
+	}
+	currency, err := s.settings.GetValue("currency", "JPY")
+	if err != nil {
+		return nil, err
+	}
+
+	return &receipt.Receipt{
+		ShopName: shopName,
+		Footer:   footer,
+		Currency: currency,
+		Sale:     sale,
+	}, nil
+}
+
+// PrintSale queues the receipt of a sale for the receipt printer
+func (s *ReceiptService) PrintSale(saleID int) (*models.PrintJob, error) {
+	r, err := s.GetReceipt(saleID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := printer.EncodeReceipt(r.Lines(receipt.Width))
+	if err != nil {
+		return nil, err
+	}
+	return s.queue.Enqueue(saleID, data)
+}
+
+// AutoPrintEnabled reports whether receipts are printed after every sale
+func (s *ReceiptService) AutoPrintEnabled() (bool, error) {
+	value, err := s.settings.GetValue("autoPrintReceipt", "false")
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// PrintAfterSale queues the receipt of a new sale when automatic printing
+// is enabled. The sale is recorded either way, so a problem is only logged.
+func (s *ReceiptService) PrintAfterSale(sale *models.Sale) {
+	enabled, err := s.AutoPrintEnabled()
+	if err != nil {
+		log.Printf("Failed to read autoPrintReceipt setting: %v", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	if _, err := s.PrintSale(sale.ID); err != nil {
+		log.Printf("Failed to queue receipt for sale %d: %v", sale.ID, err)
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"io"
+	"sort"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/xlsx"
+)
+
+const reportTimeFormat = "2006-01-02 15:04:05"
+
+// summaryRow aggregates sales for one store, staff or item
+type summaryRow struct {
+	code     string
+	name     string
+	count    int
+	quantity int
+	amount   int
+}
+
+// WriteSalesReportExcel writes the sales matching the filter as an .xlsx workbook
+// with a sales sheet, a line item sheet and a summary sheet
+func (s *SaleService) WriteSalesReportExcel(w io.Writer, filter models.SaleFilter) error {
+	sales, err := s.GetSalesReport(filter)
+	if err != nil {
+		return err
+	}
+	details, err := s.repo.FindDetailsByFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	wb := xlsx.NewWorkbook()
+
+	// Sales sheet: one row per sale
+	salesSheet := wb.AddSheet("売上")
+	salesSheet.AddHeader("販売ID", "日時", "種別", "元販売ID", "店舗コード", "店舗名", "スタッフコード", "スタッフ名", "税抜金額", "消費税", "税率(%)", "合計金額", "預かり金額", "おつり", "理由")
+	saleByID := make(map[int]*models.Sale, len(sales))
+	for _, sale := range sales {
+		saleByID[sale.ID] = sale
+		var originalID interface{}
+		if sale.OriginalSaleID != nil {
+			originalID = *sale.OriginalSaleID
+		}
+		salesSheet.AddRow(sale.ID, sale.SaleAt.Format(reportTimeFormat), saleTypeLabel(sale.Type), originalID,
+			sale.Store.StoreID, sale.Store.Name, sale.Staff.StaffID, sale.Staff.Name,
+			sale.Subtotal, sale.Tax, sale.TaxRate, sale.TotalPrice, sale.Deposit, sale.Change, sale.Reason)
+	}
+
+	// Item totals include tax like the sale totals, so the summaries agree
+	linesBySale := map[int][]models.SaleDetail{}
+	for _, detail := range details {
+		linesBySale[detail.SaleID] = append(linesBySale[detail.SaleID], detail)
+	}
+	lineAmounts := make(map[int]int, len(details))
+	for saleID, lines := range linesBySale {
+		sale, ok := saleByID[saleID]
+		if !ok {
+			continue
+		}
+		for i, amount := range taxIncludedAmounts(sale.TotalPrice, lines) {
+			lineAmounts[lines[i].ID] = amount
+		}
+	}
+
+	// Line item sheet: one row per sale_detail
+	detailSheet := wb.AddSheet("明細")
+	detailSheet.AddHeader("販売ID", "日時", "商品コード", "商品名", "単価", "数量", "小計", "税込金額", "定価", "価格変更理由")
+	items := map[string]*summaryRow{}
+	for _, detail := range details {
+		saleAt := ""
+		if sale, ok := saleByID[detail.SaleID]; ok {
+			saleAt = sale.SaleAt.Format(reportTimeFormat)
+		}
+		overrideReason := ""
+		if detail.PriceOverridden {
+			overrideReason = detail.OverrideReason
+		}
+		amount, ok := lineAmounts[detail.ID]
+		if !ok {
+			amount = detail.Subtotal()
+		}
+		detailSheet.AddRow(detail.SaleID, saleAt, detail.Item.ItemID, detail.Item.Name,
+			detail.Price, detail.Quantity, detail.Subtotal(), amount, detail.ListPrice, overrideReason)
+
+		row := summaryFor(items, detail.Item.ItemID, detail.Item.Name)
+		if detail.Quantity > 0 {
+			row.count++
+		}
+		row.quantity += detail.Quantity
+		row.amount += amount
+	}
+
+	// Summary sheet: totals per store, staff and item. Reversals count
+	// against the totals but not as sales.
+	stores := map[string]*summaryRow{}
+	staffs := map[string]*summaryRow{}
+	totalAmount := 0
+	totalTax := 0
+	saleCount := 0
+	for _, sale := range sales {
+		count := 0
+		if sale.Type == models.SaleTypeSale {
+			count = 1
+		}
+		saleCount += count
+
+		store := summaryFor(stores, sale.Store.StoreID, sale.Store.Name)
+		store.count += count
+		store.amount += sale.TotalPrice
+
+		staff := summaryFor(staffs, sale.Staff.StaffID, sale.Staff.Name)
+		staff.count += count
+		staff.amount += sale.TotalPrice
+
+		totalAmount += sale.TotalPrice
+		totalTax += sale.Tax
+	}
+
+	summary := wb.AddSheet("集計")
+	summary.AddHeader("販売件数", "売上合計(税込)", "うち消費税")
+	summary.AddRow(saleCount, totalAmount, totalTax)
+	summary.AddRow()
+
+	summary.AddHeader("店舗コード", "店舗名", "販売件数", "売上合計(税込)")
+	for _, row := range sortedSummary(stores) {
+		summary.AddRow(row.code, row.name, row.count, row.amount)
+	}
+	summary.AddRow()
+
+	summary.AddHeader("スタッフコード", "スタッフ名", "販売件数", "売上合計(税込)")
+	for _, row := range sortedSummary(staffs) {
+		summary.AddRow(row.code, row.name, row.count, row.amount)
+	}
+	summary.AddRow()
+
+	summary.AddHeader("商品コード", "商品名", "販売数量", "売上合計(税込)")
+	for _, row := range sortedSummary(items) {
+		summary.AddRow(row.code, row.name, row.quantity, row.amount)
+	}
+
+	return wb.Write(w)
+}
+
+// taxIncludedAmounts returns what each line of a sale came to with tax.
+// Tax-inclusive lines already do. The tax the sale adds on top of its
+// tax-exclusive lines is shared among them by amount, with the rounding
+// left over on the last one, so the lines add up to the sale's total.
+func taxIncludedAmounts(total int, lines []models.SaleDetail) []int {
+	amounts := make([]int, len(lines))
+	sum, exclusive, last := 0, 0, -1
+	for i, line := range lines {
+		amounts[i] = line.Subtotal()
+		sum += amounts[i]
+		if line.TaxExcluded {
+			exclusive += amounts[i]
+			last = i
+		}
+	}
+	if exclusive == 0 {
+		return amounts
+	}
+
+	tax := total - sum
+	shared := 0
+	for i, line := range lines {
+		if !line.TaxExcluded || i == last {
+			continue
+		}
+		share := tax * line.Subtotal() / exclusive
+		amounts[i] += share
+		shared += share
+	}
+	amounts[last] += tax - shared
+	return amounts
+}
+
+func saleTypeLabel(saleType string) string {
+	switch saleType {
+	case models.SaleTypeVoid:
+		return "取消"
+	case models.SaleTypeRefund:
+		return "返品"
+	default:
+		return "販売"
+	}
+}
+
+func summaryFor(rows map[string]*summaryRow, code, name string) *summaryRow {
+	row, ok := rows[code]
+	if !ok {
+		row = &summaryRow{code: code, name: name}
+		rows[code] = row
+	}
+	return row
+}
+
+// sortedSummary orders summary rows by amount, highest first
+func sortedSummary(rows map[string]*summaryRow) []*summaryRow {
+	sorted := make([]*summaryRow, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].amount != sorted[j].amount {
+			return sorted[i].amount > sorted[j].amount
+		}
+		return sorted[i].code < sorted[j].code
+	})
+	return sorted
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaxIncludedAmounts(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int
+		lines []models.SaleDetail
+		want  []int
+	}{
+		{
+			name:  "tax-inclusive lines are kept",
+			total: 300,
+			lines: []models.SaleDetail{{Price: 100, Quantity: 2}, {Price: 100, Quantity: 1}},
+			want:  []int{200, 100},
+		},
+		{
+			name:  "tax is added to tax-exclusive lines",
+			total: 50,
+			lines: []models.SaleDetail{{Price: 15, Quantity: 3, TaxExcluded: true}},
+			want:  []int{50},
+		},
+		{
+			name:  "tax is shared by amount",
+			total: 430,
+			lines: []models.SaleDetail{
+				{Price: 100, Quantity: 1},
+				{Price: 100, Quantity: 1, TaxExcluded: true},
+				{Price: 200, Quantity: 1, TaxExcluded: true},
+			},
+			want: []int{100, 110, 220},
+		},
+		{
+			name:  "rounding goes to the last tax-exclusive line",
+			total: 34,
+			lines: []models.SaleDetail{
+				{Price: 15, Quantity: 1, TaxExcluded: true},
+				{Price: 15, Quantity: 1, TaxExcluded: true},
+				{Price: 1, Quantity: 1},
+			},
+			want: []int{16, 17, 1},
+		},
+		{
+			name:  "refunds are negative",
+			total: -17,
+			lines: []models.SaleDetail{{Price: 15, Quantity: -1, TaxExcluded: true}},
+			want:  []int{-17},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, taxIncludedAmounts(tt.total, tt.lines))
+		})
+	}
+}
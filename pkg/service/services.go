@@ -1,10 +1,15 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/config"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/models"
+	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/printer"
 	"github.com/KidsPOSProject/KidsPOS-Server-GO/pkg/repository"
 	"github.com/google/uuid"
 )
@@ -14,26 +19,61 @@ type Services struct {
 	Item       *ItemService
 	Store      *StoreService
 	Staff      *StaffService
+	Auth       *AuthService
+	Device     *DeviceService
+	Audit      *AuditService
 	Sale       *SaleService
 	Setting    *SettingService
+	Receipt    *ReceiptService
+	Label      *LabelService
+	PrintQueue *PrintQueueService
 	ApkVersion *ApkVersionService
+	Backup     *BackupService
+	Export     *ExportService
 }
 
 // NewServices creates all service instances
-func NewServices(repos *repository.Repositories) *Services {
+func NewServices(repos *repository.Repositories, cfg *config.Config) *Services {
+	audit := &AuditService{repo: repos.Audit}
+	setting := &SettingService{repo: repos.Setting, audit: audit}
+	sale := &SaleService{
+		repo:              repos.Sale,
+		audit:             audit,
+		itemRepo:          repos.Item,
+		settings:          setting,
+		idempotencyKeyTTL: cfg.IdempotencyKeyTTL,
+	}
+	printQueue := NewPrintQueueService(repos.PrintJob, printer.New(cfg.ReceiptPrinterHost, cfg.ReceiptPrinterPort).Addr)
+	receipt := &ReceiptService{
+		sales:    sale,
+		settings: setting,
+		queue:    printQueue,
+	}
+
 	return &Services{
-		Item:       &ItemService{repo: repos.Item},
-		Store:      &StoreService{repo: repos.Store},
-		Staff:      &StaffService{repo: repos.Staff},
-		Sale:       &SaleService{repo: repos.Sale, itemRepo: repos.Item},
-		Setting:    &SettingService{repo: repos.Setting},
-		ApkVersion: NewApkVersionService(repos.ApkVersion),
+		Item:       &ItemService{repo: repos.Item, audit: audit, codeSize: cfg.QRCodeSize},
+		Store:      &StoreService{repo: repos.Store, audit: audit},
+		Staff:      &StaffService{repo: repos.Staff, audit: audit},
+		Auth:       NewAuthService(repos.Staff, repos.Session, cfg.SessionTTL),
+		Device:     &DeviceService{repo: repos.Device, audit: audit},
+		Audit:      audit,
+		Sale:       sale,
+		Setting:    setting,
+		Receipt:    receipt,
+		Label:      &LabelService{itemRepo: repos.Item, settings: setting},
+		PrintQueue: printQueue,
+		ApkVersion: NewApkVersionService(repos.ApkVersion, audit),
+		Backup:     NewBackupService(repos.Backup, audit, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep, cfg.EncryptionKey),
+		Export:     NewExportService(repos.Export, audit, cfg.EncryptionKey),
 	}
 }
 
 // ItemService handles item business logic
 type ItemService struct {
-	repo *repository.ItemRepository
+	repo  *repository.ItemRepository
+	audit *AuditService
+	// codeSize is the size of QR code and barcode images in pixels
+	codeSize int
 }
 
 func (s *ItemService) GetAllItems() ([]*models.Item, error) {
@@ -44,10 +84,19 @@ func (s *ItemService) GetItem(id int) (*models.Item, error) {
 	return s.repo.FindByID(id)
 }
 
-func (s *ItemService) CreateItem(item *models.Item) error {
+// GetItemByCode returns the item with the given itemId or alias code
+func (s *ItemService) GetItemByCode(code string) (*models.Item, error) {
+	return s.repo.FindByCode(strings.TrimSpace(code))
+}
+
+func (s *ItemService) CreateItem(actor models.Actor, item *models.Item) error {
 	// Generate item ID if not provided
 	if item.ItemID == "" {
 		item.ItemID = s.generateItemID()
+	} else if inUse, err := s.repo.CodeInUse(item.ItemID); err != nil {
+		return err
+	} else if inUse {
+		return fmt.Errorf("item code %s is already in use", item.ItemID)
 	}
 
 	// Validate
@@ -61,10 +110,14 @@ func (s *ItemService) CreateItem(item *models.Item) error {
 		return fmt.Errorf("item stock must be non-negative")
 	}
 
-	return s.repo.Create(item)
+	if err := s.repo.Create(item); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditItem, item.ID, models.AuditCreate, nil, item)
+	return nil
 }
 
-func (s *ItemService) UpdateItem(item *models.Item) error {
+func (s *ItemService) UpdateItem(actor models.Actor, item *models.Item) error {
 	// Validate
 	if item.Name == "" {
 		return fmt.Errorf("item name is required")
@@ -76,11 +129,81 @@ func (s *ItemService) UpdateItem(item *models.Item) error {
 		return fmt.Errorf("item stock must be non-negative")
 	}
 
-	return s.repo.Update(item)
+	before, err := s.repo.FindByID(item.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Update(item); err != nil {
+		return err
+	}
+	after, err := s.repo.FindByID(item.ID)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditItem, item.ID, models.AuditUpdate, before, after)
+	return nil
 }
 
-func (s *ItemService) DeleteItem(id int) error {
-	return s.repo.Delete(id)
+func (s *ItemService) DeleteItem(actor models.Actor, id int) error {
+	before, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditItem, id, models.AuditDelete, before, nil)
+	return nil
+}
+
+// AddAlias lets another code, such as a JAN barcode, resolve to the item
+func (s *ItemService) AddAlias(actor models.Actor, id int, code string) (*models.Item, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, fmt.Errorf("code is required")
+	}
+	if len(code) > 64 {
+		return nil, fmt.Errorf("code must be at most 64 characters")
+	}
+
+	item, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := s.repo.CodeInUse(code)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, fmt.Errorf("code %s is already in use", code)
+	}
+
+	if err := s.repo.AddAlias(item.ID, code); err != nil {
+		return nil, err
+	}
+	after, err := s.repo.FindByID(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, models.AuditItem, item.ID, models.AuditUpdate, item, after)
+	return after, nil
+}
+
+// DeleteAlias removes an alias code from the item
+func (s *ItemService) DeleteAlias(actor models.Actor, id int, code string) error {
+	before, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteAlias(id, code); err != nil {
+		return err
+	}
+	after, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditItem, id, models.AuditUpdate, before, after)
+	return nil
 }
 
 func (s *ItemService) generateItemID() string {
@@ -90,7 +213,8 @@ func (s *ItemService) generateItemID() string {
 
 // StoreService handles store business logic
 type StoreService struct {
-	repo *repository.StoreRepository
+	repo  *repository.StoreRepository
+	audit *AuditService
 }
 
 func (s *StoreService) GetAllStores() ([]*models.Store, error) {
@@ -101,7 +225,7 @@ func (s *StoreService) GetStore(id int) (*models.Store, error) {
 	return s.repo.FindByID(id)
 }
 
-func (s *StoreService) CreateStore(store *models.Store) error {
+func (s *StoreService) CreateStore(actor models.Actor, store *models.Store) error {
 	// Generate store ID if not provided
 	if store.StoreID == "" {
 		store.StoreID = s.generateStoreID()
@@ -112,20 +236,44 @@ func (s *StoreService) CreateStore(store *models.Store) error {
 		return fmt.Errorf("store name is required")
 	}
 
-	return s.repo.Create(store)
+	if err := s.repo.Create(store); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditStore, store.ID, models.AuditCreate, nil, store)
+	return nil
 }
 
-func (s *StoreService) UpdateStore(store *models.Store) error {
+func (s *StoreService) UpdateStore(actor models.Actor, store *models.Store) error {
 	// Validate
 	if store.Name == "" {
 		return fmt.Errorf("store name is required")
 	}
 
-	return s.repo.Update(store)
+	before, err := s.repo.FindByID(store.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Update(store); err != nil {
+		return err
+	}
+	after, err := s.repo.FindByID(store.ID)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditStore, store.ID, models.AuditUpdate, before, after)
+	return nil
 }
 
-func (s *StoreService) DeleteStore(id int) error {
-	return s.repo.Delete(id)
+func (s *StoreService) DeleteStore(actor models.Actor, id int) error {
+	before, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditStore, id, models.AuditDelete, before, nil)
+	return nil
 }
 
 func (s *StoreService) generateStoreID() string {
@@ -134,7 +282,8 @@ func (s *StoreService) generateStoreID() string {
 
 // StaffService handles staff business logic
 type StaffService struct {
-	repo *repository.StaffRepository
+	repo  *repository.StaffRepository
+	audit *AuditService
 }
 
 func (s *StaffService) GetAllStaffs() ([]*models.Staff, error) {
@@ -145,7 +294,7 @@ func (s *StaffService) GetStaff(id int) (*models.Staff, error) {
 	return s.repo.FindByID(id)
 }
 
-func (s *StaffService) CreateStaff(staff *models.Staff) error {
+func (s *StaffService) CreateStaff(actor models.Actor, staff *models.Staff) error {
 	// Generate staff ID if not provided
 	if staff.StaffID == "" {
 		staff.StaffID = s.generateStaffID()
@@ -155,43 +304,160 @@ func (s *StaffService) CreateStaff(staff *models.Staff) error {
 	if staff.Name == "" {
 		return fmt.Errorf("staff name is required")
 	}
+	if err := validateRole(staff); err != nil {
+		return err
+	}
 
-	return s.repo.Create(staff)
+	// Staff members without a PIN are listed but cannot log in
+	if staff.PIN != "" {
+		hash, err := hashPIN(staff.PIN)
+		if err != nil {
+			return err
+		}
+		staff.PINHash = hash
+	}
+	staff.PIN = ""
+
+	if err := s.repo.Create(staff); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditStaff, staff.ID, models.AuditCreate, nil, staff)
+	return nil
 }
 
-func (s *StaffService) UpdateStaff(staff *models.Staff) error {
+// UpdateStaff updates a staff member. A PIN, when given, replaces the old
+// one and logs the staff member out everywhere. Without a role the staff
+// member keeps theirs.
+func (s *StaffService) UpdateStaff(actor models.Actor, staff *models.Staff) error {
 	// Validate
 	if staff.Name == "" {
 		return fmt.Errorf("staff name is required")
 	}
+	before, err := s.repo.FindByID(staff.ID)
+	if err != nil {
+		return err
+	}
+	if staff.Role == "" {
+		staff.Role = before.Role
+		if staff.StoreID == nil {
+			staff.StoreID = before.StoreID
+		}
+	}
+	if err := validateRole(staff); err != nil {
+		return err
+	}
+
+	var pinHash string
+	if staff.PIN != "" {
+		hash, err := hashPIN(staff.PIN)
+		if err != nil {
+			return err
+		}
+		pinHash = hash
+	}
+	staff.PIN = ""
+
+	if err := s.repo.Update(staff); err != nil {
+		return err
+	}
+	if pinHash != "" {
+		if err := s.repo.SetPINHash(staff.ID, pinHash); err != nil {
+			return err
+		}
+		staff.HasPIN = true
+	}
 
-	return s.repo.Update(staff)
+	after, err := s.repo.FindByID(staff.ID)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditStaff, staff.ID, models.AuditUpdate, before, after)
+	return nil
 }
 
-func (s *StaffService) DeleteStaff(id int) error {
-	return s.repo.Delete(id)
+func (s *StaffService) DeleteStaff(actor models.Actor, id int) error {
+	before, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditStaff, id, models.AuditDelete, before, nil)
+	return nil
 }
 
 func (s *StaffService) generateStaffID() string {
 	return fmt.Sprintf("STAFF-%s", uuid.New().String()[:8])
 }
 
+// validateRole defaults the role to cashier and checks that a store manager
+// has a store to manage. Only store managers keep a store.
+func validateRole(staff *models.Staff) error {
+	if staff.Role == "" {
+		staff.Role = models.RoleCashier
+	}
+	if !models.ValidRole(staff.Role) {
+		return fmt.Errorf("invalid role: %s", staff.Role)
+	}
+	if staff.Role != models.RoleStoreManager {
+		staff.StoreID = nil
+	} else if staff.StoreID == nil || *staff.StoreID <= 0 {
+		return fmt.Errorf("a store manager needs a store")
+	}
+	return nil
+}
+
 // SaleService handles sale business logic
 type SaleService struct {
-	repo     *repository.SaleRepository
-	itemRepo *repository.ItemRepository
+	repo              *repository.SaleRepository
+	audit             *AuditService
+	itemRepo          *repository.ItemRepository
+	settings          *SettingService
+	idempotencyKeyTTL time.Duration
 }
 
 func (s *SaleService) GetAllSales() ([]*models.Sale, error) {
 	return s.repo.FindAll()
 }
 
-func (s *SaleService) CreateSale(sale *models.Sale) error {
+func (s *SaleService) GetSale(id int) (*models.Sale, error) {
+	sale, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sale.ChangeBreakdown, err = s.breakDownChange(sale.Change)
+	if err != nil {
+		return nil, err
+	}
+	return sale, nil
+}
+
+// CreateSale records a sale. A sale submitted again with the client ID of a
+// sale recorded before fails with a DuplicateSaleError carrying that sale.
+func (s *SaleService) CreateSale(actor models.Actor, sale *models.Sale) error {
 	// Set sale time if not provided
 	if sale.SaleAt.IsZero() {
 		sale.SaleAt = time.Now()
 	}
 
+	// Retries of a sale that already went through must not fail validation
+	// again, e.g. because the last item is now sold
+	sale.ClientID = strings.TrimSpace(sale.ClientID)
+	if len(sale.ClientID) > 255 {
+		return fmt.Errorf("client ID must be at most 255 characters")
+	}
+	if sale.ClientID != "" {
+		saleID, err := s.repo.FindIDByClientID(sale.ClientID)
+		if err != nil {
+			return err
+		}
+		if saleID != 0 {
+			return s.duplicate(&models.DuplicateSaleError{ClientID: sale.ClientID, SaleID: saleID})
+		}
+	}
+
 	// Validate
 	if sale.StoreID <= 0 {
 		return fmt.Errorf("store is required")
@@ -203,58 +469,319 @@ func (s *SaleService) CreateSale(sale *models.Sale) error {
 		return fmt.Errorf("sale must have at least one item")
 	}
 
-	// Calculate total price
-	totalPrice := 0
-	for _, detail := range sale.Details {
-		// Validate stock availability
+	// Price the lines. Stock is checked by the repository inside the
+	// sale transaction, where it cannot change underneath us.
+	for i := range sale.Details {
+		detail := &sale.Details[i]
+		if detail.Quantity <= 0 {
+			return fmt.Errorf("quantity must be positive")
+		}
+
 		item, err := s.itemRepo.FindByID(detail.ItemID)
 		if err != nil {
 			return fmt.Errorf("item not found: %d", detail.ItemID)
 		}
-		if item.Stock < detail.Quantity {
-			return fmt.Errorf("insufficient stock for item: %s", item.Name)
+
+		// The catalog decides the price. A different price is only accepted
+		// as an explicit override with a reason.
+		detail.ListPrice = item.Price
+		detail.Price = item.Price
+		detail.PriceOverridden = false
+		if detail.OverridePrice != nil {
+			if *detail.OverridePrice < 0 {
+				return fmt.Errorf("override price must be non-negative")
+			}
+			if strings.TrimSpace(detail.OverrideReason) == "" {
+				return fmt.Errorf("override reason is required for item: %s", item.Name)
+			}
+			if *detail.OverridePrice != item.Price {
+				detail.Price = *detail.OverridePrice
+				detail.PriceOverridden = true
+			}
+		}
+		if !detail.PriceOverridden {
+			detail.OverrideReason = ""
 		}
+		detail.TaxExcluded = item.TaxExcluded
+	}
+
+	taxRate, err := s.settings.GetTaxRate()
+	if err != nil {
+		return err
+	}
+	taxRounding, err := s.settings.GetTaxRounding()
+	if err != nil {
+		return err
+	}
+	sale.ApplyTax(taxRate, taxRounding)
 
-		// Set price from item if not provided
-		if detail.Price == 0 {
-			detail.Price = item.Price
+	// The customer must hand over at least the total; the rest comes back
+	if sale.Deposit < sale.TotalPrice {
+		return fmt.Errorf("deposit %d does not cover total %d", sale.Deposit, sale.TotalPrice)
+	}
+	sale.Change = sale.Deposit - sale.TotalPrice
+
+	// Work out the coins and bills before saving, so a broken setting
+	// does not leave a recorded sale behind an error
+	breakdown, err := s.breakDownChange(sale.Change)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.CreateIdempotent(sale, time.Now().Add(s.idempotencyKeyTTL))
+	var dupErr *models.DuplicateSaleError
+	if errors.As(err, &dupErr) {
+		sale.ID = 0
+		return s.duplicate(dupErr)
+	}
+	if err != nil {
+		return err
+	}
+	sale.ChangeBreakdown = breakdown
+	s.audit.Record(actor, models.AuditSale, sale.ID, models.AuditCreate, nil, sale)
+	return nil
+}
+
+// maxBatchSales caps a batch upload so a single request stays reasonably short
+const maxBatchSales = 1000
+
+// CreateSales records sales queued by a register while it was offline. Each
+// sale needs a client ID so that uploading the queue again is harmless, and
+// is recorded on its own, so one bad sale does not hold back the others.
+func (s *SaleService) CreateSales(actor models.Actor, sales []models.Sale) ([]models.BatchSaleResult, error) {
+	if len(sales) == 0 {
+		return nil, fmt.Errorf("batch must have at least one sale")
+	}
+	if len(sales) > maxBatchSales {
+		return nil, fmt.Errorf("batch must have at most %d sales", maxBatchSales)
+	}
+
+	results := make([]models.BatchSaleResult, len(sales))
+	for i := range sales {
+		sale := &sales[i]
+		result := &results[i]
+		result.ClientID = sale.ClientID
+
+		if strings.TrimSpace(sale.ClientID) == "" {
+			result.Status = models.BatchSaleRejected
+			result.Reason = "client ID is required"
+			continue
+		}
+		if sale.SaleAt.IsZero() {
+			result.Status = models.BatchSaleRejected
+			result.Reason = "sale time is required"
+			continue
 		}
 
-		totalPrice += detail.Price * detail.Quantity
+		err := s.CreateSale(actor, sale)
+		var dupErr *models.DuplicateSaleError
+		switch {
+		case err == nil:
+			result.Status = models.BatchSaleCreated
+			result.SaleID = sale.ID
+		case errors.As(err, &dupErr):
+			result.Status = models.BatchSaleDuplicate
+			result.SaleID = dupErr.SaleID
+		default:
+			result.Status = models.BatchSaleRejected
+			result.Reason = err.Error()
+		}
 	}
+	return results, nil
+}
 
-	sale.TotalPrice = totalPrice
+// duplicate loads the originally recorded sale into a DuplicateSaleError
+func (s *SaleService) duplicate(dupErr *models.DuplicateSaleError) error {
+	original, err := s.GetSale(dupErr.SaleID)
+	if err != nil {
+		return err
+	}
+	original.ClientID = dupErr.ClientID
+	dupErr.Sale = original
+	return dupErr
+}
 
-	// Set deposit to total price if not provided
-	if sale.Deposit == 0 {
-		sale.Deposit = sale.TotalPrice
+// breakDownChange splits the change into the configured coins and bills
+func (s *SaleService) breakDownChange(change int) ([]models.ChangeCount, error) {
+	if change <= 0 {
+		return nil, nil
 	}
 
-	return s.repo.Create(sale)
+	denominations, err := s.settings.GetChangeDenominations()
+	if err != nil {
+		return nil, err
+	}
+	return BreakDownChange(change, denominations), nil
 }
 
-func (s *SaleService) GetSalesReport(startDate, endDate time.Time) ([]*models.Sale, error) {
-	// For now, return all sales
-	// TODO: Implement date filtering in repository
-	return s.repo.FindAll()
+// VoidSale reverses everything left of a sale and puts the items back in stock
+func (s *SaleService) VoidSale(actor models.Actor, id int, reason string) (*models.Sale, error) {
+	return s.reverse(actor, id, models.SaleTypeVoid, nil, reason)
+}
+
+// RefundSale reverses the given lines of a sale and puts the items back in stock
+func (s *SaleService) RefundSale(actor models.Actor, id int, lines []models.RefundLine, reason string) (*models.Sale, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("refund must have at least one line")
+	}
+	return s.reverse(actor, id, models.SaleTypeRefund, lines, reason)
+}
+
+// reverse records the reversal of a sale. The audit log keeps it under the
+// original sale, with the reversal as what came after.
+func (s *SaleService) reverse(actor models.Actor, id int, saleType string, lines []models.RefundLine, reason string) (*models.Sale, error) {
+	reversal := &models.Sale{
+		Type:           saleType,
+		OriginalSaleID: &id,
+		Reason:         reason,
+		SaleAt:         time.Now(),
+		DeviceID:       actor.DeviceID,
+	}
+	if err := s.repo.CreateReversal(reversal, lines); err != nil {
+		return nil, err
+	}
+
+	reversal, err := s.repo.FindByID(reversal.ID)
+	if err != nil {
+		return nil, err
+	}
+	action := models.AuditVoid
+	if saleType == models.SaleTypeRefund {
+		action = models.AuditRefund
+	}
+	s.audit.Record(actor, models.AuditSale, id, action, nil, reversal)
+	return reversal, nil
+}
+
+func (s *SaleService) GetSalesReport(filter models.SaleFilter) ([]*models.Sale, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, fmt.Errorf("end date must not be before start date")
+	}
+
+	return s.repo.FindByFilter(filter)
 }
 
 // SettingService handles setting business logic
 type SettingService struct {
-	repo *repository.SettingRepository
+	repo  *repository.SettingRepository
+	audit *AuditService
 }
 
 func (s *SettingService) GetAllSettings() ([]*models.Setting, error) {
 	return s.repo.FindAll()
 }
 
-func (s *SettingService) UpdateSetting(key, value string) error {
+// GetValue returns the value of a setting, or defaultValue if it is not set
+func (s *SettingService) GetValue(key, defaultValue string) (string, error) {
+	setting, err := s.repo.FindByKey(key)
+	if err != nil {
+		return "", err
+	}
+	if setting == nil || setting.Value == "" {
+		return defaultValue, nil
+	}
+	return setting.Value, nil
+}
+
+// GetTaxRate returns the tax rate in percent
+func (s *SettingService) GetTaxRate() (float64, error) {
+	value, err := s.GetValue("taxRate", "0")
+	if err != nil {
+		return 0, err
+	}
+	return parseTaxRate(value)
+}
+
+// GetTaxRounding returns how tax amounts are rounded to whole currency units
+func (s *SettingService) GetTaxRounding() (string, error) {
+	value, err := s.GetValue("taxRounding", models.TaxRoundingFloor)
+	if err != nil {
+		return "", err
+	}
+	if !models.ValidTaxRounding(value) {
+		return "", fmt.Errorf("invalid tax rounding %q: must be floor, round or ceil", value)
+	}
+	return value, nil
+}
+
+func parseTaxRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || rate < 0 || rate > 100 {
+		return 0, fmt.Errorf("invalid tax rate %q: must be a percentage between 0 and 100", value)
+	}
+	return rate, nil
+}
+
+// GetChangeDenominations returns the coins and bills change is paid out in,
+// largest first. "auto" picks the set of the configured currency.
+func (s *SettingService) GetChangeDenominations() ([]models.Denomination, error) {
+	value, err := s.GetValue("changeDenominations", "auto")
+	if err != nil {
+		return nil, err
+	}
+	if value != "auto" {
+		return ParseDenominations(value)
+	}
+
+	currency, err := s.GetValue("currency", "JPY")
+	if err != nil {
+		return nil, err
+	}
+	// Unknown currencies get no breakdown; the change amount still stands
+	return currencyDenominations[strings.ToUpper(currency)], nil
+}
+
+func (s *SettingService) UpdateSetting(actor models.Actor, key, value string) error {
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+
+	before, err := s.repo.FindByKey(key)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Update(key, value); err != nil {
+		return err
+	}
+	after, err := s.repo.FindByKey(key)
+	if err != nil {
+		return err
+	}
+	// Pass missing settings as untyped nils, which the audit log leaves empty
+	var beforeState, afterState interface{}
+	if before != nil {
+		beforeState = before
+	}
+	if after != nil {
+		afterState = after
+	}
+	s.audit.Record(actor, models.AuditSetting, key, models.AuditUpdate, beforeState, afterState)
+	return nil
+}
+
+// validateSetting checks a value for the settings the server reads
+func validateSetting(key, value string) error {
 	if key == "" {
 		return fmt.Errorf("setting key is required")
 	}
 	if value == "" {
 		return fmt.Errorf("setting value is required")
 	}
-
-	return s.repo.Update(key, value)
+	switch key {
+	case "changeDenominations":
+		if value != "auto" {
+			if _, err := ParseDenominations(value); err != nil {
+				return err
+			}
+		}
+	case "taxRate":
+		if _, err := parseTaxRate(value); err != nil {
+			return err
+		}
+	case "taxRounding":
+		if !models.ValidTaxRounding(value) {
+			return fmt.Errorf("invalid tax rounding %q: must be floor, round or ceil", value)
+		}
+	}
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,71 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", ColumnName(0))
+	assert.Equal(t, "Z", ColumnName(25))
+	assert.Equal(t, "AA", ColumnName(26))
+	assert.Equal(t, "AZ", ColumnName(51))
+	assert.Equal(t, "BA", ColumnName(52))
+}
+
+func TestWorkbook_Write(t *testing.T) {
+	t.Run("writes every part as well-formed XML", func(t *testing.T) {
+		wb := NewWorkbook()
+		sales := wb.AddSheet("売上")
+		sales.AddHeader("ID", "Name", "Total", "Rate")
+		sales.AddRow(1, "Candy & <Gum>", 300, 8.5)
+		wb.AddSheet("集計").AddRow("empty")
+
+		var buf bytes.Buffer
+		require.NoError(t, wb.Write(&buf))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+
+		names := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			require.NoError(t, err)
+			names[f.Name] = string(content)
+
+			// Every part must parse
+			decoder := xml.NewDecoder(bytes.NewReader(content))
+			for {
+				_, err := decoder.Token()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err, f.Name)
+			}
+		}
+
+		assert.Contains(t, names, "[Content_Types].xml")
+		assert.Contains(t, names, "xl/workbook.xml")
+		assert.Contains(t, names, "xl/worksheets/sheet1.xml")
+		assert.Contains(t, names, "xl/worksheets/sheet2.xml")
+		assert.Contains(t, names["xl/workbook.xml"], `name="売上"`)
+		assert.Contains(t, names["xl/worksheets/sheet1.xml"], `<c r="C2"><v>300</v></c>`)
+		assert.Contains(t, names["xl/worksheets/sheet1.xml"], `<c r="D2"><v>8.5</v></c>`)
+		assert.Contains(t, names["xl/worksheets/sheet1.xml"], "Candy &amp; &lt;Gum&gt;")
+	})
+
+	t.Run("empty workbook", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := NewWorkbook().Write(&buf)
+		assert.Error(t, err)
+	})
+}